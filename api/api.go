@@ -15,10 +15,12 @@ var (
 	PLUGIN_DIR string = filepath.Join(BASE_DIR, "plugin")
 	CACHE_DIR string = filepath.Join(BASE_DIR, "caches")
 	PLUGIN_CACHE_DIR string = filepath.Join(CACHE_DIR, "plugin")
+	CACHE_TEMP_DIR string = filepath.Join(CACHE_DIR, "tmp")
 )
 
 var (
 	ErrNotFound = errors.New("ErrNotFound")
+	ErrChecksumMismatch = errors.New("ErrChecksumMismatch")
 )
 
 type PluginCounts struct {
@@ -36,42 +38,93 @@ type PluginLabels struct {
 	Api         bool `json:"api,omitempty"`
 }
 
+var PluginLabelNames = []string{"information", "tool", "management", "api"}
+
+func IsPluginLabel(name string)(bool){
+	for _, n := range PluginLabelNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the names of the labels which are set
+func (l PluginLabels)Names()(names []string){
+	for i, set := range [...]bool{l.Information, l.Tool, l.Management, l.Api} {
+		if set {
+			names = append(names, PluginLabelNames[i])
+		}
+	}
+	return
+}
+
 type DependMap map[string]VersionCondList
 type RequireMap map[string]string
 
 type PluginInfo struct {
-	Id           string       `json:"id"`
-	Name         string       `json:"name"`
-	Version      Version      `json:"version"`
-	Authors      []string     `json:"authors"`
-	Desc         string       `json:"desc,omitempty"`
-	Desc_zhCN    string       `json:"desc_zhCN,omitempty"`
-	CreateAt     time.Time    `json:"createAt"`
-	LastRelease  *time.Time   `json:"lastRelease,omitempty"`
-	Repo         string       `json:"repo,omitempty"`
-	RepoBranch   string       `json:"repoBranch,omitempty"`
-	RepoSubdir   string       `json:"repoSubdir,omitempty"`
-	Link         string       `json:"link,omitempty"`
-	Labels       PluginLabels `json:"labels"`
-	Downloads    int64        `json:"downloads"`
-	Dependencies DependMap    `json:"dependencies,omitempty"`
-	Requirements RequireMap   `json:"requirements,omitempty"`
-	GithubSync   bool         `json:"github_sync"`
-	GhRepoOwner  string       `json:"ghRepoOwner,omitempty"`
-	GhRepoName   string       `json:"ghRepoName,omitempty"`
-	LastSync     *time.Time   `json:"last_sync,omitempty"`
+	Id             string       `json:"id"`
+	Name           string       `json:"name"`
+	Version        Version      `json:"version"`
+	Authors        []string     `json:"authors"`
+	Desc           string       `json:"desc,omitempty"`
+	Desc_zhCN      string       `json:"desc_zhCN,omitempty"`
+	CreateAt       time.Time    `json:"createAt"`
+	LastRelease    *time.Time   `json:"lastRelease,omitempty"`
+	Repo           string       `json:"repo,omitempty"`
+	RepoBranch     string       `json:"repoBranch,omitempty"`
+	RepoSubdir     string       `json:"repoSubdir,omitempty"`
+	Link           string       `json:"link,omitempty"`
+	Labels         PluginLabels `json:"labels"`
+	Downloads      int64        `json:"downloads"`
+	LocalDownloads int64        `json:"localDownloads"`
+	Dependencies   DependMap    `json:"dependencies,omitempty"`
+	Requirements   RequireMap   `json:"requirements,omitempty"`
+	GithubSync     bool         `json:"github_sync"`
+	GhRepoOwner    string       `json:"ghRepoOwner,omitempty"`
+	GhRepoName     string       `json:"ghRepoName,omitempty"`
+	LastSync       *time.Time   `json:"last_sync,omitempty"`
 }
 
 type PluginRelease struct {
-	Id        string    `json:"id"`
-	Tag       Version   `json:"tag"`
-	Enabled   bool      `json:"enabled"`
-	Stable    bool      `json:"stable"`
-	Size      int64     `json:"size"`
-	Uploaded  time.Time `json:"uploaded"`
-	FileName  string    `json:"filename"`
-	Downloads int       `json:"downloads"`
-	GithubUrl string    `json:"github_url"`
+	Id             string    `json:"id"`
+	Tag            Version   `json:"tag"`
+	Name           string    `json:"name,omitempty"`
+	Enabled        bool      `json:"enabled"`
+	Stable         bool      `json:"stable"`
+	Size           int64     `json:"size"`
+	Uploaded       time.Time `json:"uploaded"`
+	FileName       string    `json:"filename"`
+	Downloads      int       `json:"downloads"`
+	LocalDownloads int       `json:"localDownloads"`
+	GithubUrl      string    `json:"github_url"`
+	Checksums
+	// Problems lists the disagreements between the embedded metadata of the asset and the release
+	Problems       []string  `json:"problems,omitempty"`
+	// Assets lists all files attached to the release, the first one is the main .mcdr file
+	Assets         []*ReleaseAsset `json:"assets"`
+}
+
+// GetAsset returns the asset with the name, or nil if not exists
+func (r *PluginRelease)GetAsset(name string)(*ReleaseAsset){
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// ReleaseAsset is a file attached to a release
+type ReleaseAsset struct {
+	Name           string    `json:"name"`
+	Size           int64     `json:"size"`
+	ContentType    string    `json:"contentType"`
+	Uploaded       time.Time `json:"uploaded"`
+	Downloads      int       `json:"downloads"`
+	LocalDownloads int       `json:"localDownloads"`
+	GithubUrl      string    `json:"github_url"`
+	Checksums
 }
 
 type PluginListOpt struct{
@@ -86,6 +139,7 @@ type PluginListOpt struct{
 type Content struct{
 	Data func()([]byte, error)
 	CloseFunc func()(error)
+	// ModTime is the last modified time in the HTTP date format, empty if unknown
 	ModTime string
 	URLPrefix string
 	DataURLPrefix string
@@ -103,6 +157,10 @@ type API interface {
 	GetPluginLastUpdateTime(id string)(modTime time.Time, err error)
 	GetPluginCounts(opt PluginListOpt)(count PluginCounts, err error)
 	GetPluginList(opt PluginListOpt)(infos []*PluginInfo, err error)
+	// StreamPluginList calls fn with each plugin of the list in order,
+	// the iteration is stopped with the error that fn returns.
+	// fn may be slow, the implementations should not hold the database resources while calling it
+	StreamPluginList(opt PluginListOpt, fn func(info *PluginInfo)(error))(err error)
 	GetPluginIdList(opt PluginListOpt)(ids []string, err error)
 	GetPluginInfo(id string, version string)(info *PluginInfo, err error)
 	GetPluginInfos(id string)(info []*PluginInfo, err error)
@@ -110,6 +168,46 @@ type API interface {
 	GetPluginReleases(id string)(releases []*PluginRelease, err error)
 	GetPluginRelease(id string, tag Version)(release *PluginRelease, err error)
 	GetPluginReleaseAsset(id string, tag Version, filename string)(rc io.ReadSeekCloser, modTime time.Time, err error)
+	GetPluginReleaseChangelog(id string, tag Version)(content Content, err error)
+	GetPluginChangelog(id string, from, to *Version)(content Content, err error)
+	GetPluginReleaseMeta(id string, tag Version)(info *McdrInfo, err error)
+	GetPluginReleaseFile(id string, tag Version, name string)(data []byte, file *McdrFile, err error)
+	RecordPluginDownload(id string, tag Version, filename string)(err error)
+	GetPluginDownloadStats(id string, opt DownloadStatsOpt)(stats []*DownloadStat, err error)
+	GetReleaseFeed(opt ReleaseFeedOpt)(items []*FeedRelease, err error)
+	GetSitemapPlugins()(plugins []*SitemapPlugin, err error)
+
+	// GetAPIKey returns the key of the token, ErrUnauthorized will be returned if the token is invalid or revoked
+	GetAPIKey(token string)(key *APIKey, err error)
+	RecordAPIKeyUsage(id string)
+
+	// The methods below edit the plugins that not synced from github,
+	// ErrGithubSynced will be returned for the plugins that synced from github
+	CreatePlugin(id string, meta *PluginMetaUpdate)(err error)
+	UpdatePluginMeta(id string, meta *PluginMetaUpdate)(err error)
+	SetPluginReadme(id string, data []byte)(err error)
+	PublishRelease(id string, tag Version, opt ReleasePublishOpt, data []byte)(release *PluginRelease, err error)
+	SetReleaseStable(id string, tag Version, stable bool)(err error)
+
+	// The webhooks are owned by the API keys
+	CreateWebhook(owner string, hook *Webhook)(err error)
+	GetWebhooks(owner string)(hooks []*Webhook, err error)
+	DeleteWebhook(owner string, id int64)(err error)
+	GetWebhookDeliveries(owner string, id int64, limit int)(deliveries []*WebhookDelivery, err error)
+
+	// GetCatalogueEvents returns the events after the sequence since, sorted by increasing sequence
+	GetCatalogueEvents(since int64, limit int)(events []*CatalogueEvent, err error)
+	// GetLastEventSeq returns the sequence of the latest catalogue event, or zero if there is none
+	GetLastEventSeq()(seq int64, err error)
+	// GetChanges returns the change log after the sequence since, sorted by increasing sequence
+	GetChanges(since int64, limit int)(changes []*CatalogueChange, err error)
+
+	// The methods below are used by the admins
+	SetPluginEnabled(id string, enabled bool)(err error)
+	SetReleaseEnabled(id string, tag Version, enabled bool)(err error)
+	OverrideReleaseStable(id string, tag Version, stable bool)(err error)
+	ResyncPlugin(id string)(err error)
+	PurgePluginCache(id string)(n int, err error)
 }
 
 type StatusCodeErr struct{
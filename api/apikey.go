@@ -0,0 +1,99 @@
+
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// ScopePublish allows to create and edit the plugins that not synced from github
+	ScopePublish = "publish"
+	// ScopeAdmin allows to moderate all plugins and releases, and to purge the caches
+	ScopeAdmin = "admin"
+)
+
+var (
+	ErrUnauthorized = errors.New("ErrUnauthorized")
+	ErrForbidden = errors.New("ErrForbidden")
+)
+
+// APIKeyPrefix is the prefix of the API key tokens, it makes the leaked tokens easy to search
+const APIKeyPrefix = "pwp_"
+
+type APIKey struct{
+	Id       string     `json:"id"`
+	Name     string     `json:"name"`
+	Scopes   []string   `json:"scopes"`
+	CreateAt time.Time  `json:"createAt"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+	// Quota is the requests allowed per RateLimitWindow, zero means the default limit
+	Quota    int        `json:"quota"`
+}
+
+// RateLimit returns the quota of the key, or def if the quota is not set
+func (k *APIKey)RateLimit(def int)(int){
+	if k.Quota > 0 {
+		return k.Quota
+	}
+	return def
+}
+
+// HasScope reports whether the key is granted the scope
+func (k *APIKey)HasScope(scope string)(bool){
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int)(string, error){
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GenerateAPIKey generates a new key id and the secret.
+// The token is given to the user, and only the hash of the secret should be stored
+func GenerateAPIKey()(id string, secret string, token string, err error){
+	if id, err = randomHex(8); err != nil {
+		return
+	}
+	if secret, err = randomHex(32); err != nil {
+		return
+	}
+	token = APIKeyPrefix + id + "_" + secret
+	return
+}
+
+// ParseAPIKey splits the token into the key id and the secret
+func ParseAPIKey(token string)(id string, secret string, err error){
+	if !strings.HasPrefix(token, APIKeyPrefix) {
+		return "", "", ErrUnauthorized
+	}
+	var ok bool
+	if id, secret, ok = strings.Cut(token[len(APIKeyPrefix):], "_"); !ok || len(id) == 0 || len(secret) == 0 {
+		return "", "", ErrUnauthorized
+	}
+	return
+}
+
+// HashAPISecret returns the hex encoded SHA-256 hash of the secret
+func HashAPISecret(secret string)(string){
+	sum := sha256.Sum256(([]byte)(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPISecret compares the secret with the stored hash in constant time
+func CheckAPISecret(hash string, secret string)(bool){
+	return subtle.ConstantTimeCompare(([]byte)(hash), ([]byte)(HashAPISecret(secret))) == 1
+}
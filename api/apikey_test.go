@@ -0,0 +1,54 @@
+package api_test
+
+import (
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestAPIKey(t *testing.T){
+	id, secret, token, err := api.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+	id2, secret2, err := api.ParseAPIKey(token)
+	if err != nil {
+		t.Fatalf("Cannot parse token %q: %v", token, err)
+	}
+	if id2 != id || secret2 != secret {
+		t.Errorf("Parsed (%q, %q), expect (%q, %q)", id2, secret2, id, secret)
+	}
+	hash := api.HashAPISecret(secret)
+	if !api.CheckAPISecret(hash, secret2) {
+		t.Errorf("The secret does not match its hash")
+	}
+	if api.CheckAPISecret(hash, secret2 + "0") {
+		t.Errorf("A wrong secret matches the hash")
+	}
+	for _, bad := range []string{"", "pwp_", "pwp_abc", "pwp__abc", "ghp_abc_def"} {
+		if _, _, err := api.ParseAPIKey(bad); err != api.ErrUnauthorized {
+			t.Errorf("Expect ErrUnauthorized for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestAPIKeyScope(t *testing.T){
+	key := &api.APIKey{ Scopes: []string{api.ScopePublish} }
+	if !key.HasScope(api.ScopePublish) {
+		t.Errorf("Expect the publish scope")
+	}
+	if key.HasScope("admin") {
+		t.Errorf("Unexpected admin scope")
+	}
+}
+
+func TestAPIKeyRateLimit(t *testing.T){
+	key := &api.APIKey{}
+	if n := key.RateLimit(600); n != 600 {
+		t.Errorf("Expect the default limit 600, got %d", n)
+	}
+	key.Quota = 3000
+	if n := key.RateLimit(600); n != 3000 {
+		t.Errorf("Expect the quota 3000, got %d", n)
+	}
+}
@@ -0,0 +1,408 @@
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EvictLRU = "lru"
+	EvictLFU = "lfu"
+)
+
+var ErrCacheTooLarge = errors.New("ErrCacheTooLarge")
+
+type cacheEntry struct{
+	key        string
+	size       int64
+	lastAccess time.Time
+	hits       int64
+	verified   bool
+}
+
+// AssetCache manages the cached asset files in a blob store.
+// The files are written to a local temporary directory first and then put into the store,
+// and the least recently used (LRU) or the least frequently used (LFU) files
+// will be evicted when the total size exceeds the budget.
+// When the store is shared by multiple instances, the budget is only a best-effort limit of each instance
+type AssetCache struct{
+	store   BlobStore
+	tempDir string
+	maxSize int64 // zero or negative means no limit
+	policy  string
+
+	mux     sync.Mutex
+	entries map[string]*cacheEntry
+	size    int64
+}
+
+// NewAssetCache indexes the blobs in the store, removes the partial files in the temporary directory,
+// and evicts the files until the total size fits the budget
+func NewAssetCache(store BlobStore, tempDir string, maxSize int64, policy string)(c *AssetCache, err error){
+	policy = strings.ToLower(policy)
+	switch policy {
+	case "":
+		policy = EvictLRU
+	case EvictLRU, EvictLFU:
+	default:
+		return nil, fmt.Errorf("Unknown cache eviction policy %q", policy)
+	}
+	c = &AssetCache{
+		store: store,
+		tempDir: tempDir,
+		maxSize: maxSize,
+		policy: policy,
+		entries: make(map[string]*cacheEntry),
+	}
+	if err = os.MkdirAll(tempDir, 0755); err != nil {
+		return
+	}
+	var files []fs.DirEntry
+	if files, err = os.ReadDir(tempDir); err != nil {
+		return
+	}
+	for _, f := range files {
+		if !f.IsDir() && isPartialFile(f.Name()) {
+			name := filepath.Join(tempDir, f.Name())
+			loger.Infof("Removing partial cache file %q", name)
+			if err := os.Remove(name); err != nil {
+				loger.Warnf("Cannot remove partial cache file %q: %v", name, err)
+			}
+		}
+	}
+	var blobs []BlobInfo
+	if blobs, err = store.List(""); err != nil {
+		return
+	}
+	for _, b := range blobs {
+		c.entries[b.Key] = &cacheEntry{
+			key: b.Key,
+			size: b.Size,
+			lastAccess: b.ModTime,
+		}
+		c.size += b.Size
+	}
+	c.mux.Lock()
+	victims := c.evictLocked("")
+	c.mux.Unlock()
+	c.removeEvicted(victims)
+	loger.Infof("Asset cache: %d files, %d bytes used, budget %d bytes, policy %s",
+		len(c.entries), c.size, c.maxSize, c.policy)
+	return
+}
+
+// InitAssetCache creates the asset cache in the blob store of PLUGIN_CACHE_DIR,
+// the budget and the eviction policy are read from env `ASSET_CACHE_SIZE` and `ASSET_CACHE_POLICY`
+func InitAssetCache()(c *AssetCache){
+	maxSize, err := ParseByteSize(os.Getenv("ASSET_CACHE_SIZE"))
+	if err != nil {
+		loger.Fatalf("Cannot parse env ASSET_CACHE_SIZE: %v", err)
+	}
+	store := InitBlobStore(PLUGIN_CACHE_DIR)
+	if c, err = NewAssetCache(store, CACHE_TEMP_DIR, maxSize, os.Getenv("ASSET_CACHE_POLICY")); err != nil {
+		loger.Fatalf("Cannot init asset cache: %v", err)
+	}
+	return
+}
+
+// ParseByteSize parses sizes like `512M`, `2G` or `1048576`. Empty string means zero
+func ParseByteSize(s string)(n int64, err error){
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) == 0 {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	var unit int64 = 1
+	if len(s) > 0 {
+		switch s[len(s) - 1] {
+		case 'K':
+			unit = 1 << 10
+		case 'M':
+			unit = 1 << 20
+		case 'G':
+			unit = 1 << 30
+		case 'T':
+			unit = 1 << 40
+		}
+		if unit != 1 {
+			s = s[:len(s) - 1]
+		}
+	}
+	if n, err = strconv.ParseInt(strings.TrimSpace(s), 10, 64); err != nil {
+		return
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("Negative size %d", n)
+	}
+	return n * unit, nil
+}
+
+// Size returns the total size of the cached files
+func (c *AssetCache)Size()(int64){
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.size
+}
+
+// Open opens the cached file and marks it as accessed.
+// If the file is not indexed, it will be looked up in the store since it may be put by other instances
+func (c *AssetCache)Open(key string)(rc io.ReadSeekCloser, info BlobInfo, err error){
+	c.mux.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		e.lastAccess = time.Now()
+		e.hits++
+	}
+	c.mux.Unlock()
+	if rc, info, err = c.store.Open(key); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			c.forget(key)
+		}
+		return
+	}
+	if !ok {
+		var victims []string
+		c.mux.Lock()
+		if _, ok := c.entries[key]; !ok {
+			c.entries[key] = &cacheEntry{
+				key: key,
+				size: info.Size,
+				lastAccess: time.Now(),
+				hits: 1,
+			}
+			c.size += info.Size
+			victims = c.evictLocked(key)
+		}
+		c.mux.Unlock()
+		c.removeEvicted(victims)
+	}
+	return
+}
+
+// Verified reports whether the cached file is checked with the checksums
+func (c *AssetCache)Verified(key string)(bool){
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	e, ok := c.entries[key]
+	return ok && e.verified
+}
+
+// SetVerified marks the cached file as checked, the mark will be reset when the file is replaced
+func (c *AssetCache)SetVerified(key string){
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.verified = true
+	}
+}
+
+// Remove removes the cached file
+func (c *AssetCache)Remove(key string)(err error){
+	c.forget(key)
+	return c.store.Remove(key)
+}
+
+// Purge removes the cached files which key has the prefix, include the files that put by other instances
+func (c *AssetCache)Purge(prefix string)(n int, err error){
+	var blobs []BlobInfo
+	if blobs, err = c.store.List(prefix); err != nil {
+		return
+	}
+	keys := make(map[string]struct{}, len(blobs))
+	for _, b := range blobs {
+		keys[b.Key] = struct{}{}
+	}
+	c.mux.Lock()
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			keys[k] = struct{}{}
+		}
+	}
+	c.mux.Unlock()
+	for k := range keys {
+		if err = c.Remove(k); err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
+
+func (c *AssetCache)forget(key string){
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if e, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.size -= e.size
+	}
+}
+
+// Create creates a temporary file for the key, it will be put into the store after committed.
+// If the size is larger than the budget, the file will be removed instead when committing.
+// The size can be zero if it's unknown
+func (c *AssetCache)Create(key string, size int64)(w *CacheWriter, err error){
+	var fd *os.File
+	if fd, err = os.CreateTemp(c.tempDir, "." + path.Base(key) + ".*" + partialFileSuffix); err != nil {
+		return
+	}
+	return &CacheWriter{
+		c: c,
+		key: key,
+		fd: fd,
+		discard: c.maxSize > 0 && size > c.maxSize,
+	}, nil
+}
+
+// Put writes the data from the reader to the cache
+func (c *AssetCache)Put(key string, r io.Reader)(err error){
+	var w *CacheWriter
+	if w, err = c.Create(key, 0); err != nil {
+		return
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		w.Abort()
+		return
+	}
+	return w.Commit(false)
+}
+
+func (c *AssetCache)add(key string, size int64, verified bool){
+	c.mux.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.size -= e.size
+	}
+	c.entries[key] = &cacheEntry{
+		key: key,
+		size: size,
+		lastAccess: time.Now(),
+		verified: verified,
+	}
+	c.size += size
+	victims := c.evictLocked(key)
+	c.mux.Unlock()
+	c.removeEvicted(victims)
+}
+
+// less reports whether a should be evicted before b
+func (c *AssetCache)less(a, b *cacheEntry)(bool){
+	if c.policy == EvictLFU && a.hits != b.hits {
+		return a.hits < b.hits
+	}
+	return a.lastAccess.Before(b.lastAccess)
+}
+
+// evictLocked drops the entries until the total size fits the budget, the entry of the key will be kept.
+// It returns the keys of the dropped entries, the files should be removed by removeEvicted after the lock is released
+func (c *AssetCache)evictLocked(keep string)(victims []string){
+	if c.maxSize <= 0 {
+		return
+	}
+	for c.size > c.maxSize {
+		var victim *cacheEntry
+		for _, e := range c.entries {
+			if e.key != keep && (victim == nil || c.less(e, victim)) {
+				victim = e
+			}
+		}
+		if victim == nil {
+			return
+		}
+		delete(c.entries, victim.key)
+		c.size -= victim.size
+		loger.Debugf("Evicting cached asset %q (%d bytes)", victim.key, victim.size)
+		victims = append(victims, victim.key)
+	}
+	return
+}
+
+// removeEvicted removes the files of the evicted entries from the store, it must be called without holding the lock
+func (c *AssetCache)removeEvicted(victims []string){
+	for _, key := range victims {
+		c.mux.Lock()
+		_, ok := c.entries[key]
+		c.mux.Unlock()
+		if ok {
+			// it's cached again after evicted
+			continue
+		}
+		if err := c.store.Remove(key); err != nil {
+			loger.Warnf("Cannot remove cached asset %q: %v", key, err)
+		}
+	}
+}
+
+// CacheWriter writes a temporary file of the cache.
+// Either Commit or Abort must be called after the writes
+type CacheWriter struct{
+	c       *AssetCache
+	key     string
+	fd      *os.File
+	n       int64
+	discard bool
+}
+
+var _ io.Writer = (*CacheWriter)(nil)
+
+func (w *CacheWriter)Write(buf []byte)(n int, err error){
+	n, err = w.fd.Write(buf)
+	w.n += (int64)(n)
+	return
+}
+
+// Name returns the path of the temporary file
+func (w *CacheWriter)Name()(string){
+	return w.fd.Name()
+}
+
+// Commit puts the temporary file into the store.
+// ErrCacheTooLarge will be returned and the file will be removed if the size exceeds the budget,
+// the handles that already opened are still readable.
+// If verified is true, the file is marked as checked with the checksums
+func (w *CacheWriter)Commit(verified bool)(err error){
+	name := w.fd.Name()
+	if w.discard || (w.c.maxSize > 0 && w.n > w.c.maxSize) {
+		w.Abort()
+		return ErrCacheTooLarge
+	}
+	if err = w.fd.Sync(); err != nil {
+		w.Abort()
+		return
+	}
+	if fp, ok := w.c.store.(FilePutter); ok {
+		if err = w.fd.Close(); err != nil {
+			os.Remove(name)
+			return
+		}
+		if err = fp.PutFile(w.key, name); err != nil {
+			os.Remove(name)
+			return
+		}
+	}else{
+		defer w.Abort()
+		if _, err = w.fd.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		if err = w.c.store.Put(w.key, w.fd, w.n); err != nil {
+			return
+		}
+	}
+	w.c.add(w.key, w.n, verified)
+	return
+}
+
+// Abort removes the temporary file
+func (w *CacheWriter)Abort(){
+	name := w.fd.Name()
+	w.fd.Close()
+	os.Remove(name)
+}
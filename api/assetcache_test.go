@@ -0,0 +1,235 @@
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestParseByteSize(t *testing.T){
+	data := []struct{
+		S string
+		N int64
+	}{
+		{ "", 0 },
+		{ "1024", 1024 },
+		{ "2K", 2 << 10 },
+		{ "512M", 512 << 20 },
+		{ "2g", 2 << 30 },
+		{ "3GiB", 3 << 30 },
+		{ "1TB", 1 << 40 },
+	}
+	for _, d := range data {
+		n, err := api.ParseByteSize(d.S)
+		if err != nil {
+			t.Errorf("Cannot parse %q: %v", d.S, err)
+			continue
+		}
+		if n != d.N {
+			t.Errorf("ParseByteSize(%q) = %d, expect %d", d.S, n, d.N)
+		}
+	}
+	for _, s := range []string{"abc", "-1", "1X"} {
+		if _, err := api.ParseByteSize(s); err == nil {
+			t.Errorf("Expect error when parsing %q", s)
+		}
+	}
+}
+
+// newTestCache creates a cache with a local blob store at `{root}/store` and the temporary directory at `{root}/tmp`
+func newTestCache(t *testing.T, root string, maxSize int64, policy string)(c *api.AssetCache){
+	store, err := api.NewLocalBlobStore(filepath.Join(root, "store"))
+	if err != nil {
+		t.Fatalf("Cannot create blob store: %v", err)
+	}
+	if c, err = api.NewAssetCache(store, filepath.Join(root, "tmp"), maxSize, policy); err != nil {
+		t.Fatalf("Cannot create cache: %v", err)
+	}
+	return
+}
+
+func putCache(t *testing.T, c *api.AssetCache, key string, size int){
+	if err := c.Put(key, strings.NewReader(strings.Repeat("x", size))); err != nil {
+		t.Fatalf("Cannot put %q: %v", key, err)
+	}
+}
+
+func isCached(c *api.AssetCache, key string)(bool){
+	fd, _, err := c.Open(key)
+	if err != nil {
+		return false
+	}
+	fd.Close()
+	return true
+}
+
+func TestAssetCacheLRU(t *testing.T){
+	root := t.TempDir()
+	c := newTestCache(t, root, 30, api.EvictLRU)
+	putCache(t, c, "a/1", 10)
+	putCache(t, c, "b/1", 10)
+	putCache(t, c, "c/1", 10)
+	if !isCached(c, "a/1") { // a is used recently
+		t.Fatalf("a/1 should be cached")
+	}
+	putCache(t, c, "d/1", 10)
+	if isCached(c, "b/1") {
+		t.Errorf("b/1 should be evicted")
+	}
+	for _, k := range []string{"a/1", "c/1", "d/1"} {
+		if !isCached(c, k) {
+			t.Errorf("%s should be cached", k)
+		}
+	}
+	if s := c.Size(); s != 30 {
+		t.Errorf("Cache size is %d, expect 30", s)
+	}
+	if _, err := os.Stat(filepath.Join(root, "store", "b", "1")); !os.IsNotExist(err) {
+		t.Errorf("Evicted file should be removed, got %v", err)
+	}
+}
+
+func TestAssetCacheLFU(t *testing.T){
+	c := newTestCache(t, t.TempDir(), 30, api.EvictLFU)
+	putCache(t, c, "a", 10)
+	putCache(t, c, "b", 10)
+	putCache(t, c, "c", 10)
+	isCached(c, "a")
+	isCached(c, "a")
+	isCached(c, "b")
+	isCached(c, "c")
+	isCached(c, "c")
+	putCache(t, c, "d", 10)
+	if isCached(c, "b") {
+		t.Errorf("b should be evicted")
+	}
+	if !isCached(c, "a") || !isCached(c, "c") || !isCached(c, "d") {
+		t.Errorf("a, c and d should be cached")
+	}
+}
+
+func TestAssetCacheCleanup(t *testing.T){
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "store", "p"), 0755)
+	os.MkdirAll(filepath.Join(root, "tmp"), 0755)
+	partials := []string{
+		filepath.Join(root, "store", "p", ".asset.mcdr.123.tmp"),
+		filepath.Join(root, "tmp", ".asset.mcdr.456.tmp"),
+	}
+	for _, p := range partials {
+		os.WriteFile(p, []byte("partial"), 0644)
+	}
+	os.WriteFile(filepath.Join(root, "store", "p", "asset.mcdr"), []byte("complete"), 0644)
+	c := newTestCache(t, root, 0, "")
+	for _, p := range partials {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("Partial file %q should be removed, got %v", p, err)
+		}
+	}
+	if !isCached(c, "p/asset.mcdr") {
+		t.Errorf("Complete file should be indexed")
+	}
+	if s := c.Size(); s != 8 {
+		t.Errorf("Cache size is %d, expect 8", s)
+	}
+	if err := c.Put("big", strings.NewReader("0123456789")); err != nil {
+		t.Errorf("Unlimited cache should accept any size: %v", err)
+	}
+}
+
+func TestAssetCacheTooLarge(t *testing.T){
+	c := newTestCache(t, t.TempDir(), 5, api.EvictLRU)
+	w, err := c.Create("a", 10)
+	if err != nil {
+		t.Fatalf("Cannot create %q: %v", "a", err)
+	}
+	if err := w.Commit(false); err != api.ErrCacheTooLarge {
+		t.Errorf("Expect ErrCacheTooLarge, got %v", err)
+	}
+	if err := c.Put("a", strings.NewReader("0123456789")); err != api.ErrCacheTooLarge {
+		t.Errorf("Expect ErrCacheTooLarge, got %v", err)
+	}
+	if s := c.Size(); s != 0 {
+		t.Errorf("Cache size is %d, expect 0", s)
+	}
+}
+
+func TestAssetCachePurge(t *testing.T){
+	root := t.TempDir()
+	c := newTestCache(t, root, 0, api.EvictLRU)
+	putCache(t, c, "a/release/1.0.0/a.mcdr", 10)
+	putCache(t, c, "a/release/1.1.0/a.mcdr", 10)
+	putCache(t, c, "ab/release/1.0.0/ab.mcdr", 10)
+	n, err := c.Purge("a/")
+	if err != nil {
+		t.Fatalf("Cannot purge: %v", err)
+	}
+	if n != 2 || c.Size() != 10 {
+		t.Errorf("Purged %d files and %d bytes left, expect 2 files and 10 bytes", n, c.Size())
+	}
+	if !isCached(c, "ab/release/1.0.0/ab.mcdr") {
+		t.Errorf("ab should not be purged")
+	}
+}
+
+// blockingStore blocks the removes until unblock is closed
+type blockingStore struct{
+	api.BlobStore
+	removing chan string
+	unblock chan struct{}
+}
+
+func (s *blockingStore)Remove(key string)(error){
+	s.removing <- key
+	<-s.unblock
+	return s.BlobStore.Remove(key)
+}
+
+func TestAssetCacheEvictUnlocked(t *testing.T){
+	root := t.TempDir()
+	local, err := api.NewLocalBlobStore(filepath.Join(root, "store"))
+	if err != nil {
+		t.Fatalf("Cannot create blob store: %v", err)
+	}
+	store := &blockingStore{
+		BlobStore: local,
+		removing: make(chan string, 1),
+		unblock: make(chan struct{}),
+	}
+	c, err := api.NewAssetCache(store, filepath.Join(root, "tmp"), 20, api.EvictLRU)
+	if err != nil {
+		t.Fatalf("Cannot create cache: %v", err)
+	}
+	putCache(t, c, "a", 10)
+	putCache(t, c, "b", 10)
+	done := make(chan struct{})
+	go func(){
+		defer close(done)
+		putCache(t, c, "c", 10)
+	}()
+	if key := <-store.removing; key != "a" {
+		t.Errorf("Evicting %q, expect a", key)
+	}
+	// the cache must be usable while the evicted file is being removed
+	sized := make(chan int64, 1)
+	go func(){
+		sized <- c.Size()
+	}()
+	select {
+	case s := <-sized:
+		if s != 20 {
+			t.Errorf("Cache size is %d, expect 20", s)
+		}
+	case <-time.After(time.Second * 3):
+		t.Errorf("The cache is locked while removing the evicted file")
+	}
+	close(store.unblock)
+	<-done
+	if isCached(c, "a") || !isCached(c, "b") || !isCached(c, "c") {
+		t.Errorf("a should be evicted, b and c should be cached")
+	}
+}
@@ -0,0 +1,123 @@
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// BatchMaxSize is the max number of the plugins in a batch lookup
+const BatchMaxSize = 100
+
+// BatchQuery is an entry of the batch lookup,
+// it can be unmarshaled from a plugin id, or an object with the id and the installed version
+type BatchQuery struct{
+	Id      string `json:"id"`
+	Version string `json:"version,omitempty"`
+}
+
+func (q *BatchQuery)UnmarshalJSON(data []byte)(err error){
+	if len(data) > 0 && data[0] == '"' {
+		q.Version = ""
+		return json.Unmarshal(data, &q.Id)
+	}
+	type query BatchQuery
+	return json.Unmarshal(data, (*query)(q))
+}
+
+type BatchOpt struct{
+	Plugins  []BatchQuery `json:"plugins"`
+	Releases bool         `json:"releases,omitempty"` // whether to include all releases of the plugins
+}
+
+// BatchResult is the result of a BatchQuery, Error and Message are set if the lookup is failed
+type BatchResult struct{
+	Id            string           `json:"id"`
+	Status        string           `json:"status"`
+	Error         string           `json:"error,omitempty"`
+	Message       string           `json:"message,omitempty"`
+	Info          *PluginInfo      `json:"info,omitempty"`
+	LatestRelease *PluginRelease   `json:"latestRelease"`
+	Release       *PluginRelease   `json:"release,omitempty"` // the release of the requested version
+	Releases      []*PluginRelease `json:"releases,omitempty"`
+}
+
+func (r *BatchResult)setErr(name string, err error){
+	r.Status = "error"
+	r.Error = name
+	r.Message = err.Error()
+}
+
+// LatestStableRelease returns the newest stable release, or nil if there is none
+func LatestStableRelease(releases []*PluginRelease)(latest *PluginRelease){
+	for _, r := range releases {
+		if r.Stable && (latest == nil || latest.Tag.Less(r.Tag)) {
+			latest = r
+		}
+	}
+	return
+}
+
+func (opt BatchOpt)Check()(error){
+	if len(opt.Plugins) == 0 {
+		return errors.New("The plugin list is empty")
+	}
+	if len(opt.Plugins) > BatchMaxSize {
+		return fmt.Errorf("Too many plugins, the limit is %d", BatchMaxSize)
+	}
+	return nil
+}
+
+// GetPluginBatch looks up the plugins in the order of the queries.
+// The failure of a query is reported in its result instead of failing the others
+func GetPluginBatch(ins API, opt BatchOpt)(results []*BatchResult){
+	results = make([]*BatchResult, len(opt.Plugins))
+	for i, q := range opt.Plugins {
+		res := &BatchResult{
+			Id: q.Id,
+			Status: "ok",
+		}
+		results[i] = res
+		var tag Version
+		if len(q.Version) > 0 {
+			var err error
+			if tag, err = VersionFromString(q.Version); err != nil {
+				res.setErr("VersionFormatErr", err)
+				continue
+			}
+		}
+		info, err := ins.GetPluginInfo(q.Id, "latest")
+		if err != nil {
+			if err == ErrNotFound {
+				res.setErr("NotFound", err)
+			}else{
+				res.setErr("ApiErr", err)
+			}
+			continue
+		}
+		releases, err := ins.GetPluginReleases(q.Id)
+		if err != nil {
+			res.setErr("ApiErr", err)
+			continue
+		}
+		if len(q.Version) > 0 {
+			for _, r := range releases {
+				if r.Tag.Equal(tag) {
+					res.Release = r
+					break
+				}
+			}
+			if res.Release == nil {
+				res.setErr("NotFound", fmt.Errorf("Release %s of %s is not found", q.Version, q.Id))
+				continue
+			}
+		}
+		res.Info = info
+		res.LatestRelease = LatestStableRelease(releases)
+		if opt.Releases {
+			res.Releases = releases
+		}
+	}
+	return
+}
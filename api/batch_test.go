@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+type batchAPI struct{
+	api.API
+	releases map[string][]*api.PluginRelease
+}
+
+func (b *batchAPI)GetPluginInfo(id string, version string)(*api.PluginInfo, error){
+	if _, ok := b.releases[id]; !ok {
+		return nil, api.ErrNotFound
+	}
+	return &api.PluginInfo{ Id: id }, nil
+}
+
+func (b *batchAPI)GetPluginReleases(id string)([]*api.PluginRelease, error){
+	return b.releases[id], nil
+}
+
+func TestGetPluginBatch(t *testing.T){
+	release := func(tag string, stable bool)(*api.PluginRelease){
+		v, err := api.VersionFromString(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &api.PluginRelease{ Tag: v, Stable: stable }
+	}
+	ins := &batchAPI{
+		releases: map[string][]*api.PluginRelease{
+			"hello": { release("1.0.0", true), release("1.2.0", true), release("2.0.0", false) },
+			"beta": { release("0.1.0", false) },
+		},
+	}
+	var opt api.BatchOpt
+	if err := json.Unmarshal(([]byte)(`{"plugins":["hello",{"id":"hello","version":"1.0.0"},{"id":"hello","version":"0.9.0"},` +
+		`{"id":"hello","version":"x.y"},"beta","missing"],"releases":true}`), &opt); err != nil {
+		t.Fatalf("Cannot unmarshal the batch: %v", err)
+	}
+	if err := opt.Check(); err != nil {
+		t.Fatalf("Unexpected check error: %v", err)
+	}
+	results := api.GetPluginBatch(ins, opt)
+	if len(results) != 6 {
+		t.Fatalf("Expect 6 results, got %d", len(results))
+	}
+	type expect struct{
+		status, err, latest, release string
+		releases int
+	}
+	for i, want := range []expect{
+		{ "ok", "", "1.2.0", "", 3 },
+		{ "ok", "", "1.2.0", "1.0.0", 3 },
+		{ "error", "NotFound", "", "", 0 },
+		{ "error", "VersionFormatErr", "", "", 0 },
+		{ "ok", "", "", "", 1 },
+		{ "error", "NotFound", "", "", 0 },
+	} {
+		res := results[i]
+		got := expect{ status: res.Status, err: res.Error, releases: len(res.Releases) }
+		if res.LatestRelease != nil {
+			got.latest = res.LatestRelease.Tag.String()
+		}
+		if res.Release != nil {
+			got.release = res.Release.Tag.String()
+		}
+		if got != want {
+			t.Errorf("Unexpected result %d of %s: %+v, expect %+v", i, res.Id, got, want)
+		}
+	}
+
+	if err := (api.BatchOpt{}).Check(); err == nil {
+		t.Errorf("Expect an error for the empty batch")
+	}
+	if err := (api.BatchOpt{ Plugins: make([]api.BatchQuery, api.BatchMaxSize + 1) }).Check(); err == nil {
+		t.Errorf("Expect an error for the oversized batch")
+	}
+}
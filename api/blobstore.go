@@ -0,0 +1,247 @@
+
+package api
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type BlobInfo struct{
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores files by slash separated keys, e.g. `{id}/release/{tag}/{filename}`
+type BlobStore interface{
+	// Open opens the blob for reading, fs.ErrNotExist will be returned if the blob does not exist
+	Open(key string)(rc io.ReadSeekCloser, info BlobInfo, err error)
+	// Stat returns the blob info, fs.ErrNotExist will be returned if the blob does not exist
+	Stat(key string)(info BlobInfo, err error)
+	// Put writes the blob atomically, the size can be negative if it's unknown
+	Put(key string, r io.Reader, size int64)(err error)
+	// Remove removes the blob, it will not return an error if the blob does not exist
+	Remove(key string)(err error)
+	// List returns all blobs whose key has the prefix
+	List(prefix string)(infos []BlobInfo, err error)
+}
+
+// FilePutter is implemented by the blob stores that can take over a local file without copying
+type FilePutter interface{
+	// PutFile moves the file to the blob, the file should not be used after that
+	PutFile(key string, name string)(err error)
+}
+
+// InitBlobStore creates the blob store for the directory under BASE_DIR.
+// The backend is selected by env `BLOB_STORE`, which can be `local` (default) or `s3`.
+// For the s3 backend, the directory path relative to BASE_DIR is used as the key prefix
+func InitBlobStore(dir string)(s BlobStore){
+	switch backend := os.Getenv("BLOB_STORE"); backend {
+	case "", "local":
+		ls, err := NewLocalBlobStore(dir)
+		if err != nil {
+			loger.Fatalf("Cannot init blob store at %q: %v", dir, err)
+		}
+		return ls
+	case "s3":
+		cfg := S3ConfigFromEnv()
+		rel, err := filepath.Rel(BASE_DIR, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			loger.Fatalf("Blob store dir %q is not under %q", dir, BASE_DIR)
+		}
+		cfg.Prefix = path.Join(cfg.Prefix, filepath.ToSlash(rel))
+		ss, err := NewS3BlobStore(cfg)
+		if err != nil {
+			loger.Fatalf("Cannot init s3 blob store: %v", err)
+		}
+		loger.Infof("Using s3 blob store %s/%s/%s", cfg.Endpoint, cfg.Bucket, cfg.Prefix)
+		return ss
+	default:
+		loger.Fatalf("Unknown blob store backend %q", backend)
+	}
+	return
+}
+
+const partialFileSuffix = ".tmp"
+
+// LocalBlobStore stores the blobs as the files under the root directory
+type LocalBlobStore struct{
+	root string
+}
+
+var (
+	_ BlobStore = (*LocalBlobStore)(nil)
+	_ FilePutter = (*LocalBlobStore)(nil)
+)
+
+// NewLocalBlobStore creates the root directory if not exists,
+// and removes the partial files that left by unfinished writes
+func NewLocalBlobStore(root string)(s *LocalBlobStore, err error){
+	if err = os.MkdirAll(root, 0755); err != nil {
+		return
+	}
+	s = &LocalBlobStore{
+		root: root,
+	}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error)(error){
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isPartialFile(d.Name()) {
+			loger.Infof("Removing partial file %q", path)
+			if err := os.Remove(path); err != nil {
+				loger.Warnf("Cannot remove partial file %q: %v", path, err)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func isPartialFile(name string)(bool){
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, partialFileSuffix)
+}
+
+func (s *LocalBlobStore)Root()(string){
+	return s.root
+}
+
+func (s *LocalBlobStore)path(key string)(string){
+	// clean the key as an absolute path to prevent it goes out of the root
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/" + key)))
+}
+
+func fileBlobInfo(key string, stat fs.FileInfo)(BlobInfo){
+	return BlobInfo{
+		Key: key,
+		Size: stat.Size(),
+		ModTime: stat.ModTime(),
+	}
+}
+
+func (s *LocalBlobStore)Open(key string)(rc io.ReadSeekCloser, info BlobInfo, err error){
+	var fd *os.File
+	if fd, err = os.Open(s.path(key)); err != nil {
+		return
+	}
+	var stat fs.FileInfo
+	if stat, err = fd.Stat(); err != nil {
+		fd.Close()
+		return
+	}
+	if stat.IsDir() {
+		fd.Close()
+		err = fs.ErrNotExist
+		return
+	}
+	return fd, fileBlobInfo(key, stat), nil
+}
+
+func (s *LocalBlobStore)Stat(key string)(info BlobInfo, err error){
+	var stat fs.FileInfo
+	if stat, err = os.Stat(s.path(key)); err != nil {
+		return
+	}
+	if stat.IsDir() {
+		err = fs.ErrNotExist
+		return
+	}
+	return fileBlobInfo(key, stat), nil
+}
+
+func (s *LocalBlobStore)Put(key string, r io.Reader, size int64)(err error){
+	target := s.path(key)
+	dir, name := filepath.Split(target)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	var fd *os.File
+	if fd, err = os.CreateTemp(dir, "." + name + ".*" + partialFileSuffix); err != nil {
+		return
+	}
+	tmpName := fd.Name()
+	defer func(){
+		if err != nil {
+			os.Remove(tmpName)
+		}
+	}()
+	if _, err = io.Copy(fd, r); err != nil {
+		fd.Close()
+		return
+	}
+	if err = fd.Sync(); err != nil {
+		fd.Close()
+		return
+	}
+	if err = fd.Chmod(0444); err != nil {
+		fd.Close()
+		return
+	}
+	if err = fd.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmpName, target)
+}
+
+// PutFile renames the file to the blob path, or copies it if they are not on the same device
+func (s *LocalBlobStore)PutFile(key string, name string)(err error){
+	target := s.path(key)
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return
+	}
+	if err = os.Chmod(name, 0444); err != nil {
+		return
+	}
+	if err = os.Rename(name, target); err == nil {
+		return
+	}
+	var fd *os.File
+	if fd, err = os.Open(name); err != nil {
+		return
+	}
+	defer fd.Close()
+	if err = s.Put(key, fd, -1); err != nil {
+		return
+	}
+	os.Remove(name)
+	return
+}
+
+func (s *LocalBlobStore)Remove(key string)(err error){
+	if err = os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	return nil
+}
+
+func (s *LocalBlobStore)List(prefix string)(infos []BlobInfo, err error){
+	err = filepath.WalkDir(s.root, func(name string, d fs.DirEntry, err error)(error){
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isPartialFile(d.Name()) {
+			return nil
+		}
+		key, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		key = filepath.ToSlash(key)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, fileBlobInfo(key, stat))
+		return nil
+	})
+	return
+}
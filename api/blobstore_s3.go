@@ -0,0 +1,493 @@
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config is the config of a S3 compatible storage, the buckets are accessed by path-style URLs
+type S3Config struct{
+	Endpoint  string // e.g. `https://s3.us-east-1.amazonaws.com` or `http://minio:9000`
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // the prefix of the object keys
+}
+
+// S3ConfigFromEnv reads the config from env `S3_ENDPOINT`, `S3_REGION`, `S3_BUCKET`,
+// `S3_ACCESS_KEY`, `S3_SECRET_KEY` and `S3_PREFIX`
+func S3ConfigFromEnv()(cfg S3Config){
+	cfg = S3Config{
+		Endpoint: os.Getenv("S3_ENDPOINT"),
+		Region: os.Getenv("S3_REGION"),
+		Bucket: os.Getenv("S3_BUCKET"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		Prefix: os.Getenv("S3_PREFIX"),
+	}
+	if len(cfg.Region) == 0 {
+		cfg.Region = "us-east-1"
+	}
+	return
+}
+
+type S3Error struct{
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *S3Error)Error()(string){
+	return fmt.Sprintf("S3 error %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+const (
+	// s3RequestTimeout is the timeout of the requests that transfer no object or a bounded range of it
+	s3RequestTimeout = time.Second * 30
+	// s3TransferTimeout is the timeout of the requests that transfer a whole object
+	s3TransferTimeout = time.Minute * 30
+)
+
+// S3BlobStore stores the blobs in a S3 compatible storage
+type S3BlobStore struct{
+	cfg      S3Config
+	endpoint *url.URL
+	cli      *http.Client
+}
+
+var _ BlobStore = (*S3BlobStore)(nil)
+
+func NewS3BlobStore(cfg S3Config)(s *S3BlobStore, err error){
+	if len(cfg.Bucket) == 0 {
+		return nil, errors.New("S3 bucket is empty")
+	}
+	var endpoint *url.URL
+	if endpoint, err = url.Parse(cfg.Endpoint); err != nil {
+		return
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("Unsupported S3 endpoint %q", cfg.Endpoint)
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	if len(cfg.Region) == 0 {
+		cfg.Region = "us-east-1"
+	}
+	return &S3BlobStore{
+		cfg: cfg,
+		endpoint: endpoint,
+		cli: &http.Client{
+			// no total timeout since the objects may be large
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				ResponseHeaderTimeout: time.Second * 30,
+				IdleConnTimeout: time.Second * 90,
+			},
+		},
+	}, nil
+}
+
+func (s *S3BlobStore)objectKey(key string)(string){
+	key = strings.TrimPrefix(path.Clean("/" + key), "/")
+	if len(s.cfg.Prefix) == 0 {
+		return key
+	}
+	return s.cfg.Prefix + "/" + key
+}
+
+// s3Escape encodes the string as the URI encoding of AWS signature version 4
+func s3Escape(s string, escapeSlash bool)(string){
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+		}else{
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query encodes the query sorted by keys, as the canonical query string of AWS signature version 4
+func s3Query(query url.Values)(string){
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), query[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, s3Escape(k, true) + "=" + s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string)([]byte){
+	h := hmac.New(sha256.New, key)
+	h.Write(([]byte)(data))
+	return h.Sum(nil)
+}
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// SignV4 signs the request with AWS signature version 4.
+// The payload hash is read from header `X-Amz-Content-Sha256`, it will be set to `UNSIGNED-PAYLOAD` if not exists.
+// The URL path and query must be already encoded as the canonical form
+func SignV4(req *http.Request, accessKey, secretKey, region, service string, now time.Time){
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if len(payloadHash) == 0 {
+		payloadHash = unsignedPayload
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+	headers := map[string]string{
+		"host": host,
+	}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") || k == "range" || k == "content-type" || k == "content-md5" {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	uri := req.URL.EscapedPath()
+	if len(uri) == 0 {
+		uri = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	crHash := sha256.Sum256(([]byte)(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256(([]byte)("AWS4" + secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=" + accessKey + "/" + scope +
+		", SignedHeaders=" + signedHeaders + ", Signature=" + signature)
+}
+
+// do sends a signed request to the object, or the bucket if the key is empty.
+// Responses with status code not in 2xx will be converted to an error
+func (s *S3BlobStore)do(ctx context.Context, method string, key string, query url.Values, header http.Header,
+	body io.Reader, size int64)(res *http.Response, err error){
+	u := *s.endpoint
+	u.Path = path.Join("/", u.Path, s.cfg.Bucket)
+	u.RawPath = path.Join("/", u.EscapedPath(), s3Escape(s.cfg.Bucket, true))
+	if len(key) > 0 {
+		u.Path += "/" + key
+		u.RawPath += "/" + s3Escape(key, false)
+	}
+	u.RawQuery = s3Query(query)
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, method, u.String(), body); err != nil {
+		return
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", "PluginWebPoint-App")
+	SignV4(req, s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, "s3", time.Now())
+
+	if res, err = s.cli.Do(req); err != nil {
+		return
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusNotFound {
+			return nil, fs.ErrNotExist
+		}
+		e := &S3Error{ Status: res.StatusCode }
+		if data, err := io.ReadAll(io.LimitReader(res.Body, 64 * 1024)); err == nil {
+			xml.Unmarshal(data, e)
+		}
+		return nil, e
+	}
+	return
+}
+
+func s3BlobInfo(key string, res *http.Response)(info BlobInfo){
+	info.Key = key
+	info.Size = res.ContentLength
+	if cr := res.Header.Get("Content-Range"); len(cr) > 0 {
+		// bytes start-end/size
+		if i := strings.LastIndexByte(cr, '/'); i >= 0 {
+			if n, err := strconv.ParseInt(cr[i + 1:], 10, 64); err == nil {
+				info.Size = n
+			}
+		}
+	}
+	info.ModTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	return
+}
+
+func (s *S3BlobStore)Stat(key string)(info BlobInfo, err error){
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+	var res *http.Response
+	if res, err = s.do(ctx, "HEAD", s.objectKey(key), nil, nil, nil, 0); err != nil {
+		return
+	}
+	res.Body.Close()
+	return s3BlobInfo(key, res), nil
+}
+
+func (s *S3BlobStore)Open(key string)(rc io.ReadSeekCloser, info BlobInfo, err error){
+	if info, err = s.Stat(key); err != nil {
+		return
+	}
+	return &s3Reader{
+		s: s,
+		key: s.objectKey(key),
+		size: info.Size,
+	}, info, nil
+}
+
+func (s *S3BlobStore)Put(key string, r io.Reader, size int64)(err error){
+	if size < 0 {
+		var data []byte
+		if data, err = io.ReadAll(r); err != nil {
+			return
+		}
+		r, size = bytes.NewReader(data), (int64)(len(data))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s3TransferTimeout)
+	defer cancel()
+	var res *http.Response
+	if res, err = s.do(ctx, "PUT", s.objectKey(key), nil, nil, r, size); err != nil {
+		return
+	}
+	res.Body.Close()
+	return
+}
+
+func (s *S3BlobStore)Remove(key string)(err error){
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+	var res *http.Response
+	if res, err = s.do(ctx, "DELETE", s.objectKey(key), nil, nil, nil, 0); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return
+	}
+	res.Body.Close()
+	return
+}
+
+func (s *S3BlobStore)List(prefix string)(infos []BlobInfo, err error){
+	var payload struct{
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+		Contents []struct{
+			Key          string    `xml:"Key"`
+			LastModified time.Time `xml:"LastModified"`
+			Size         int64     `xml:"Size"`
+		} `xml:"Contents"`
+	}
+	keyPrefix := ""
+	if len(s.cfg.Prefix) > 0 {
+		keyPrefix = s.cfg.Prefix + "/"
+	}
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix": {keyPrefix + prefix},
+	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+		res, err := s.do(ctx, "GET", "", query, nil, nil, 0)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		payload.IsTruncated, payload.NextContinuationToken, payload.Contents = false, "", nil
+		err = xml.NewDecoder(res.Body).Decode(&payload)
+		res.Body.Close()
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range payload.Contents {
+			infos = append(infos, BlobInfo{
+				Key: strings.TrimPrefix(c.Key, keyPrefix),
+				Size: c.Size,
+				ModTime: c.LastModified,
+			})
+		}
+		if !payload.IsTruncated || len(payload.NextContinuationToken) == 0 {
+			break
+		}
+		query.Set("continuation-token", payload.NextContinuationToken)
+	}
+	return
+}
+
+// s3Reader reads the object by range requests, the request is sent lazily when reading.
+// The sequential reads share a request from the current position to the end,
+// and each ReadAt sends a request of the bounded range, so they can be called concurrently
+type s3Reader struct{
+	s      *S3BlobStore
+	key    string
+	size   int64
+	pos    int64
+	body   io.ReadCloser
+	cancel context.CancelFunc
+}
+
+var _ io.ReaderAt = (*s3Reader)(nil)
+
+func (r *s3Reader)Read(buf []byte)(n int, err error){
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		header := http.Header{}
+		if r.pos > 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", r.pos))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s3TransferTimeout)
+		var res *http.Response
+		if res, err = r.s.do(ctx, "GET", r.key, nil, header, nil, 0); err != nil {
+			cancel()
+			return
+		}
+		if r.pos > 0 && res.StatusCode != http.StatusPartialContent {
+			res.Body.Close()
+			cancel()
+			return 0, &StatusCodeErr{ Code: res.StatusCode }
+		}
+		r.body, r.cancel = res.Body, cancel
+	}
+	n, err = r.body.Read(buf)
+	r.pos += (int64)(n)
+	if err == io.EOF && r.pos < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// ReadAt reads the range [off, off + len(buf)) of the object, which is clipped to the size of the object
+func (r *s3Reader)ReadAt(buf []byte, off int64)(n int, err error){
+	if off < 0 {
+		return 0, errors.New("s3Reader.ReadAt: negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	end := off + (int64)(len(buf))
+	if end > r.size {
+		end = r.size
+	}
+	if end == off {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, end - 1))
+	var res *http.Response
+	if res, err = r.s.do(ctx, "GET", r.key, nil, header, nil, 0); err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent {
+		return 0, &StatusCodeErr{ Code: res.StatusCode }
+	}
+	if n, err = io.ReadFull(res.Body, buf[:end - off]); err != nil {
+		return
+	}
+	if n < len(buf) {
+		err = io.EOF
+	}
+	return
+}
+
+func (r *s3Reader)Seek(offset int64, whence int)(int64, error){
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, errors.New("s3Reader.Seek: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("s3Reader.Seek: negative position")
+	}
+	if pos != r.pos {
+		r.closeBody()
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *s3Reader)closeBody(){
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+func (r *s3Reader)Close()(error){
+	r.closeBody()
+	return nil
+}
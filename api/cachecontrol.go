@@ -0,0 +1,60 @@
+
+package api
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// The Cache-Control policies of the routes
+const (
+	// CacheNoStore is used by the authenticated and the mutable routes
+	CacheNoStore = "no-store"
+	// CacheRevalidate is the default policy, the caches must revalidate the response every time
+	CacheRevalidate = "no-cache"
+	// CacheList is used by the plugin lists and the feeds, which change when any plugin is updated
+	CacheList = "public, max-age=60, stale-while-revalidate=600"
+	// CachePlugin is used by the information, the README and the releases of a plugin
+	CachePlugin = "public, max-age=60, stale-while-revalidate=3600"
+	// CacheRelease is used by a release, it's only changed by the admins
+	CacheRelease = "public, max-age=600, stale-while-revalidate=86400"
+	// CacheAsset is used by the release assets, which are verified by their checksums
+	CacheAsset = "public, max-age=86400"
+	// CacheStatic is used by the documents that only change on deploy, e.g. the OpenAPI document
+	CacheStatic = "public, max-age=3600, stale-while-revalidate=86400"
+)
+
+type noStoreOnErrorWriter struct{
+	http.ResponseWriter
+}
+
+// NoStoreOnError wraps the ResponseWriter, so the responses with an error status code are marked as `no-store`,
+// whatever the Cache-Control policy of the route is
+func NoStoreOnError(w http.ResponseWriter)(http.ResponseWriter){
+	return &noStoreOnErrorWriter{ w }
+}
+
+func (w *noStoreOnErrorWriter)WriteHeader(code int){
+	if code >= 400 {
+		w.Header().Set("Cache-Control", CacheNoStore)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *noStoreOnErrorWriter)Flush(){
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *noStoreOnErrorWriter)Hijack()(net.Conn, *bufio.ReadWriter, error){
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *noStoreOnErrorWriter)Unwrap()(http.ResponseWriter){
+	return w.ResponseWriter
+}
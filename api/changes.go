@@ -0,0 +1,126 @@
+
+package api
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	ChangeKindPlugin  = "plugin"
+	ChangeKindRelease = "release"
+
+	ChangeOpUpsert = "upsert"
+	ChangeOpDelete = "delete"
+)
+
+const (
+	ChangesDefaultLimit = 100
+	ChangesMaxLimit = 1000
+)
+
+// CatalogueChange is an entry of the change log for mirroring the catalogue.
+// Only the latest change of each plugin or release is kept, and the deletion of a plugin implies all its releases are deleted
+type CatalogueChange struct{
+	Seq    int64     `json:"seq"`
+	Kind   string    `json:"kind"`
+	Op     string    `json:"op"`
+	Plugin string    `json:"plugin"`
+	Tag    string    `json:"tag,omitempty"`
+	Time   time.Time `json:"time"`
+	// Hash is the digest of the public fields, which is used to detect the changes
+	Hash   string    `json:"-"`
+}
+
+// ChangeState is the state of a plugin or a release, the key of the plugin itself is an empty tag
+type ChangeState struct{
+	Op   string
+	Hash string
+}
+
+// DiffChanges compares the recorded states of the plugin with the current states, and returns the changes to record.
+// The current states of the disabled plugin and releases should be deleted ones.
+// The plugin change comes first, and the release changes are sorted by tag
+func DiffChanges(plugin string, recorded map[string]ChangeState, current map[string]ChangeState)(changes []*CatalogueChange){
+	old, recordedOk := recorded[""]
+	cur, ok := current[""]
+	if !ok || cur.Op == ChangeOpDelete {
+		if recordedOk && old.Op != ChangeOpDelete {
+			changes = append(changes, &CatalogueChange{
+				Kind: ChangeKindPlugin,
+				Op: ChangeOpDelete,
+				Plugin: plugin,
+			})
+		}
+		return
+	}
+	if !recordedOk || old != cur {
+		changes = append(changes, &CatalogueChange{
+			Kind: ChangeKindPlugin,
+			Op: ChangeOpUpsert,
+			Plugin: plugin,
+			Hash: cur.Hash,
+		})
+	}
+
+	tags := make([]string, 0, len(current) + len(recorded))
+	for tag := range current {
+		if len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	for tag := range recorded {
+		if _, ok := current[tag]; !ok && len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		old, recordedOk := recorded[tag]
+		cur, ok := current[tag]
+		if !ok || cur.Op == ChangeOpDelete {
+			if recordedOk && old.Op != ChangeOpDelete {
+				changes = append(changes, &CatalogueChange{
+					Kind: ChangeKindRelease,
+					Op: ChangeOpDelete,
+					Plugin: plugin,
+					Tag: tag,
+				})
+			}
+			continue
+		}
+		if !recordedOk || old != cur {
+			changes = append(changes, &CatalogueChange{
+				Kind: ChangeKindRelease,
+				Op: ChangeOpUpsert,
+				Plugin: plugin,
+				Tag: tag,
+				Hash: cur.Hash,
+			})
+		}
+	}
+	return
+}
+
+// ChangesPage is a page of the change log, the next page starts after Next
+type ChangesPage struct{
+	Changes []*CatalogueChange `json:"changes"`
+	Next    int64              `json:"next"`
+	More    bool               `json:"more"`
+}
+
+// NewChangesPage creates the page of the changes which are queried after since with the limit
+func NewChangesPage(since int64, changes []*CatalogueChange, limit int)(*ChangesPage){
+	p := &ChangesPage{
+		Changes: changes,
+		Next: since,
+		More: len(changes) >= limit,
+	}
+	if p.Changes == nil {
+		p.Changes = []*CatalogueChange{}
+	}
+	if len(changes) > 0 {
+		p.Next = changes[len(changes) - 1].Seq
+	}
+	return p
+}
@@ -0,0 +1,101 @@
+package api_test
+
+import (
+	"fmt"
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func formatChanges(changes []*api.CatalogueChange)(s []string){
+	for _, c := range changes {
+		s = append(s, fmt.Sprintf("%s %s %s %s", c.Op, c.Kind, c.Tag, c.Hash))
+	}
+	return
+}
+
+func TestDiffChanges(t *testing.T){
+	up := func(hash string)(api.ChangeState){
+		return api.ChangeState{ Op: api.ChangeOpUpsert, Hash: hash }
+	}
+	del := api.ChangeState{ Op: api.ChangeOpDelete }
+	for _, c := range []struct{
+		name string
+		recorded, current map[string]api.ChangeState
+		want []string
+	}{
+		{
+			name: "new plugin",
+			recorded: map[string]api.ChangeState{},
+			current: map[string]api.ChangeState{"": up("p"), "1.1.0": up("b"), "1.0.0": up("a"), "0.1.0": del},
+			want: []string{"upsert plugin  p", "upsert release 1.0.0 a", "upsert release 1.1.0 b"},
+		},
+		{
+			name: "unchanged",
+			recorded: map[string]api.ChangeState{"": up("p"), "1.0.0": up("a"), "0.1.0": del},
+			current: map[string]api.ChangeState{"": up("p"), "1.0.0": up("a"), "0.1.0": del},
+			want: nil,
+		},
+		{
+			name: "changed",
+			recorded: map[string]api.ChangeState{"": up("p"), "1.0.0": up("a"), "1.1.0": up("b")},
+			current: map[string]api.ChangeState{"": up("q"), "1.0.0": up("a"), "1.1.0": del, "1.2.0": up("c")},
+			want: []string{"upsert plugin  q", "delete release 1.1.0 ", "upsert release 1.2.0 c"},
+		},
+		{
+			name: "release removed",
+			recorded: map[string]api.ChangeState{"": up("p"), "1.0.0": up("a"), "0.9.0": del},
+			current: map[string]api.ChangeState{"": up("p")},
+			want: []string{"delete release 1.0.0 "},
+		},
+		{
+			name: "backfilled",
+			recorded: map[string]api.ChangeState{"": up(""), "1.0.0": up("")},
+			current: map[string]api.ChangeState{"": up("p"), "1.0.0": up("a")},
+			want: []string{"upsert plugin  p", "upsert release 1.0.0 a"},
+		},
+		{
+			name: "plugin disabled",
+			recorded: map[string]api.ChangeState{"": up("p"), "1.0.0": up("a")},
+			current: map[string]api.ChangeState{"": del},
+			want: []string{"delete plugin  "},
+		},
+		{
+			name: "plugin removed",
+			recorded: map[string]api.ChangeState{"": up("p"), "1.0.0": up("a")},
+			current: map[string]api.ChangeState{},
+			want: []string{"delete plugin  "},
+		},
+		{
+			name: "already removed",
+			recorded: map[string]api.ChangeState{"": del},
+			current: map[string]api.ChangeState{},
+			want: nil,
+		},
+		{
+			name: "never published",
+			recorded: map[string]api.ChangeState{},
+			current: map[string]api.ChangeState{"": del},
+			want: nil,
+		},
+	}{
+		got := formatChanges(api.DiffChanges("foo", c.recorded, c.current))
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%s: changes are %q, expect %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNewChangesPage(t *testing.T){
+	p := api.NewChangesPage(5, nil, 2)
+	if p.Changes == nil || len(p.Changes) != 0 || p.Next != 5 || p.More {
+		t.Errorf("Unexpected empty page %+v", p)
+	}
+	changes := []*api.CatalogueChange{{ Seq: 7 }, { Seq: 9 }}
+	if p = api.NewChangesPage(5, changes, 2); p.Next != 9 || !p.More {
+		t.Errorf("Unexpected full page %+v", p)
+	}
+	if p = api.NewChangesPage(5, changes[:1], 2); p.Next != 7 || p.More {
+		t.Errorf("Unexpected last page %+v", p)
+	}
+}
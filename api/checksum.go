@@ -0,0 +1,98 @@
+
+package api
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+type Checksums struct{
+	Sha256 string `json:"sha256,omitempty"`
+	Sha1   string `json:"sha1,omitempty"`
+}
+
+func (c Checksums)IsZero()(bool){
+	return len(c.Sha256) == 0
+}
+
+func (c Checksums)Equal(o Checksums)(bool){
+	return c.Sha256 == o.Sha256 && c.Sha1 == o.Sha1
+}
+
+// ETag returns a strong entity tag generated from the sha256 checksum
+func (c Checksums)ETag()(string){
+	return `"sha256:` + c.Sha256 + `"`
+}
+
+func hexToBase64(s string)(string){
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// Digest returns the value of the `Digest` header, see RFC 3230
+func (c Checksums)Digest()(string){
+	v := "sha-256=" + hexToBase64(c.Sha256)
+	if len(c.Sha1) > 0 {
+		v += ",sha=" + hexToBase64(c.Sha1)
+	}
+	return v
+}
+
+// ChecksumWriter calculates the checksums of the data written to it
+type ChecksumWriter struct{
+	sha256 hash.Hash
+	sha1   hash.Hash
+}
+
+var _ io.Writer = (*ChecksumWriter)(nil)
+
+func NewChecksumWriter()(*ChecksumWriter){
+	return &ChecksumWriter{
+		sha256: sha256.New(),
+		sha1: sha1.New(),
+	}
+}
+
+func (w *ChecksumWriter)Write(buf []byte)(n int, err error){
+	w.sha256.Write(buf)
+	w.sha1.Write(buf)
+	return len(buf), nil
+}
+
+func (w *ChecksumWriter)Sums()(Checksums){
+	return Checksums{
+		Sha256: hex.EncodeToString(w.sha256.Sum(nil)),
+		Sha1: hex.EncodeToString(w.sha1.Sum(nil)),
+	}
+}
+
+func CalcChecksums(r io.Reader)(sums Checksums, err error){
+	w := NewChecksumWriter()
+	if _, err = io.Copy(w, r); err != nil {
+		return
+	}
+	return w.Sums(), nil
+}
+
+// VerifyChecksums calculates the checksums of the data and seeks it back to the start.
+// ErrChecksumMismatch is returned with the calculated checksums if they don't equal to the expected ones,
+// the data is not rejected if the expected checksums are zero, since they are not recorded yet
+func VerifyChecksums(expect Checksums, r io.ReadSeeker)(sums Checksums, err error){
+	if sums, err = CalcChecksums(r); err != nil {
+		return
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if !expect.IsZero() && !expect.Equal(sums) {
+		err = ErrChecksumMismatch
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package api_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestCalcChecksums(t *testing.T){
+	cases := []struct{
+		data   string
+		sha256 string
+		sha1   string
+		digest string
+	}{
+		{ "", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+			"sha-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=,sha=2jmj7l5rSw0yVb/vlWAYkK/YBwk=" },
+		{ "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", "a9993e364706816aba3e25717850c26c9cd0d89d",
+			"sha-256=ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=,sha=qZk+NkcGgWq6PiVxeFDCbJzQ2J0=" },
+	}
+	for _, c := range cases {
+		sums, err := api.CalcChecksums(strings.NewReader(c.data))
+		if err != nil {
+			t.Fatalf("Cannot calc checksums of %q: %v", c.data, err)
+		}
+		if sums.Sha256 != c.sha256 || sums.Sha1 != c.sha1 {
+			t.Errorf("Unexpected checksums of %q: %+v", c.data, sums)
+		}
+		if d := sums.Digest(); d != c.digest {
+			t.Errorf("Unexpected digest of %q: %s, expect %s", c.data, d, c.digest)
+		}
+		if etag := sums.ETag(); etag != `"sha256:` + c.sha256 + `"` {
+			t.Errorf("Unexpected etag of %q: %s", c.data, etag)
+		}
+	}
+	if d := (api.Checksums{ Sha256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" }).Digest(); d != "sha-256=ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=" {
+		t.Errorf("The digest without sha1 is %s", d)
+	}
+}
+
+func TestVerifyChecksums(t *testing.T){
+	abc, _ := api.CalcChecksums(strings.NewReader("abc"))
+	cases := []struct{
+		name   string
+		expect api.Checksums
+		data   string
+		err    error
+	}{
+		{ "matched", abc, "abc", nil },
+		{ "not recorded", api.Checksums{}, "abc", nil },
+		{ "changed content", abc, "abd", api.ErrChecksumMismatch },
+		{ "empty content", abc, "", api.ErrChecksumMismatch },
+		{ "sha1 mismatch", api.Checksums{ Sha256: abc.Sha256, Sha1: "0000" }, "abc", api.ErrChecksumMismatch },
+	}
+	for _, c := range cases {
+		r := strings.NewReader(c.data)
+		sums, err := api.VerifyChecksums(c.expect, r)
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s: unexpected error %v, expect %v", c.name, err, c.err)
+		}
+		if want, _ := api.CalcChecksums(strings.NewReader(c.data)); !sums.Equal(want) {
+			t.Errorf("%s: the calculated checksums are %+v, expect %+v", c.name, sums, want)
+		}
+		// the data must be readable again after checked
+		if data, _ := io.ReadAll(r); string(data) != c.data {
+			t.Errorf("%s: the reader is not seeked back, read %q", c.name, data)
+		}
+	}
+}
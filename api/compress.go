@@ -0,0 +1,327 @@
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The content codings that can be negotiated by `Accept-Encoding`
+const (
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+	EncodingGzip   = "gzip"
+)
+
+// Encodings are the supported content codings, in the order of preference
+var Encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+// CompressMinSize is the min size of a response body to be compressed,
+// the smaller bodies don't worth the overhead
+const CompressMinSize = 1024
+
+// NegotiateEncoding returns the content coding in the available ones that is most preferred by the `Accept-Encoding` header,
+// or an empty string if the identity coding should be used
+func NegotiateEncoding(header string, available []string)(encoding string){
+	if len(header) == 0 {
+		return ""
+	}
+	qvalues := make(map[string]float64)
+	wildcard := -1.0
+	for _, item := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(item, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if len(coding) == 0 {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				var err error
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		if coding == "*" {
+			wildcard = q
+		}else{
+			qvalues[coding] = q
+		}
+	}
+	best := 0.0
+	for _, enc := range available {
+		q, ok := qvalues[enc]
+		if !ok {
+			q = wildcard
+		}
+		if q > best {
+			encoding, best = enc, q
+		}
+	}
+	return
+}
+
+// IsCompressibleType reports whether a response with the content type is worth to compress
+func IsCompressibleType(contentType string)(bool){
+	typ, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(typ, "text/") || strings.HasSuffix(typ, "+json") || strings.HasSuffix(typ, "+xml") {
+		return true
+	}
+	switch typ {
+	case "application/json", "application/x-ndjson", "application/javascript", "application/xml",
+		"application/yaml", "application/graphql-response+json", "image/svg+xml", "image/x-icon":
+		return true
+	}
+	return false
+}
+
+// CompressBytes compresses the data with the best level of the coding,
+// it's used to precompress the static files
+func CompressBytes(data []byte, encoding string)(_ []byte, err error){
+	var (
+		buf bytes.Buffer
+		w io.WriteCloser
+	)
+	switch encoding {
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	case EncodingZstd:
+		if w, err = zstd.NewWriter(&buf, zstd.WithEncoderLevel(zstd.SpeedBestCompression)); err != nil {
+			return
+		}
+	case EncodingGzip:
+		if w, err = gzip.NewWriterLevel(&buf, gzip.BestCompression); err != nil {
+			return
+		}
+	default:
+		return nil, http.ErrNotSupported
+	}
+	if _, err = w.Write(data); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+	return buf.Bytes(), nil
+}
+
+type encoder interface{
+	io.WriteCloser
+	Flush()(error)
+	Reset(w io.Writer)
+}
+
+// the encoders for the dynamic responses use the fast levels
+var encoderPools = map[string]*sync.Pool{
+	EncodingBrotli: {
+		New: func()(any){
+			return brotli.NewWriterLevel(nil, 4)
+		},
+	},
+	EncodingZstd: {
+		New: func()(any){
+			w, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				panic(err)
+			}
+			return w
+		},
+	},
+	EncodingGzip: {
+		New: func()(any){
+			w, _ := gzip.NewWriterLevel(nil, 5)
+			return w
+		},
+	},
+}
+
+// AddVary adds the field to the `Vary` header if it's not listed yet
+func AddVary(header http.Header, field string){
+	for _, v := range header.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
+
+// CompressWriter compresses the response body with the coding negotiated by the request.
+// The body is buffered until it reaches CompressMinSize or is flushed, so the small ones are sent as is.
+// Close must be called after the handler is returned
+type CompressWriter struct{
+	http.ResponseWriter
+	encoding string
+	head bool
+
+	code int
+	wroteHeader bool
+	started bool
+	buf []byte
+	enc encoder
+}
+
+var _ http.Flusher = (*CompressWriter)(nil)
+var _ http.Hijacker = (*CompressWriter)(nil)
+
+func NewCompressWriter(w http.ResponseWriter, r *http.Request)(*CompressWriter){
+	return &CompressWriter{
+		ResponseWriter: w,
+		encoding: NegotiateEncoding(r.Header.Get("Accept-Encoding"), Encodings),
+		head: r.Method == http.MethodHead,
+	}
+}
+
+func (w *CompressWriter)WriteHeader(code int){
+	if w.wroteHeader {
+		return
+	}
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+	w.wroteHeader = true
+	if w.head || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.start(false)
+	}
+}
+
+func (w *CompressWriter)Write(buf []byte)(n int, err error){
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.started {
+		w.buf = append(w.buf, buf...)
+		if len(w.buf) < CompressMinSize {
+			return len(buf), nil
+		}
+		if err = w.start(true); err != nil {
+			return 0, err
+		}
+		return len(buf), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(buf)
+	}
+	return w.ResponseWriter.Write(buf)
+}
+
+// compressible reports whether the response could be compressed, whatever the client accepts
+func (w *CompressWriter)compressible(header http.Header)(bool){
+	if w.head || w.code == http.StatusNoContent || w.code == http.StatusNotModified || w.code == http.StatusPartialContent {
+		return false
+	}
+	if len(header.Get("Content-Encoding")) > 0 || len(header.Get("Content-Range")) > 0 {
+		return false
+	}
+	if l := header.Get("Content-Length"); len(l) > 0 {
+		if n, err := strconv.ParseInt(l, 10, 64); err == nil && n < CompressMinSize {
+			return false
+		}
+	}
+	return IsCompressibleType(header.Get("Content-Type"))
+}
+
+// start sends the header, and the buffered body with the coding if compress is true and the response is compressible
+func (w *CompressWriter)start(compress bool)(err error){
+	w.started = true
+	header := w.Header()
+	if len(w.buf) > 0 && len(header.Get("Content-Type")) == 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if w.compressible(header) {
+		AddVary(header, "Accept-Encoding")
+		if compress && len(w.encoding) > 0 {
+			header.Del("Content-Length")
+			header.Del("Accept-Ranges")
+			header.Set("Content-Encoding", w.encoding)
+			// the compressed body is not byte-for-byte equivalent
+			if etag := header.Get("ETag"); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/" + etag)
+			}
+			w.enc = encoderPools[w.encoding].Get().(encoder)
+			w.enc.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	if len(w.buf) > 0 {
+		if w.enc != nil {
+			_, err = w.enc.Write(w.buf)
+		}else{
+			_, err = w.ResponseWriter.Write(w.buf)
+		}
+		w.buf = nil
+	}
+	return
+}
+
+// Flush sends the buffered body without waiting it reaches CompressMinSize, so the event streams are not delayed
+func (w *CompressWriter)Flush(){
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.started {
+		w.start(true)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *CompressWriter)Hijack()(net.Conn, *bufio.ReadWriter, error){
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *CompressWriter)Unwrap()(http.ResponseWriter){
+	return w.ResponseWriter
+}
+
+// Close sends the rest of the body, and puts the encoder back to the pool
+func (w *CompressWriter)Close()(err error){
+	if w.wroteHeader && !w.started {
+		// the body is smaller than CompressMinSize
+		err = w.start(false)
+	}
+	if w.enc != nil {
+		if e := w.enc.Close(); err == nil {
+			err = e
+		}
+		w.enc.Reset(nil)
+		encoderPools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+	return
+}
+
+// CompressHandler compresses the responses of the handler with the negotiated coding
+func CompressHandler(h http.Handler)(http.Handler){
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request){
+		cw := NewCompressWriter(rw, req)
+		defer cw.Close()
+		h.ServeHTTP(cw, req)
+	})
+}
@@ -0,0 +1,170 @@
+package api_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestNegotiateEncoding(t *testing.T){
+	cases := map[string]string{
+		"": "",
+		"identity": "",
+		"gzip": "gzip",
+		"gzip, deflate, br": "br",
+		"gzip, deflate, br, zstd": "br",
+		"br;q=0.5, zstd": "zstd",
+		"GZIP;q=0.8, br;q=0": "gzip",
+		"*": "br",
+		"*;q=0.1, gzip;q=0.5": "gzip",
+		"*, br;q=0, zstd;q=0": "gzip",
+		"gzip;q=0": "",
+		"gzip;q=x": "",
+	}
+	for header, expect := range cases {
+		if enc := api.NegotiateEncoding(header, api.Encodings); enc != expect {
+			t.Errorf("Negotiated %q for %q, expect %q", enc, header, expect)
+		}
+	}
+	if enc := api.NegotiateEncoding("br, gzip;q=0.9", []string{api.EncodingGzip}); enc != api.EncodingGzip {
+		t.Errorf("Negotiated %q in the available encodings, expect gzip", enc)
+	}
+}
+
+func decodeBody(t *testing.T, encoding string, body []byte)([]byte){
+	var (
+		r io.Reader
+		err error
+	)
+	switch encoding {
+	case "":
+		return body
+	case api.EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case api.EncodingZstd:
+		var d *zstd.Decoder
+		if d, err = zstd.NewReader(bytes.NewReader(body)); err == nil {
+			defer d.Close()
+			r = d
+		}
+	case api.EncodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	}
+	if err != nil {
+		t.Fatalf("Cannot decode %s: %v", encoding, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Cannot decode %s: %v", encoding, err)
+	}
+	return data
+}
+
+func TestCompressWriter(t *testing.T){
+	large := strings.Repeat(`{"id":"hello","name":"Hello World"},`, 100)
+	type testCase struct{
+		accept string
+		contentType string
+		code int
+		body string
+		encoding string
+		vary bool
+	}
+	cases := []testCase{
+		{ "br", "application/json", 200, large, "br", true },
+		{ "zstd", "application/json; charset=utf-8", 200, large, "zstd", true },
+		{ "gzip", "text/html", 404, large, "gzip", true },
+		{ "", "application/json", 200, large, "", true },
+		{ "gzip", "application/json", 200, "{}", "", true },
+		{ "gzip", "application/zip", 200, large, "", false },
+		{ "gzip", "application/json", 304, "", "", false },
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", c.accept)
+		rw := httptest.NewRecorder()
+		cw := api.NewCompressWriter(rw, req)
+		cw.Header().Set("Content-Type", c.contentType)
+		cw.Header().Set("ETag", `"abc"`)
+		cw.WriteHeader(c.code)
+		// write in small chunks so the body is buffered
+		for body := c.body; len(body) > 0; {
+			n := len(body)
+			if n > 100 {
+				n = 100
+			}
+			cw.Write(([]byte)(body[:n]))
+			body = body[n:]
+		}
+		if err := cw.Close(); err != nil {
+			t.Fatalf("Cannot close the writer: %v", err)
+		}
+		res := rw.Result()
+		if res.StatusCode != c.code {
+			t.Errorf("%+v: unexpected status %d", c, res.StatusCode)
+		}
+		if enc := res.Header.Get("Content-Encoding"); enc != c.encoding {
+			t.Errorf("%+v: unexpected encoding %q", c, enc)
+		}
+		if vary := res.Header.Get("Vary") == "Accept-Encoding"; vary != c.vary {
+			t.Errorf("%+v: unexpected vary %q", c, res.Header.Get("Vary"))
+		}
+		etag := `"abc"`
+		if len(c.encoding) > 0 {
+			etag = `W/"abc"`
+		}
+		if res.Header.Get("ETag") != etag {
+			t.Errorf("%+v: unexpected etag %q", c, res.Header.Get("ETag"))
+		}
+		if body := string(decodeBody(t, c.encoding, rw.Body.Bytes())); body != c.body {
+			t.Errorf("%+v: unexpected body %q", c, body)
+		}
+	}
+}
+
+func TestCompressWriterFlush(t *testing.T){
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	cw := api.NewCompressWriter(rw, req)
+	cw.Header().Set("Content-Type", "text/event-stream")
+	io.WriteString(cw, "retry: 1000\n\n")
+	cw.Flush()
+	if !rw.Flushed || rw.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("The small event is not flushed, encoding=%q", rw.Header().Get("Content-Encoding"))
+	}
+	// the flushed part must be decodable before the stream is closed
+	r, err := gzip.NewReader(bytes.NewReader(rw.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("Cannot decode the flushed event: %v", err)
+	}
+	buf := make([]byte, 13)
+	if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "retry: 1000\n\n" {
+		t.Errorf("Unexpected flushed event %q: %v", buf, err)
+	}
+	cw.Close()
+}
+
+func TestCompressBytes(t *testing.T){
+	data := ([]byte)(strings.Repeat("console.log('hello');\n", 100))
+	for _, enc := range api.Encodings {
+		buf, err := api.CompressBytes(data, enc)
+		if err != nil {
+			t.Fatalf("Cannot compress with %s: %v", enc, err)
+		}
+		if len(buf) >= len(data) {
+			t.Errorf("%s doesn't compress the data: %d >= %d", enc, len(buf), len(data))
+		}
+		if !bytes.Equal(decodeBody(t, enc, buf), data) {
+			t.Errorf("%s: the decoded data is not matched", enc)
+		}
+	}
+}
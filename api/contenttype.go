@@ -0,0 +1,47 @@
+
+package api
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// AssetContentType returns the content type which the asset should be served with.
+// The assets are uploaded by the plugin authors, so the types that can be rendered
+// as a page by the browsers are never returned
+func AssetContentType(name string)(string){
+	ext := strings.ToLower(path.Ext(name))
+	switch ext {
+	case ".mcdr", ".zip":
+		return "application/zip"
+	case ".py", ".txt", ".md", ".json", ".yml", ".yaml", ".toml", ".properties":
+		return "text/plain; charset=utf-8"
+	}
+	typ, _, err := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	if strings.HasPrefix(typ, "text/") {
+		return "text/plain; charset=utf-8"
+	}
+	if strings.Contains(typ, "html") || strings.Contains(typ, "xml") || strings.Contains(typ, "javascript") {
+		return "application/octet-stream"
+	}
+	return typ
+}
+
+// inlineContentTypes are the types that the browsers never run as a page or a script
+var inlineContentTypes = map[string]bool{
+	"text/plain; charset=utf-8": true,
+	"image/png": true,
+	"image/jpeg": true,
+	"image/gif": true,
+	"image/webp": true,
+}
+
+// IsInlineContentType reports whether the asset of the type is safe to be shown by the browsers on our origin,
+// the assets of the other types should be sent as attachments
+func IsInlineContentType(typ string)(bool){
+	return inlineContentTypes[typ]
+}
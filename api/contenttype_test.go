@@ -0,0 +1,39 @@
+package api_test
+
+import (
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestAssetContentType(t *testing.T){
+	cases := map[string]string{
+		"Hello World-v1.0.0.mcdr": "application/zip",
+		"resources.ZIP": "application/zip",
+		"config.yml": "text/plain; charset=utf-8",
+		"index.html": "text/plain; charset=utf-8",
+		"image.svg": "application/octet-stream",
+		"page.xhtml": "application/octet-stream",
+		"icon.png": "image/png",
+		"unknown": "application/octet-stream",
+	}
+	for name, expect := range cases {
+		if typ := api.AssetContentType(name); typ != expect {
+			t.Errorf("Content type of %q is %q, expect %q", name, typ, expect)
+		}
+	}
+
+	for name, expect := range map[string]bool{
+		"config.yml": true,
+		"index.html": true,
+		"icon.png": true,
+		"Hello World-v1.0.0.mcdr": false,
+		"image.svg": false,
+		"document.pdf": false,
+		"unknown": false,
+	} {
+		if inline := api.IsInlineContentType(api.AssetContentType(name)); inline != expect {
+			t.Errorf("IsInlineContentType of %q is %v, expect %v", name, inline, expect)
+		}
+	}
+}
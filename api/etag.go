@@ -0,0 +1,26 @@
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ContentETag returns a strong etag which is the truncated SHA-256 of the content
+func ContentETag(data []byte)(string){
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// MatchETag reports whether the etag matches the `If-None-Match` header, the weak tags are compared weakly
+func MatchETag(header string, etag string)(bool){
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package api_test
+
+import (
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestMatchETag(t *testing.T){
+	for _, c := range []struct{
+		header string
+		etag string
+		want bool
+	}{
+		{`"abc"`, `"abc"`, true},
+		{`"x", "abc"`, `"abc"`, true},
+		{`W/"abc"`, `"abc"`, true},
+		{`*`, `"abc"`, true},
+		{`"abcd"`, `"abc"`, false},
+		{``, `"abc"`, false},
+	}{
+		if got := api.MatchETag(c.header, c.etag); got != c.want {
+			t.Errorf("MatchETag(%q, %q) is %v, expect %v", c.header, c.etag, got, c.want)
+		}
+	}
+}
+
+func TestContentETag(t *testing.T){
+	a, b := api.ContentETag(([]byte)("hello")), api.ContentETag(([]byte)("hello!"))
+	if a == b {
+		t.Errorf("Different contents have the same etag %s", a)
+	}
+	if a != api.ContentETag(([]byte)("hello")) {
+		t.Errorf("The etag is not stable")
+	}
+	if len(a) < 2 || a[0] != '"' || a[len(a) - 1] != '"' {
+		t.Errorf("The etag %s is not quoted", a)
+	}
+}
@@ -0,0 +1,166 @@
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// EventStreamBuffer is the count of events buffered for each subscriber,
+	// the subscriber will be dropped if it cannot catch up
+	EventStreamBuffer = 64
+	// EventBacklogLimit is the max count of events queried from the log at once
+	EventBacklogLimit = 500
+)
+
+// EventSubscription receives the events published to the hub.
+// C will be closed when the subscription is cancelled or it falls behind
+type EventSubscription struct{
+	C <-chan *CatalogueEvent
+
+	ch chan *CatalogueEvent
+	types []string
+	plugin string
+}
+
+// Match reports whether the event is subscribed
+func (s *EventSubscription)Match(ev *CatalogueEvent)(bool){
+	if len(s.plugin) > 0 && s.plugin != ev.Plugin {
+		return false
+	}
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if t == ev.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// EventHub broadcasts the catalogue events to the subscribers
+type EventHub struct{
+	mux sync.Mutex
+	subs map[*EventSubscription]struct{}
+	lastSeq int64
+}
+
+func NewEventHub(lastSeq int64)(*EventHub){
+	return &EventHub{
+		subs: make(map[*EventSubscription]struct{}),
+		lastSeq: lastSeq,
+	}
+}
+
+// Subscribe registers a subscriber for the given event types (all types if empty) of the plugin (all plugins if empty).
+// The sequence of the last published event is returned, the subscriber will only receive the events after it
+func (h *EventHub)Subscribe(types []string, plugin string)(sub *EventSubscription, lastSeq int64){
+	ch := make(chan *CatalogueEvent, EventStreamBuffer)
+	sub = &EventSubscription{
+		C: ch,
+		ch: ch,
+		types: types,
+		plugin: plugin,
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.subs[sub] = struct{}{}
+	return sub, h.lastSeq
+}
+
+// Unsubscribe cancels the subscription, it's safe to call it more than once
+func (h *EventHub)Unsubscribe(sub *EventSubscription){
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish sends the events to the subscribers, the events must be sorted by increasing sequence.
+// The events that are not newer than the last published one are ignored
+func (h *EventHub)Publish(events ...*CatalogueEvent){
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for _, ev := range events {
+		if ev.Seq <= h.lastSeq {
+			continue
+		}
+		h.lastSeq = ev.Seq
+		for sub := range h.subs {
+			if !sub.Match(ev) {
+				continue
+			}
+			select {
+			case sub.ch <- ev:
+			default:
+				// the subscriber is too slow, it should resume from the log later
+				delete(h.subs, sub)
+				close(sub.ch)
+			}
+		}
+	}
+}
+
+// LastSeq returns the sequence of the last published event
+func (h *EventHub)LastSeq()(int64){
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return h.lastSeq
+}
+
+// Subscribers returns the count of the current subscribers
+func (h *EventHub)Subscribers()(int){
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return len(h.subs)
+}
+
+// ParseEventTypes parses the comma separated event types, ok is false if any of them is unknown
+func ParseEventTypes(s string)(types []string, ok bool){
+	if len(s) == 0 {
+		return nil, true
+	}
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if !IsCatalogueEventType(t) {
+			return nil, false
+		}
+		types = append(types, t)
+	}
+	return types, true
+}
+
+// ParseEventID parses the `Last-Event-ID` of server-sent events
+func ParseEventID(s string)(seq int64, ok bool){
+	seq, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, false
+	}
+	return seq, true
+}
+
+// WriteSSE writes the event in the format of server-sent events
+func WriteSSE(w io.Writer, ev *CatalogueEvent)(err error){
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	buf := make([]byte, 0, len(data) + 64)
+	buf = append(buf, "id: "...)
+	buf = strconv.AppendInt(buf, ev.Seq, 10)
+	buf = append(buf, "\nevent: "...)
+	buf = append(buf, ev.Type...)
+	buf = append(buf, "\ndata: "...)
+	buf = append(buf, data...)
+	buf = append(buf, "\n\n"...)
+	_, err = w.Write(buf)
+	return
+}
@@ -0,0 +1,126 @@
+package api_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestEventHub(t *testing.T){
+	hub := api.NewEventHub(1)
+	all, seq := hub.Subscribe(nil, "")
+	if seq != 1 {
+		t.Fatalf("Last sequence is %d, expect 1", seq)
+	}
+	releases, _ := hub.Subscribe([]string{api.EventReleaseAdded}, "")
+	plugin, _ := hub.Subscribe(nil, "foo")
+
+	hub.Publish(
+		&api.CatalogueEvent{ Seq: 1, Type: api.EventPluginAdded, Plugin: "old" },
+		&api.CatalogueEvent{ Seq: 2, Type: api.EventPluginAdded, Plugin: "foo" },
+		&api.CatalogueEvent{ Seq: 3, Type: api.EventReleaseAdded, Plugin: "bar", Tag: "1.0.0" },
+	)
+	if seq := hub.LastSeq(); seq != 3 {
+		t.Errorf("Last sequence is %d, expect 3", seq)
+	}
+	for _, c := range []struct{
+		name string
+		sub *api.EventSubscription
+		want []int64
+	}{
+		{"all", all, []int64{2, 3}},
+		{"releases", releases, []int64{3}},
+		{"plugin", plugin, []int64{2}},
+	}{
+		for _, want := range c.want {
+			if ev := <-c.sub.C; ev.Seq != want {
+				t.Errorf("Subscriber %s received event %d, expect %d", c.name, ev.Seq, want)
+			}
+		}
+		select {
+		case ev := <-c.sub.C:
+			t.Errorf("Subscriber %s received unexpected event %d", c.name, ev.Seq)
+		default:
+		}
+	}
+
+	hub.Unsubscribe(releases)
+	hub.Unsubscribe(releases)
+	if _, ok := <-releases.C; ok {
+		t.Errorf("The channel is not closed after unsubscribed")
+	}
+	if n := hub.Subscribers(); n != 2 {
+		t.Errorf("Subscriber count is %d, expect 2", n)
+	}
+}
+
+func TestEventHubSlowSubscriber(t *testing.T){
+	hub := api.NewEventHub(0)
+	sub, _ := hub.Subscribe(nil, "")
+	for i := 1; i <= api.EventStreamBuffer + 1; i++ {
+		hub.Publish(&api.CatalogueEvent{ Seq: (int64)(i), Type: api.EventReleaseAdded, Plugin: "foo" })
+	}
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != api.EventStreamBuffer {
+		t.Errorf("Received %d events, expect %d", n, api.EventStreamBuffer)
+	}
+	if n := hub.Subscribers(); n != 0 {
+		t.Errorf("The slow subscriber is not dropped")
+	}
+}
+
+func TestParseEventTypes(t *testing.T){
+	if types, ok := api.ParseEventTypes(""); !ok || types != nil {
+		t.Errorf("Empty types are parsed as %v, %v", types, ok)
+	}
+	types, ok := api.ParseEventTypes("release_added, release_stable")
+	if !ok || len(types) != 2 || types[0] != api.EventReleaseAdded || types[1] != api.EventReleaseStable {
+		t.Errorf("Unexpected types %v, %v", types, ok)
+	}
+	if _, ok := api.ParseEventTypes("release_added,unknown"); ok {
+		t.Errorf("Unknown type is accepted")
+	}
+}
+
+func TestParseEventID(t *testing.T){
+	for _, c := range []struct{
+		s string
+		seq int64
+		ok bool
+	}{
+		{"0", 0, true},
+		{"42", 42, true},
+		{" 42 ", 42, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"abc", 0, false},
+	}{
+		if seq, ok := api.ParseEventID(c.s); seq != c.seq || ok != c.ok {
+			t.Errorf("ParseEventID(%q) is %d, %v, expect %d, %v", c.s, seq, ok, c.seq, c.ok)
+		}
+	}
+}
+
+func TestWriteSSE(t *testing.T){
+	var buf bytes.Buffer
+	ev := &api.CatalogueEvent{
+		Seq: 7,
+		Type: api.EventReleaseAdded,
+		Plugin: "foo",
+		Tag: "1.0.0",
+		Time: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if err := api.WriteSSE(&buf, ev); err != nil {
+		t.Fatalf("Cannot write event: %v", err)
+	}
+	const want = "id: 7\nevent: release_added\n" +
+		`data: {"id":7,"type":"release_added","plugin":"foo","tag":"1.0.0","time":"2023-05-01T12:00:00Z"}` + "\n\n"
+	if s := buf.String(); s != want {
+		t.Errorf("Unexpected output %q, expect %q", s, want)
+	}
+}
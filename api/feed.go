@@ -0,0 +1,304 @@
+
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	FeedDefaultLimit = 50
+	FeedMaxLimit = 200
+)
+
+type ReleaseFeedOpt struct{
+	Plugin string // only the releases of the plugin
+	Author string // only the releases of the plugins which authors include it
+	Label  string // only the releases of the plugins with the label, see PluginLabels
+	Limit  int
+}
+
+// FeedRelease is a release with the information of its plugin
+type FeedRelease struct{
+	Plugin    *PluginInfo
+	Release   *PluginRelease
+	Changelog string
+}
+
+type FeedEntry struct{
+	Id         string
+	Title      string
+	Link       string
+	Authors    []string
+	Categories []string
+	Summary    string
+	Content    string
+	Published  time.Time
+	Updated    time.Time
+
+	Enclosure       string
+	EnclosureLength int64
+	EnclosureType   string
+}
+
+// Feed is rendered as Atom 1.0 or RSS 2.0
+type Feed struct{
+	Title   string
+	Desc    string
+	Link    string // the html page of the feed
+	Self    string // the url of the feed itself
+	Updated time.Time
+	Entries []*FeedEntry
+}
+
+// NewReleaseFeed creates the feed of the releases, the updated time is the latest upload time of the releases.
+// site is the prefix of the web pages, and page is the path of the html page of the feed.
+// The plugin descriptions are in Chinese if lang is `zh_cn` and the Chinese description exists
+func NewReleaseFeed(site string, title string, page string, self string, items []*FeedRelease, lang string)(f *Feed){
+	f = &Feed{
+		Title: title,
+		Desc: title,
+		Link: site + page,
+		Self: self,
+		Entries: make([]*FeedEntry, 0, len(items)),
+	}
+	for _, item := range items {
+		p, r := item.Plugin, item.Release
+		if r.Uploaded.After(f.Updated) {
+			f.Updated = r.Uploaded
+		}
+		desc := p.Desc
+		if lang == "zh_cn" && len(p.Desc_zhCN) > 0 {
+			desc = p.Desc_zhCN
+		}
+		title := fmt.Sprintf("%s v%s", p.Name, r.Tag)
+		// the release names usually contain the version, which are redundant in the title
+		if len(r.Name) > 0 && !strings.Contains(r.Name, r.Tag.String()) {
+			title += ": " + r.Name
+		}
+		if !r.Stable {
+			title += " (prerelease)"
+		}
+		entry := &FeedEntry{
+			Id: fmt.Sprintf("urn:pwp:release:%s:%s", p.Id, r.Tag),
+			Title: title,
+			Link: site + "/plugin/" + url.PathEscape(p.Id) + "/",
+			Authors: p.Authors,
+			Categories: p.Labels.Names(),
+			Summary: desc,
+			Content: item.Changelog,
+			Published: r.Uploaded,
+			Updated: r.Uploaded,
+		}
+		if len(r.FileName) > 0 {
+			entry.Enclosure = fmt.Sprintf("%s/download/%s/%s/%s", site, url.PathEscape(p.Id), r.Tag, url.PathEscape(r.FileName))
+			entry.EnclosureLength = r.Size
+			entry.EnclosureType = AssetContentType(r.FileName)
+		}
+		f.Entries = append(f.Entries, entry)
+	}
+	return
+}
+
+type atomLink struct{
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomText struct{
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomPerson struct{
+	Name string `xml:"name"`
+}
+
+type atomCategory struct{
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct{
+	Id         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary"`
+	Content    *atomText      `xml:"content"`
+}
+
+type atomFeed struct{
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id        string      `xml:"id"`
+	Title     atomText    `xml:"title"`
+	Subtitle  *atomText   `xml:"subtitle"`
+	Links     []atomLink  `xml:"link"`
+	Updated   string      `xml:"updated"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+func atomTime(t time.Time)(string){
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Atom renders the feed as Atom 1.0, the content type is `application/atom+xml`
+func (f *Feed)Atom()([]byte, error){
+	feed := atomFeed{
+		Id: f.Self,
+		Title: atomText{ Type: "text", Body: f.Title },
+		Links: []atomLink{{ Rel: "self", Href: f.Self, Type: "application/atom+xml" }},
+		Updated: atomTime(f.Updated),
+		Generator: "PluginWebPoint",
+		Entries: make([]atomEntry, len(f.Entries)),
+	}
+	if len(f.Desc) > 0 && f.Desc != f.Title {
+		feed.Subtitle = &atomText{ Type: "text", Body: f.Desc }
+	}
+	if len(f.Link) > 0 {
+		feed.Links = append(feed.Links, atomLink{ Rel: "alternate", Href: f.Link, Type: "text/html" })
+	}
+	for i, e := range f.Entries {
+		entry := atomEntry{
+			Id: e.Id,
+			Title: atomText{ Type: "text", Body: e.Title },
+			Published: atomTime(e.Published),
+			Updated: atomTime(e.Updated),
+		}
+		if len(e.Link) > 0 {
+			entry.Links = append(entry.Links, atomLink{ Rel: "alternate", Href: e.Link, Type: "text/html" })
+		}
+		if len(e.Enclosure) > 0 {
+			entry.Links = append(entry.Links, atomLink{ Rel: "enclosure", Href: e.Enclosure, Type: e.EnclosureType, Length: e.EnclosureLength })
+		}
+		for _, a := range e.Authors {
+			entry.Authors = append(entry.Authors, atomPerson{ Name: a })
+		}
+		for _, c := range e.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{ Term: c })
+		}
+		if len(e.Summary) > 0 {
+			entry.Summary = &atomText{ Type: "text", Body: e.Summary }
+		}
+		if len(e.Content) > 0 {
+			entry.Content = &atomText{ Type: "text", Body: e.Content }
+		}
+		feed.Entries[i] = entry
+	}
+	return marshalXML(feed)
+}
+
+type rssGuid struct{
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Body        string `xml:",chardata"`
+}
+
+type rssEnclosure struct{
+	Url    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssItem struct{
+	Title      string        `xml:"title"`
+	Link       string        `xml:"link,omitempty"`
+	Guid       rssGuid       `xml:"guid"`
+	PubDate    string        `xml:"pubDate"`
+	Creators   []string      `xml:"dc:creator"`
+	Categories []string      `xml:"category"`
+	Desc       string        `xml:"description,omitempty"`
+	Enclosure  *rssEnclosure `xml:"enclosure"`
+}
+
+type rssSelfLink struct{
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssChannel struct{
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Desc          string      `xml:"description"`
+	Self          rssSelfLink `xml:"atom:link"`
+	LastBuildDate string      `xml:"lastBuildDate"`
+	Generator     string      `xml:"generator"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssFeed struct{
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DcNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+func rssTime(t time.Time)(string){
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+// RSS renders the feed as RSS 2.0, the content type is `application/rss+xml`
+func (f *Feed)RSS()([]byte, error){
+	link := f.Link
+	if len(link) == 0 {
+		link = f.Self
+	}
+	feed := rssFeed{
+		Version: "2.0",
+		AtomNS: "http://www.w3.org/2005/Atom",
+		DcNS: "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title: f.Title,
+			Link: link,
+			Desc: f.Desc,
+			Self: rssSelfLink{ Href: f.Self, Rel: "self", Type: "application/rss+xml" },
+			LastBuildDate: rssTime(f.Updated),
+			Generator: "PluginWebPoint",
+			Items: make([]rssItem, len(f.Entries)),
+		},
+	}
+	for i, e := range f.Entries {
+		item := rssItem{
+			Title: e.Title,
+			Link: e.Link,
+			Guid: rssGuid{ Body: e.Id },
+			PubDate: rssTime(e.Published),
+			Creators: e.Authors,
+			Categories: e.Categories,
+			Desc: e.Summary,
+		}
+		if len(e.Enclosure) > 0 {
+			item.Enclosure = &rssEnclosure{ Url: e.Enclosure, Length: e.EnclosureLength, Type: e.EnclosureType }
+		}
+		feed.Channel.Items[i] = item
+	}
+	return marshalXML(feed)
+}
+
+func marshalXML(v any)([]byte, error){
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package api_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func newTestFeed()(*api.Feed){
+	uploaded := time.Date(2023, 5, 1, 8, 30, 0, 0, time.UTC)
+	items := []*api.FeedRelease{
+		{
+			Plugin: &api.PluginInfo{
+				Id: "hello_world",
+				Name: "Hello World",
+				Authors: []string{"Alice", "Bob"},
+				Desc: "Say hello <world>",
+				Desc_zhCN: "你好世界",
+				Labels: api.PluginLabels{ Tool: true, Api: true },
+			},
+			Release: &api.PluginRelease{
+				Tag: api.Version{ Comps: []int{1, 2, 0} },
+				Name: "Hello World v1.2.0",
+				Stable: true,
+				Size: 1024,
+				Uploaded: uploaded,
+				FileName: "HelloWorld-v1.2.0.mcdr",
+			},
+			Changelog: "- Fix & improve",
+		},
+		{
+			Plugin: &api.PluginInfo{ Id: "hello_world", Name: "Hello World", Authors: []string{"Alice"} },
+			Release: &api.PluginRelease{
+				Tag: api.Version{ Comps: []int{1, 1, 0} },
+				Uploaded: uploaded.Add(-time.Hour * 24),
+			},
+		},
+	}
+	return api.NewReleaseFeed("https://example.com", "Releases of Hello World", "/plugin/hello_world/",
+		"https://example.com/v1/feeds/plugin/hello_world/releases.atom", items, "zh_cn")
+}
+
+func TestReleaseFeed(t *testing.T){
+	f := newTestFeed()
+	if want := time.Date(2023, 5, 1, 8, 30, 0, 0, time.UTC); !f.Updated.Equal(want) {
+		t.Errorf("Feed updated time is %v, expect %v", f.Updated, want)
+	}
+	if len(f.Entries) != 2 {
+		t.Fatalf("Expect 2 entries, got %d", len(f.Entries))
+	}
+	e := f.Entries[0]
+	if e.Title != "Hello World v1.2.0" {
+		t.Errorf("Unexpected title %q", e.Title)
+	}
+	if e.Summary != "你好世界" {
+		t.Errorf("Expect the Chinese description, got %q", e.Summary)
+	}
+	if e.Enclosure != "https://example.com/download/hello_world/1.2.0/HelloWorld-v1.2.0.mcdr" {
+		t.Errorf("Unexpected enclosure %q", e.Enclosure)
+	}
+	if strings.Join(e.Categories, ",") != "tool,api" {
+		t.Errorf("Unexpected categories %v", e.Categories)
+	}
+	if e := f.Entries[1]; e.Title != "Hello World v1.1.0 (prerelease)" || len(e.Enclosure) != 0 {
+		t.Errorf("Unexpected entry %q with enclosure %q", e.Title, e.Enclosure)
+	}
+}
+
+func TestFeedAtom(t *testing.T){
+	data, err := newTestFeed().Atom()
+	if err != nil {
+		t.Fatalf("Cannot render atom: %v", err)
+	}
+	var feed struct{
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Updated string `xml:"updated"`
+		Entries []struct{
+			Id string `xml:"id"`
+			Summary string `xml:"summary"`
+			Content string `xml:"content"`
+			Authors []string `xml:"author>name"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		t.Fatalf("Cannot parse the rendered atom: %v\n%s", err, data)
+	}
+	if feed.Updated != "2023-05-01T08:30:00Z" {
+		t.Errorf("Unexpected updated time %q", feed.Updated)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("Expect 2 entries, got %d", len(feed.Entries))
+	}
+	e := feed.Entries[0]
+	if e.Id != "urn:pwp:release:hello_world:1.2.0" || e.Content != "- Fix & improve" || len(e.Authors) != 2 {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+}
+
+func TestFeedRSS(t *testing.T){
+	data, err := newTestFeed().RSS()
+	if err != nil {
+		t.Fatalf("Cannot render rss: %v", err)
+	}
+	var feed struct{
+		XMLName xml.Name `xml:"rss"`
+		Version string `xml:"version,attr"`
+		Items []struct{
+			Guid string `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+			Desc string `xml:"description"`
+			Enclosure struct{
+				Url string `xml:"url,attr"`
+				Length int64 `xml:"length,attr"`
+			} `xml:"enclosure"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		t.Fatalf("Cannot parse the rendered rss: %v\n%s", err, data)
+	}
+	if feed.Version != "2.0" || len(feed.Items) != 2 {
+		t.Fatalf("Unexpected rss %s", data)
+	}
+	item := feed.Items[0]
+	if item.PubDate != "Mon, 01 May 2023 08:30:00 +0000" {
+		t.Errorf("Unexpected pubDate %q", item.PubDate)
+	}
+	if item.Enclosure.Length != 1024 {
+		t.Errorf("Unexpected enclosure %+v", item.Enclosure)
+	}
+}
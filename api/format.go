@@ -0,0 +1,312 @@
+
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The response formats of the list and the information routes
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+	FormatYAML   = "yaml"
+)
+
+// FormatContentTypes are the content types of the formats
+var FormatContentTypes = map[string]string{
+	FormatJSON: "application/json; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson; charset=utf-8",
+	FormatCSV: "text/csv; charset=utf-8; header=present",
+	FormatYAML: "application/yaml; charset=utf-8",
+}
+
+// the media types in `Accept` that select the formats
+var formatMediaTypes = map[string]string{
+	"application/json": FormatJSON,
+	"application/*": FormatJSON,
+	"*/*": FormatJSON,
+	"application/x-ndjson": FormatNDJSON,
+	"application/jsonl": FormatNDJSON,
+	"text/csv": FormatCSV,
+	"application/yaml": FormatYAML,
+	"application/x-yaml": FormatYAML,
+	"text/yaml": FormatYAML,
+}
+
+// NegotiateFormat returns the format named by the `format` parameter,
+// or the one most preferred by the `Accept` header if the parameter is empty.
+// FormatJSON is returned if there is no acceptable format
+func NegotiateFormat(accept string, param string)(format string, err error){
+	if len(param) > 0 {
+		param = strings.ToLower(param)
+		if _, ok := FormatContentTypes[param]; !ok {
+			return "", fmt.Errorf("Unknown format %q, expect one of json, ndjson, csv and yaml", param)
+		}
+		return param, nil
+	}
+	format = FormatJSON
+	best, wildcard := 0.0, true
+	for _, item := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		f, ok := formatMediaTypes[typ]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		// an explicit type takes precedence over the wildcards with the same quality
+		isWildcard := strings.HasSuffix(typ, "*")
+		if q > best || (q == best && q > 0 && wildcard && !isWildcard) {
+			format, best, wildcard = f, q, isWildcard
+		}
+	}
+	return format, nil
+}
+
+// PluginCSVColumns are the columns of the plugins in CSV
+var PluginCSVColumns = []string{
+	"id", "name", "version", "authors", "desc", "desc_zhCN", "createAt", "lastRelease",
+	"repo", "link", "labels", "downloads", "localDownloads", "dependencies", "requirements", "github_sync",
+}
+
+// ReleaseCSVColumns are the columns of the releases in CSV
+var ReleaseCSVColumns = []string{
+	"id", "tag", "name", "enabled", "stable", "size", "uploaded", "filename",
+	"downloads", "localDownloads", "github_url", "sha256", "assets",
+}
+
+// IdCSVColumns is the column of the plugin ids in CSV
+var IdCSVColumns = []string{"id"}
+
+func formatCSVTime(t *time.Time)(string){
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatCSVMap joins the entries of the map in the order of the keys, e.g. `a>=1.0; b`
+func formatCSVMap[V any](m map[string]V, format func(V)(string))(string){
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if v := format(m[k]); len(v) > 0 {
+			keys[i] = k + v
+		}
+	}
+	return strings.Join(keys, "; ")
+}
+
+func pluginCSVRecord(info *PluginInfo)([]string){
+	return []string{
+		info.Id,
+		info.Name,
+		info.Version.String(),
+		strings.Join(info.Authors, ", "),
+		info.Desc,
+		info.Desc_zhCN,
+		formatCSVTime(&info.CreateAt),
+		formatCSVTime(info.LastRelease),
+		info.Repo,
+		info.Link,
+		strings.Join(info.Labels.Names(), ", "),
+		strconv.FormatInt(info.Downloads, 10),
+		strconv.FormatInt(info.LocalDownloads, 10),
+		formatCSVMap(info.Dependencies, VersionCondList.String),
+		formatCSVMap(info.Requirements, func(v string)(string){ return v }),
+		strconv.FormatBool(info.GithubSync),
+	}
+}
+
+func releaseCSVRecord(release *PluginRelease)([]string){
+	assets := make([]string, len(release.Assets))
+	for i, a := range release.Assets {
+		assets[i] = a.Name
+	}
+	return []string{
+		release.Id,
+		release.Tag.String(),
+		release.Name,
+		strconv.FormatBool(release.Enabled),
+		strconv.FormatBool(release.Stable),
+		strconv.FormatInt(release.Size, 10),
+		formatCSVTime(&release.Uploaded),
+		release.FileName,
+		strconv.Itoa(release.Downloads),
+		strconv.Itoa(release.LocalDownloads),
+		release.GithubUrl,
+		release.Sha256,
+		strings.Join(assets, ", "),
+	}
+}
+
+// CSVEncoder writes the plugins, the releases or the plugin ids as the flat CSV records,
+// the header is written before the first record, or when it's flushed if there is no record
+type CSVEncoder struct{
+	w *csv.Writer
+	columns []string
+	wroteHeader bool
+}
+
+func NewCSVEncoder(w io.Writer, columns []string)(*CSVEncoder){
+	return &CSVEncoder{
+		w: csv.NewWriter(w),
+		columns: columns,
+	}
+}
+
+func (e *CSVEncoder)writeHeader()(err error){
+	if e.wroteHeader {
+		return
+	}
+	e.wroteHeader = true
+	return e.w.Write(e.columns)
+}
+
+// Encode writes a record of v, which must be a *PluginInfo, a *PluginRelease or a plugin id
+func (e *CSVEncoder)Encode(v any)(err error){
+	var record []string
+	switch v := v.(type) {
+	case *PluginInfo:
+		record = pluginCSVRecord(v)
+	case *PluginRelease:
+		record = releaseCSVRecord(v)
+	case string:
+		record = []string{v}
+	default:
+		return fmt.Errorf("Cannot encode %T as CSV", v)
+	}
+	if err = e.writeHeader(); err != nil {
+		return
+	}
+	return e.w.Write(record)
+}
+
+// WriteError writes the trailer record `#error,<name>,<message>` and flushes,
+// it's used when the records cannot be completed after some of them are sent
+func (e *CSVEncoder)WriteError(name string, message string)(err error){
+	if err = e.writeHeader(); err != nil {
+		return
+	}
+	if err = e.w.Write([]string{"#error", name, message}); err != nil {
+		return
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *CSVEncoder)Flush()(err error){
+	if err = e.writeHeader(); err != nil {
+		return
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// CSVColumnsOf returns the CSV columns of the value or the elements of the slice
+func CSVColumnsOf(v any)([]string){
+	switch v.(type) {
+	case *PluginInfo, []*PluginInfo:
+		return PluginCSVColumns
+	case *PluginRelease, []*PluginRelease:
+		return ReleaseCSVColumns
+	case string, []string:
+		return IdCSVColumns
+	}
+	return nil
+}
+
+// eachElem calls fn with each element if v is a slice, or with v itself if it's not
+func eachElem(v any, fn func(any)(error))(err error){
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return fn(v)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err = fn(rv.Index(i).Interface()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// restyleYAML clears the JSON styles of the nodes, so they are encoded as block YAML
+func restyleYAML(node *yaml.Node){
+	node.Style = 0
+	for _, n := range node.Content {
+		restyleYAML(n)
+	}
+}
+
+// MarshalYAML encodes v as YAML with the same field names and order as its JSON
+func MarshalYAML(v any)(_ []byte, err error){
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	var node yaml.Node
+	// JSON is a subset of YAML
+	if err = yaml.Unmarshal(data, &node); err != nil {
+		return
+	}
+	restyleYAML(&node)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(&node); err != nil {
+		return
+	}
+	if err = enc.Close(); err != nil {
+		return
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalFormat encodes v in the format other than FormatJSON.
+// The slices are encoded as a record per element in NDJSON and CSV
+func MarshalFormat(format string, v any)(_ []byte, err error){
+	var buf bytes.Buffer
+	switch format {
+	case FormatNDJSON:
+		enc := json.NewEncoder(&buf)
+		if err = eachElem(v, enc.Encode); err != nil {
+			return
+		}
+	case FormatCSV:
+		enc := NewCSVEncoder(&buf, CSVColumnsOf(v))
+		if err = eachElem(v, enc.Encode); err != nil {
+			return
+		}
+		if err = enc.Flush(); err != nil {
+			return
+		}
+	case FormatYAML:
+		return MarshalYAML(v)
+	default:
+		return nil, fmt.Errorf("Cannot marshal the format %q", format)
+	}
+	return buf.Bytes(), nil
+}
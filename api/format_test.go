@@ -0,0 +1,84 @@
+package api_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestNegotiateFormat(t *testing.T){
+	cases := []struct{
+		accept, param string
+		format string
+	}{
+		{ "", "", "json" },
+		{ "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "", "json" },
+		{ "text/csv", "", "csv" },
+		{ "*/*, text/csv", "", "csv" },
+		{ "application/json, text/csv", "", "json" },
+		{ "application/json;q=0.5, application/x-ndjson", "", "ndjson" },
+		{ "application/yaml;q=0.9, */*;q=0.1", "", "yaml" },
+		{ "text/csv;q=0", "", "json" },
+		{ "text/csv", "YAML", "yaml" },
+		{ "image/png", "", "json" },
+	}
+	for _, c := range cases {
+		format, err := api.NegotiateFormat(c.accept, c.param)
+		if err != nil || format != c.format {
+			t.Errorf("Negotiated %q for %q and %q, expect %q: %v", format, c.accept, c.param, c.format, err)
+		}
+	}
+	if _, err := api.NegotiateFormat("", "xml"); err == nil {
+		t.Errorf("Expect an error for the unknown format")
+	}
+}
+
+func TestMarshalFormat(t *testing.T){
+	v, err := api.VersionFromString("1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugins := []*api.PluginInfo{
+		{
+			Id: "hello", Name: "Hello, World", Version: v, Authors: []string{"alice", "bob"},
+			Desc: "Say \"hello\"\nto everyone", CreateAt: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+			Labels: api.PluginLabels{ Tool: true, Api: true },
+			Requirements: api.RequireMap{ "requests": ">=2.0", "yaml": "" },
+			Downloads: 10,
+		},
+		{ Id: "empty", Version: v },
+	}
+
+	data, err := api.MarshalFormat(api.FormatCSV, plugins)
+	if err != nil {
+		t.Fatalf("Cannot marshal as CSV: %v", err)
+	}
+	lines := strings.SplitN(string(data), "\n", 2)
+	if lines[0] != strings.Join(api.PluginCSVColumns, ",") {
+		t.Errorf("Unexpected CSV header %q", lines[0])
+	}
+	expect := `hello,"Hello, World",1.2.0,"alice, bob","Say ""hello""` + "\n" + `to everyone",,2023-05-01T00:00:00Z,,,,"tool, api",10,0,,requests>=2.0; yaml,false` + "\n" +
+		`empty,,1.2.0,,,,,,,,,0,0,,,false` + "\n"
+	if lines[1] != expect {
+		t.Errorf("Unexpected CSV records:\n%s\nexpect:\n%s", lines[1], expect)
+	}
+	if data, err = api.MarshalFormat(api.FormatCSV, []string{}); err != nil || string(data) != "id\n" {
+		t.Errorf("Unexpected CSV of the empty list %q: %v", data, err)
+	}
+
+	if data, err = api.MarshalFormat(api.FormatNDJSON, []string{"a", "b"}); err != nil || string(data) != "\"a\"\n\"b\"\n" {
+		t.Errorf("Unexpected NDJSON %q: %v", data, err)
+	}
+	if data, err = api.MarshalFormat(api.FormatNDJSON, plugins[1]); err != nil || strings.Count(string(data), "\n") != 1 {
+		t.Errorf("Unexpected NDJSON of an object %q: %v", data, err)
+	}
+
+	if data, err = api.MarshalFormat(api.FormatYAML, plugins[1]); err != nil {
+		t.Fatalf("Cannot marshal as YAML: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "id: empty\nname: \"\"\nversion: 1.2.0\n") {
+		t.Errorf("Unexpected YAML:\n%s", data)
+	}
+}
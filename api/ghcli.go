@@ -33,6 +33,7 @@ type resCache struct{
 
 type GhClient struct{
 	cli *http.Client
+	dlCli *http.Client
 	appId     string
 	appSecret string
 	token *GToken
@@ -62,6 +63,7 @@ func InitGithubCli()(c *GhClient){
 		cli: &http.Client{
 			Timeout: time.Second * 5,
 		},
+		dlCli: &http.Client{},
 		appId: os.Getenv("GH_CLI_ID"),
 		appSecret: os.Getenv("GH_CLI_SEC"),
 		getCache: make(map[string]resCache),
@@ -210,6 +212,42 @@ func (c *GhClient)Get(url string)(*http.Response, error){
 	return c.GetWithContext(context.Background(), url)
 }
 
+// Download requests the url without the client timeout and the response cache, it's used to download large files.
+// The lifetime of the request should be controlled by the context
+func (c *GhClient)Download(ctx context.Context, url string)(res *http.Response, err error){
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", "PluginWebPoint-App")
+	if c.token != nil {
+		req.Header.Set("Authorization", c.token.GetAuth())
+	}else if len(c.appSecret) > 0 {
+		req.SetBasicAuth(c.appId, c.appSecret)
+	}
+	if res, err = c.dlCli.Do(req); err != nil {
+		return
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, &StatusCodeErr{ Code: res.StatusCode }
+	}
+	return
+}
+
+// PurgeCache removes the cached responses which url has the prefix, so they will be fetched again
+func (c *GhClient)PurgeCache(prefix string)(n int){
+	c.getChMux.Lock()
+	defer c.getChMux.Unlock()
+	for url := range c.getCache {
+		if strings.HasPrefix(url, prefix) {
+			delete(c.getCache, url)
+			n++
+		}
+	}
+	return
+}
+
 type bytesReadCloser struct{
 	*bytes.Reader
 }
@@ -226,7 +264,7 @@ func (c *GhClient)GetWithContext(ctx context.Context, url string)(res *http.Resp
 	c.getChMux.RUnlock()
 	if eok {
 		if len(cache.Etag) > 0 {
-			req.Header.Set("If-Not-Match", cache.Etag)
+			req.Header.Set("If-None-Match", cache.Etag)
 		}
 		if len(cache.ModTime) > 0 {
 			req.Header.Set("If-Modified-Since", cache.ModTime)
@@ -249,6 +287,13 @@ func (c *GhClient)GetWithContext(ctx context.Context, url string)(res *http.Resp
 		res.Body.Close()
 		if eok && res.StatusCode == http.StatusNotModified {
 			loger.Debugf("Cached %q data", url)
+			// the 304 response may omit the validators, restore them from the cache
+			if len(cache.ModTime) > 0 {
+				res.Header.Set("Last-Modified", cache.ModTime)
+			}
+			if len(cache.Etag) > 0 {
+				res.Header.Set("Etag", cache.Etag)
+			}
 			res.Body = bytesReadCloser{bytes.NewReader(cache.Body)}
 			return
 		}
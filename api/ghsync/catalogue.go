@@ -0,0 +1,194 @@
+
+package ghsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+var (
+	Target string = "https://github.com/MCDReforged/PluginCatalogue"
+	targetRaw string = "https://raw.githubusercontent.com/MCDReforged/PluginCatalogue/" // meta/{{plugin_id}}/meta.json
+)
+
+type Author struct{
+	Name string `json:"name"`
+	Link string `json:"link"`
+}
+
+type Labels []string
+
+func (l Labels)HasInformation()(bool){
+	for _, a := range l {
+		if a == "information" {
+			return true
+		}
+	}
+	return false
+}
+
+func (l Labels)HasTool()(bool){
+	for _, a := range l {
+		if a == "tool" {
+			return true
+		}
+	}
+	return false
+}
+
+func (l Labels)HasManagement()(bool){
+	for _, a := range l {
+		if a == "management" {
+			return true
+		}
+	}
+	return false
+}
+
+func (l Labels)HasAPI()(bool){
+	for _, a := range l {
+		if a == "api" {
+			return true
+		}
+	}
+	return false
+}
+
+type PluginInfo struct{
+	Disable bool `json:"disable"`
+	Id string `json:"id"`
+	Authors []Author `json:"authors"`
+	Repo string `json:"repository"`
+	Branch string `json:"branch"`
+	RelatedPath string `json:"related_path"`
+	Labels Labels `json:"labels"`
+}
+
+type DependMap = map[string]api.VersionCondList
+type Requirements = []string
+
+type PluginMeta struct{
+	Id string `json:"id"`
+	Name string `json:"name"`
+	Version string `json:"version"`
+	Repo string `json:"repository"`
+	Branch string `json:"branch"`
+	RelatedPath string `json:"related_path"`
+	Authors []string `json:"authors"`
+	Deps DependMap `json:"dependencies"`
+	Reqs Requirements `json:"requirements"`
+	Desc any `json:"description"`
+}
+
+type Asset struct{
+	Name string `json:"name"`
+	Size int64 `json:"size"`
+	DownloadCount int64 `json:"download_count"`
+	Url string `json:"url"`
+	CreateAt time.Time `json:"created_at"`
+	BrowserDownloadUrl string `json:"browser_download_url"`
+}
+
+type Release struct{
+	Name string `json:"name"`
+	TagName string `json:"tag_name"`
+	CreateAt time.Time `json:"created_at"`
+	Assets []Asset `json:"assets"`
+	Description string `json:"description"`
+	Prerelease bool `json:"prerelease"`
+	ParsedVersion string `json:"parsed_version"`
+	Meta any `json:"meta"`
+}
+
+// Stable reports whether the release is not a prerelease, it's the value of the `stable` column
+func (r *Release)Stable()(bool){
+	return !r.Prerelease
+}
+
+const CurrenReleaseSchemaVersion = 7
+type PluginRelease struct{
+	SchemaVersion int `json:"schema_version"`
+	Id string `json:"id"`
+	LatestVersion string `json:"latest_version"`
+	Releases []Release `json:"releases"`
+}
+
+func GetPluginMetaJson(id string)(meta PluginMeta, err error){
+	p, err := url.JoinPath(targetRaw, "meta", id, "meta.json")
+	if err != nil {
+		return
+	}
+	loger.Infof("Getting %q", p)
+	resp, err := http.DefaultClient.Get(p)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &meta); err != nil {
+		return
+	}
+	return
+}
+
+func GetPluginReleaseJson(id string)(meta PluginRelease, err error){
+	p, err := url.JoinPath(targetRaw, "meta", id, "release.json")
+	if err != nil {
+		return
+	}
+	loger.Infof("Getting %q", p)
+	resp, err := http.DefaultClient.Get(p)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &meta); err != nil {
+		return
+	}
+	if meta.SchemaVersion != CurrenReleaseSchemaVersion {
+		err = fmt.Errorf("Unexpect schema version %d, expect %d", meta.SchemaVersion, CurrenReleaseSchemaVersion)
+		return
+	}
+	return
+}
+
+// GetPluginInfoJson fetches the plugin_info.json of the plugin from the catalogue repo
+func GetPluginInfoJson(id string)(info PluginInfo, err error){
+	p, err := url.JoinPath(targetRaw, "master", "plugins", id, "plugin_info.json")
+	if err != nil {
+		return
+	}
+	loger.Infof("Getting %q", p)
+	resp, err := http.DefaultClient.Get(p)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return info, api.ErrNotFound
+		}
+		return info, &api.StatusCodeErr{ Code: resp.StatusCode }
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, &info); err != nil {
+		return
+	}
+	return
+}
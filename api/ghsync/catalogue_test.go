@@ -0,0 +1,25 @@
+package ghsync_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kmcsr/PluginWebPoint/api/ghsync"
+)
+
+func TestReleaseStable(t *testing.T){
+	// the `stable` column is the opposite of the github prerelease flag
+	for data, stable := range map[string]bool{
+		`{"tag_name":"v1.0.0","prerelease":false}`: true,
+		`{"tag_name":"v1.1.0-beta","prerelease":true}`: false,
+		`{"tag_name":"v1.2.0"}`: true,
+	} {
+		var release ghsync.Release
+		if err := json.Unmarshal(([]byte)(data), &release); err != nil {
+			t.Fatalf("Cannot parse %s: %v", data, err)
+		}
+		if release.Stable() != stable {
+			t.Errorf("Stable of %s is %v, expect %v", data, release.Stable(), stable)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+
+package ghsync
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+// the hashes cover the public fields of the plugins and the releases, except the download counts
+const pluginHashExpr = "SHA1(CONCAT_WS(0x1f,p.`name`,p.`version`,p.`authors`,p.`desc`,p.`desc_zhCN`," +
+	"p.`repo`,p.`repo_branch`,p.`repo_subdir`,p.`link`," +
+	"p.`label_information`,p.`label_tool`,p.`label_management`,p.`label_api`,p.`lastRelease`," +
+	"(SELECT GROUP_CONCAT(`target`,'@',`tag` ORDER BY `target`) FROM plugin_dependencies WHERE `id`=p.`id`)," +
+	"(SELECT GROUP_CONCAT(`target`,'@',`tag` ORDER BY `target`) FROM plugin_requirements WHERE `id`=p.`id`)))"
+const releaseHashExpr = "SHA1(CONCAT_WS(0x1f,r.`stable`,r.`size`,r.`uploaded`,r.`filename`,r.`github_url`,r.`name`,r.`changelog`," +
+	"(SELECT GROUP_CONCAT(`name`,':',`size` ORDER BY `name`) FROM plugin_release_assets WHERE `id`=r.`id` AND `tag`=r.`tag`)))"
+
+// nextSeq allocates the next sequence number of the catalogue changes and events.
+// The counter row is locked until the transaction ends, so the numbers are committed in order,
+// and the readers that poll with the last seen number never miss the ones committed later
+func nextSeq(tx *sql.Tx)(seq int64, err error){
+	const updateCmd = "UPDATE catalogue_seq SET `seq`=LAST_INSERT_ID(`seq`+1) WHERE `id`=1"
+
+	var res sql.Result
+	if res, err = ExecTx(tx, updateCmd); err != nil {
+		return
+	}
+	return res.LastInsertId()
+}
+
+func queryRecordedChanges(ctx context.Context, tx *sql.Tx, id string)(states map[string]api.ChangeState, err error){
+	const queryCmd = "SELECT `tag`,`op`,`hash` FROM catalogue_changes WHERE `id`=?"
+
+	var rows *sql.Rows
+	if rows, err = tx.QueryContext(ctx, queryCmd, id); err != nil {
+		return
+	}
+	defer rows.Close()
+	states = make(map[string]api.ChangeState)
+	for rows.Next() {
+		var (
+			tag string
+			s api.ChangeState
+			hash sql.NullString
+		)
+		if err = rows.Scan(&tag, &s.Op, &hash); err != nil {
+			return
+		}
+		s.Hash = hash.String
+		states[tag] = s
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
+
+func queryCurrentStates(ctx context.Context, tx *sql.Tx, id string)(states map[string]api.ChangeState, err error){
+	const queryPluginCmd = "SELECT p.`enabled`," + pluginHashExpr + " FROM plugins AS p WHERE p.`id`=?"
+	const queryReleasesCmd = "SELECT r.`tag`,r.`enabled`," + releaseHashExpr + " FROM plugin_releases AS r WHERE r.`id`=?"
+
+	states = make(map[string]api.ChangeState)
+	var (
+		enabled bool
+		hash string
+	)
+	if err = tx.QueryRowContext(ctx, queryPluginCmd, id).Scan(&enabled, &hash); err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		return
+	}
+	if !enabled {
+		states[""] = api.ChangeState{ Op: api.ChangeOpDelete }
+		return
+	}
+	states[""] = api.ChangeState{ Op: api.ChangeOpUpsert, Hash: hash }
+
+	var rows *sql.Rows
+	if rows, err = tx.QueryContext(ctx, queryReleasesCmd, id); err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		if err = rows.Scan(&tag, &enabled, &hash); err != nil {
+			return
+		}
+		if enabled {
+			states[tag] = api.ChangeState{ Op: api.ChangeOpUpsert, Hash: hash }
+		}else{
+			states[tag] = api.ChangeState{ Op: api.ChangeOpDelete }
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
+
+// RecordChanges compares the plugin and its releases with the change log, and records the changed ones.
+// It should be called in the same transaction after the plugin is written or deleted
+func RecordChanges(ctx context.Context, tx *sql.Tx, id string)(err error){
+	const replaceCmd = "REPLACE INTO catalogue_changes (`seq`,`kind`,`op`,`id`,`tag`,`hash`,`time`) VALUES (?,?,?,?,?,?,NOW())"
+	const removeReleasesCmd = "DELETE FROM catalogue_changes WHERE `id`=? AND `kind`='release'"
+
+	var recorded, current map[string]api.ChangeState
+	if recorded, err = queryRecordedChanges(ctx, tx, id); err != nil {
+		return
+	}
+	if current, err = queryCurrentStates(ctx, tx, id); err != nil {
+		return
+	}
+	for _, c := range api.DiffChanges(id, recorded, current) {
+		var hash sql.NullString
+		if len(c.Hash) > 0 {
+			hash.String, hash.Valid = c.Hash, true
+		}
+		if c.Seq, err = nextSeq(tx); err != nil {
+			return
+		}
+		if _, err = ExecTx(tx, replaceCmd, c.Seq, c.Kind, c.Op, c.Plugin, c.Tag, hash); err != nil {
+			return
+		}
+		// the deleted plugin implies its releases are deleted
+		if c.Kind == api.ChangeKindPlugin && c.Op == api.ChangeOpDelete {
+			if _, err = ExecTx(tx, removeReleasesCmd, id); err != nil {
+				return
+			}
+		}
+		loger.Debugf("[%s] Change %s %s %s", id, c.Op, c.Kind, c.Tag)
+	}
+	return
+}
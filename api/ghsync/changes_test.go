@@ -0,0 +1,23 @@
+package ghsync
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestInitHashExprs(t *testing.T){
+	// the migration seeds the change log with the same hashes, or every plugin is reported as changed by the first sync
+	data, err := os.ReadFile("../../init.sql")
+	if err != nil {
+		t.Fatalf("Cannot read init.sql: %v", err)
+	}
+	for name, expr := range map[string]string{
+		"pluginHashExpr": pluginHashExpr,
+		"releaseHashExpr": releaseHashExpr,
+	} {
+		if !strings.Contains((string)(data), expr) {
+			t.Errorf("The seed of catalogue_changes in init.sql does not use %s:\n%s", name, expr)
+		}
+	}
+}
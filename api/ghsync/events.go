@@ -0,0 +1,129 @@
+
+package ghsync
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+// RecordEvent writes the event into the catalogue event log,
+// and queues the deliveries for the webhooks that subscribed the event
+func RecordEvent(tx *sql.Tx, ev *api.CatalogueEvent)(err error){
+	const insertEventCmd = "INSERT INTO catalogue_events (`seq`,`type`,`id`,`tag`,`time`,`data`) VALUES (?,?,?,?,NOW(),?)"
+	const queueCmd = "INSERT INTO webhook_deliveries (`webhook`,`event`,`status`,`attempts`,`next_attempt`,`updated`)" +
+		" SELECT `id`,?,'pending',0,NOW(),NOW() FROM webhooks WHERE `events`='' OR FIND_IN_SET(?,`events`)"
+
+	var data sql.NullString
+	if ev.Data != nil {
+		var buf []byte
+		if buf, err = json.Marshal(ev.Data); err != nil {
+			return
+		}
+		data.String, data.Valid = (string)(buf), true
+	}
+	var tag sql.NullString
+	if len(ev.Tag) > 0 {
+		tag.String, tag.Valid = ev.Tag, true
+	}
+	ev.Time = time.Now()
+	if ev.Seq, err = nextSeq(tx); err != nil {
+		return
+	}
+	if _, err = ExecTx(tx, insertEventCmd, ev.Seq, ev.Type, ev.Plugin, tag, data); err != nil {
+		return
+	}
+	if _, err = ExecTx(tx, queueCmd, ev.Seq, ev.Type); err != nil {
+		return
+	}
+	loger.Infof("[%s] Event %s %s", ev.Plugin, ev.Type, ev.Tag)
+	return
+}
+
+// pluginSnapshot is the metadata compared to detect the metadata_changed events.
+// The version is not included since it's changed with every new release, which is reported by release_added
+type pluginSnapshot struct{
+	Name       string
+	Authors    string
+	Desc       string
+	Desc_zhCN  string
+	Repo       string
+	RepoBranch string
+	RepoSubdir string
+	Labels     [4]bool
+}
+
+func queryPluginSnapshot(ctx context.Context, tx *sql.Tx, id string)(s *pluginSnapshot, err error){
+	const queryCmd = "SELECT `name`,`authors`,`desc`,`desc_zhCN`,`repo`,`repo_branch`,`repo_subdir`," +
+		"`label_information`,`label_tool`,`label_management`,`label_api`" +
+		" FROM plugins WHERE `id`=?"
+
+	s = new(pluginSnapshot)
+	if err = tx.QueryRowContext(ctx, queryCmd, id).Scan(&s.Name, &s.Authors, &s.Desc, &s.Desc_zhCN,
+		&s.Repo, &s.RepoBranch, &s.RepoSubdir, &s.Labels[0], &s.Labels[1], &s.Labels[2], &s.Labels[3]); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// changedFields returns the json names of the fields which are different from o
+func (s *pluginSnapshot)changedFields(o *pluginSnapshot)(fields []string){
+	if s.Name != o.Name {
+		fields = append(fields, "name")
+	}
+	if s.Authors != o.Authors {
+		fields = append(fields, "authors")
+	}
+	if s.Desc != o.Desc {
+		fields = append(fields, "desc")
+	}
+	if s.Desc_zhCN != o.Desc_zhCN {
+		fields = append(fields, "desc_zhCN")
+	}
+	if s.Repo != o.Repo {
+		fields = append(fields, "repo")
+	}
+	if s.RepoBranch != o.RepoBranch {
+		fields = append(fields, "repoBranch")
+	}
+	if s.RepoSubdir != o.RepoSubdir {
+		fields = append(fields, "repoSubdir")
+	}
+	if s.Labels != o.Labels {
+		fields = append(fields, "labels")
+	}
+	return
+}
+
+type releaseSnapshot struct{
+	Stable bool
+	Locked bool
+}
+
+func queryReleaseSnapshots(ctx context.Context, tx *sql.Tx, id string)(releases map[string]releaseSnapshot, err error){
+	const queryCmd = "SELECT `tag`,`stable`,`stable_locked` FROM plugin_releases WHERE `id`=?"
+
+	var rows *sql.Rows
+	if rows, err = tx.QueryContext(ctx, queryCmd, id); err != nil {
+		return
+	}
+	defer rows.Close()
+	releases = make(map[string]releaseSnapshot)
+	for rows.Next() {
+		var (
+			tag string
+			r releaseSnapshot
+		)
+		if err = rows.Scan(&tag, &r.Stable, &r.Locked); err != nil {
+			return
+		}
+		releases[tag] = r
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,25 @@
+package ghsync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedFields(t *testing.T){
+	old := &pluginSnapshot{ Name: "Hello", Authors: "alice", Labels: [4]bool{true, false, false, false} }
+	for _, c := range []struct{
+		modify func(s *pluginSnapshot)
+		fields []string
+	}{
+		{ func(s *pluginSnapshot){}, nil },
+		{ func(s *pluginSnapshot){ s.Name = "Hi" }, []string{"name"} },
+		{ func(s *pluginSnapshot){ s.Authors = "alice,bob"; s.Labels[1] = true }, []string{"authors", "labels"} },
+		{ func(s *pluginSnapshot){ s.RepoSubdir = "src" }, []string{"repoSubdir"} },
+	} {
+		current := *old
+		c.modify(&current)
+		if fields := current.changedFields(old); !reflect.DeepEqual(fields, c.fields) {
+			t.Errorf("Changed fields are %v, expect %v", fields, c.fields)
+		}
+	}
+}
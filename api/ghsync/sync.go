@@ -0,0 +1,347 @@
+
+package ghsync
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+var packageNameRe = regexp.MustCompile(`^[a-zA-Z_.-]+[a-zA-Z0-9_.-]*`)
+
+func ExecTx(tx *sql.Tx, cmd string, args ...any)(res sql.Result, err error){
+	loger.Debugf("Exec sql cmd: %s\n  args: %v", cmd, args)
+	for {
+		if res, err = tx.Exec(cmd, args...); err != nil {
+			if e, ok := err.(*mysql.MySQLError); ok {
+				switch e.Number {
+				case 1213:
+					continue
+				}
+			}
+		}
+		return
+	}
+}
+
+// SyncPlugin writes the plugin info, the metadata and the releases from the catalogue into the database.
+// The plugins that are not synced from github will be skipped
+func SyncPlugin(DB *sql.DB, info PluginInfo, meta PluginMeta, releases PluginRelease)(err error){
+	const queryGhSyncCmd = "SELECT `github_sync`" +
+		" FROM plugins WHERE `id`=?"
+	const insertCmd = "INSERT INTO plugins (`id`,`name`,`enabled`,`version`,`authors`,`desc`,`desc_zhCN`," +
+		"`repo`,`repo_branch`,`repo_subdir`,`link`," +
+		"`label_information`,`label_tool`,`label_management`,`label_api`," +
+		"`createAt`,`lastRelease`,`github_sync`,`ghRepoOwner`,`ghRepoName`,`last_sync`)" +
+		" VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,TRUE,?,?,?)"
+	const updateCmd = "UPDATE plugins SET " +
+			"`name`=?," +
+			"`enabled`=IF(`enabled_locked`,`enabled`,?)," +
+			"`version`=?," +
+			"`authors`=?," +
+			"`desc`=?," +
+			"`desc_zhCN`=?," +
+			"`repo`=?," +
+			"`repo_branch`=?," +
+			"`repo_subdir`=?," +
+			"`link`=?," +
+			"`label_information`=?," +
+			"`label_tool`=?," +
+			"`label_management`=?," +
+			"`label_api`=?," +
+			"`lastRelease`=?," +
+			"`ghRepoOwner`=?," +
+			"`ghRepoName`=?," +
+			"`last_sync`=?" +
+			" WHERE `id`=?"
+	const removeDepenceCmd = "DELETE FROM plugin_dependencies WHERE `id`=?"
+	const insertDepenceCmd = "INSERT INTO plugin_dependencies (`id`,`target`,`tag`)" +
+		" VALUES (?,?,?)"
+	const removeRequireCmd = "DELETE FROM plugin_requirements WHERE `id`=?"
+	const insertRequireCmd = "INSERT INTO plugin_requirements (`id`,`target`,`tag`)" +
+		" VALUES (?,?,?)"
+	// the checksums are calculated by the API when caching the asset,
+	// so they are only cleared when the asset is changed
+	const insertReleaseCmd = "INSERT INTO plugin_releases (`id`,`tag`,`enabled`,`stable`,`size`,`uploaded`,`filename`,`downloads`," +
+		"`github_url`,`name`,`changelog`)" +
+		" VALUES (?,?,TRUE,?,?,?,?,?,?,?,?)" +
+		" ON DUPLICATE KEY UPDATE" +
+		" `sha256`=IF(`github_url`<=>VALUES(`github_url`) AND `size`=VALUES(`size`),`sha256`,NULL)," +
+		"`sha1`=IF(`github_url`<=>VALUES(`github_url`) AND `size`=VALUES(`size`),`sha1`,NULL)," +
+		"`mcdr_meta`=IF(`github_url`<=>VALUES(`github_url`) AND `size`=VALUES(`size`),`mcdr_meta`,NULL)," +
+		"`problems`=IF(`github_url`<=>VALUES(`github_url`) AND `size`=VALUES(`size`),`problems`,NULL)," +
+		"`stable`=IF(`stable_locked`,`stable`,VALUES(`stable`)),`size`=VALUES(`size`),`uploaded`=VALUES(`uploaded`)," +
+		"`filename`=VALUES(`filename`),`downloads`=VALUES(`downloads`),`github_url`=VALUES(`github_url`)," +
+		"`name`=VALUES(`name`),`changelog`=VALUES(`changelog`)"
+	const insertSnapshotCmd = "INSERT INTO plugin_release_snapshots (`id`,`tag`,`time`,`downloads`)" +
+		" VALUES (?,?,?,?)"
+	const insertAssetCmd = "INSERT INTO plugin_release_assets (`id`,`tag`,`name`,`size`,`content_type`,`uploaded`,`downloads`,`github_url`)" +
+		" VALUES (?,?,?,?,?,?,?,?)" +
+		" ON DUPLICATE KEY UPDATE" +
+		" `sha256`=IF(`github_url`<=>VALUES(`github_url`) AND `size`=VALUES(`size`),`sha256`,NULL)," +
+		"`sha1`=IF(`github_url`<=>VALUES(`github_url`) AND `size`=VALUES(`size`),`sha1`,NULL)," +
+		"`size`=VALUES(`size`),`content_type`=VALUES(`content_type`),`uploaded`=VALUES(`uploaded`)," +
+		"`downloads`=VALUES(`downloads`),`github_url`=VALUES(`github_url`)"
+	const removeAssetsCmd = "DELETE FROM plugin_release_assets WHERE `id`=? AND `tag`=?"
+
+	nowt := time.Now()
+	now := nowt.Format("2006-01-02 15:04:05")
+
+	sort.Strings(meta.Authors)
+	var (
+		desc string
+		desc_zhCN string
+	)
+	if d, ok := meta.Desc.(string); ok {
+		desc = d
+	}else if m, ok := meta.Desc.(map[string]any); ok {
+		if d, ok := m["en_us"].(string); ok {
+			desc = d
+		}
+		if d, ok := m["zh_cn"].(string); ok {
+			desc_zhCN = d
+		}
+	}
+	if !strings.HasPrefix(info.Repo, "https://github.com/") {
+		err = fmt.Errorf("Unexpect repo link (missing gh prefix): %q", info.Repo)
+		return
+	}
+	var lastRelease sql.NullTime
+	{
+		for _, r := range releases.Releases {
+			t := r.CreateAt
+			if !lastRelease.Valid || t.After(lastRelease.Time) {
+				lastRelease.Valid = true
+				lastRelease.Time = t
+			}
+		}
+	}
+	var ghRepoOwner, ghRepoName string
+	{
+		b := info.Repo[len("https://github.com/"):]
+		if b[len(b) - 1] == '/' {
+			b = b[:len(b) - 1]
+		}
+		paths := strings.Split(b, "/")
+		if len(paths) <= 1 {
+			err = fmt.Errorf("Unexpect repo link (missing repo name): %q, expect 'https://github.com/{owner}/{name}'", info.Repo)
+			return
+		}
+		if len(paths) > 2 {
+			err = fmt.Errorf("Unexpect repo link (extra path): %q, expect 'https://github.com/{owner}/{name}'", info.Repo)
+			return
+		}
+		ghRepoOwner, ghRepoName = paths[0], paths[1]
+	}
+	link, err := url.JoinPath(info.Repo, "tree", info.Branch, info.RelatedPath)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 10)
+	defer cancel()
+
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var flag sql.NullBool
+	if err = conn.QueryRowContext(ctx, queryGhSyncCmd, info.Id).Scan(&flag); err != nil && err != sql.ErrNoRows {
+		return
+	}
+	if flag.Valid && !flag.Bool {
+		loger.Debugf("Plugin %s is not synced from github", info.Id)
+		return
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	current := &pluginSnapshot{
+		Name: meta.Name,
+		Authors: strings.Join(meta.Authors, ","),
+		Desc: desc,
+		Desc_zhCN: desc_zhCN,
+		Repo: info.Repo,
+		RepoBranch: info.Branch,
+		RepoSubdir: info.RelatedPath,
+		Labels: [4]bool{info.Labels.HasInformation(), info.Labels.HasTool(), info.Labels.HasManagement(), info.Labels.HasAPI()},
+	}
+	var (
+		events []*api.CatalogueEvent
+		oldReleases map[string]releaseSnapshot
+	)
+
+	if flag.Valid {
+		var old *pluginSnapshot
+		if old, err = queryPluginSnapshot(ctx, tx, info.Id); err != nil {
+			return
+		}
+		if fields := current.changedFields(old); len(fields) > 0 {
+			events = append(events, &api.CatalogueEvent{
+				Type: api.EventMetadataChanged,
+				Plugin: info.Id,
+				Data: map[string]any{
+					"fields": fields,
+				},
+			})
+		}
+		if oldReleases, err = queryReleaseSnapshots(ctx, tx, info.Id); err != nil {
+			return
+		}
+		loger.Infof("[%s] Updating metadata", info.Id)
+		if _, err = ExecTx(tx, updateCmd, meta.Name, !info.Disable, meta.Version,
+			strings.Join(meta.Authors, ","), desc, desc_zhCN,
+			info.Repo, info.Branch, info.RelatedPath, link,
+			info.Labels.HasInformation(), info.Labels.HasTool(), info.Labels.HasManagement(), info.Labels.HasAPI(),
+			lastRelease, ghRepoOwner, ghRepoName, now, info.Id); err != nil {
+			return
+		}
+		if _, err = ExecTx(tx, removeDepenceCmd, info.Id); err != nil {
+			return
+		}
+		if _, err = ExecTx(tx, removeRequireCmd, info.Id); err != nil {
+			return
+		}
+	}else{
+		loger.Infof("[%s] Insert into database", info.Id)
+		if _, err = ExecTx(tx, insertCmd, info.Id, meta.Name, !info.Disable, meta.Version,
+			strings.Join(meta.Authors, ","), desc, desc_zhCN,
+			info.Repo, info.Branch, info.RelatedPath, link,
+			info.Labels.HasInformation(), info.Labels.HasTool(), info.Labels.HasManagement(), info.Labels.HasAPI(),
+			now, lastRelease, ghRepoOwner, ghRepoName, now); err != nil {
+			return
+		}
+		events = append(events, &api.CatalogueEvent{
+			Type: api.EventPluginAdded,
+			Plugin: info.Id,
+			Data: map[string]any{
+				"name": meta.Name,
+				"version": meta.Version,
+			},
+		})
+	}
+	for id, cond := range meta.Deps {
+		if _, err = ExecTx(tx, insertDepenceCmd, info.Id, id, cond); err != nil {
+			return
+		}
+	}
+	for _, req := range meta.Reqs {
+		loger.Debugf("Parsing requirement %q", req)
+		id := packageNameRe.FindString(req)
+		if len(id) == 0 {
+			loger.Warnf("Cannot parse python package requirement: %q", req)
+			continue
+		}
+		cond := strings.ReplaceAll(req[len(id):], " ", "")
+		if _, err = ExecTx(tx, insertRequireCmd, info.Id, id, cond); err != nil {
+			return
+		}
+	}
+	for _, release := range releases.Releases {
+		// the first .mcdr file is the main file of the release, releases without it are ignored
+		var main *Asset
+		for i, asset := range release.Assets {
+			if strings.HasSuffix(asset.Name, ".mcdr") {
+				main = &release.Assets[i]
+				break
+			}
+		}
+		if main == nil {
+			continue
+		}
+		loger.Debugf("inserting asset: %v", *main)
+		if _, err = ExecTx(tx, insertReleaseCmd, info.Id, release.ParsedVersion, release.Stable(),
+			main.Size, main.CreateAt, main.Name, main.DownloadCount, main.BrowserDownloadUrl,
+			release.Name, release.Description); err != nil {
+			// loger.Errorf("Error when insert release into sql")
+			return
+		}
+		if _, err = ExecTx(tx, insertSnapshotCmd, info.Id, release.ParsedVersion, nowt, main.DownloadCount); err != nil {
+			return
+		}
+		// the releases of the new plugins are not reported, since the plugin_added event is already sent
+		if oldReleases != nil {
+			if old, ok := oldReleases[release.ParsedVersion]; !ok {
+				events = append(events, &api.CatalogueEvent{
+					Type: api.EventReleaseAdded,
+					Plugin: info.Id,
+					Tag: release.ParsedVersion,
+					Data: map[string]any{
+						"name": release.Name,
+						"stable": release.Stable(),
+						"filename": main.Name,
+					},
+				})
+			}else if !old.Stable && !old.Locked && release.Stable() {
+				// the stored flags are flipped by init.sql before the events table is created,
+				// so the releases synced by the old versions are not reported
+				events = append(events, &api.CatalogueEvent{
+					Type: api.EventReleaseStable,
+					Plugin: info.Id,
+					Tag: release.ParsedVersion,
+				})
+			}
+		}
+		// remove the assets that are deleted from the release
+		removeCmd := removeAssetsCmd
+		removeArgs := []any{info.Id, release.ParsedVersion}
+		if len(release.Assets) > 0 {
+			removeCmd += " AND `name` NOT IN (?" + strings.Repeat(",?", len(release.Assets) - 1) + ")"
+			for _, asset := range release.Assets {
+				removeArgs = append(removeArgs, asset.Name)
+			}
+		}
+		if _, err = ExecTx(tx, removeCmd, removeArgs...); err != nil {
+			return
+		}
+		for _, asset := range release.Assets {
+			if _, err = ExecTx(tx, insertAssetCmd, info.Id, release.ParsedVersion, asset.Name, asset.Size,
+				api.AssetContentType(asset.Name), asset.CreateAt, asset.DownloadCount, asset.BrowserDownloadUrl); err != nil {
+				return
+			}
+		}
+	}
+	if err = RecordChanges(ctx, tx, info.Id); err != nil {
+		return
+	}
+	for _, ev := range events {
+		if err = RecordEvent(tx, ev); err != nil {
+			return
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	return
+}
+
+// ResyncPlugin fetches the plugin from the catalogue and syncs it into the database
+func ResyncPlugin(DB *sql.DB, id string)(err error){
+	var info PluginInfo
+	if info, err = GetPluginInfoJson(id); err != nil {
+		return
+	}
+	var meta PluginMeta
+	if meta, err = GetPluginMetaJson(id); err != nil {
+		return
+	}
+	var releases PluginRelease
+	if releases, err = GetPluginReleaseJson(id); err != nil {
+		return
+	}
+	return SyncPlugin(DB, info, meta, releases)
+}
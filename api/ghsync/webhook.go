@@ -0,0 +1,170 @@
+
+package ghsync
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+var webhookClient = api.NewWebhookClient()
+
+type pendingDelivery struct{
+	id int64
+	attempts int
+	url string
+	secret string
+	event api.CatalogueEvent
+}
+
+func queryDueDeliveries(ctx context.Context, DB *sql.DB)(deliveries []*pendingDelivery, err error){
+	const queryCmd = "SELECT d.`id`,d.`attempts`,w.`url`,w.`secret`," +
+		"e.`seq`,e.`type`,e.`id`,e.`tag`,CONVERT_TZ(e.`time`,@@session.time_zone,'+00:00') AS `utc_time`,e.`data`" +
+		" FROM webhook_deliveries AS d" +
+		" JOIN webhooks AS w ON d.`webhook`=w.`id`" +
+		" JOIN catalogue_events AS e ON d.`event`=e.`seq`" +
+		" WHERE d.`status`='pending' AND d.`next_attempt`<=NOW()" +
+		" ORDER BY d.`event`,d.`id` LIMIT 100"
+
+	var rows *sql.Rows
+	if rows, err = DB.QueryContext(ctx, queryCmd); err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			d = new(pendingDelivery)
+			tag, data sql.NullString
+		)
+		if err = rows.Scan(&d.id, &d.attempts, &d.url, &d.secret,
+			&d.event.Seq, &d.event.Type, &d.event.Plugin, &tag, &d.event.Time, &data); err != nil {
+			return
+		}
+		d.event.Tag = tag.String
+		if data.Valid {
+			d.event.Data = (json.RawMessage)(data.String)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
+
+// send posts the event to the webhook, and returns the status code of the response
+func (d *pendingDelivery)send(ctx context.Context)(code int, err error){
+	payload, err := json.Marshal(d.event)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PluginWebPoint-Webhook")
+	req.Header.Set("X-PWP-Event", d.event.Type)
+	req.Header.Set("X-PWP-Delivery", strconv.FormatInt(d.id, 10))
+	req.Header.Set(api.WebhookSignatureHeader, api.SignWebhookPayload(d.secret, payload))
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64 * 1024))
+	code = res.StatusCode
+	if code < 200 || code >= 300 {
+		err = fmt.Errorf("Unexpected status code %d", code)
+	}
+	return
+}
+
+func (d *pendingDelivery)deliver(ctx context.Context, DB *sql.DB)(err error){
+	const okCmd = "UPDATE webhook_deliveries SET `status`='ok',`attempts`=`attempts`+1," +
+		"`last_code`=?,`last_error`=NULL,`next_attempt`=NULL,`updated`=NOW() WHERE `id`=?"
+	const retryCmd = "UPDATE webhook_deliveries SET `attempts`=`attempts`+1," +
+		"`last_code`=?,`last_error`=?,`next_attempt`=DATE_ADD(NOW(),INTERVAL ? SECOND),`updated`=NOW() WHERE `id`=?"
+	const failCmd = "UPDATE webhook_deliveries SET `status`='failed',`attempts`=`attempts`+1," +
+		"`last_code`=?,`last_error`=?,`next_attempt`=NULL,`updated`=NOW() WHERE `id`=?"
+
+	code, serr := d.send(ctx)
+	var lastCode sql.NullInt32
+	if code != 0 {
+		lastCode.Int32, lastCode.Valid = (int32)(code), true
+	}
+	if serr == nil {
+		loger.Debugf("Delivered event %d to %s", d.event.Seq, d.url)
+		_, err = DB.ExecContext(ctx, okCmd, lastCode, d.id)
+		return
+	}
+	msg := serr.Error()
+	if len(msg) > 256 {
+		msg = msg[:256]
+	}
+	attempts := d.attempts + 1
+	if attempts >= api.WebhookMaxAttempts {
+		loger.Warnf("Cannot deliver event %d to %s after %d attempts: %v", d.event.Seq, d.url, attempts, serr)
+		_, err = DB.ExecContext(ctx, failCmd, lastCode, msg, d.id)
+		return
+	}
+	backoff := api.WebhookBackoff(attempts)
+	loger.Debugf("Cannot deliver event %d to %s, retry after %v: %v", d.event.Seq, d.url, backoff, serr)
+	_, err = DB.ExecContext(ctx, retryCmd, lastCode, msg, (int64)(backoff / time.Second), d.id)
+	return
+}
+
+// nextAttemptAfter returns how long to wait for the next pending delivery, ok is false if there is none
+func nextAttemptAfter(ctx context.Context, DB *sql.DB)(wait time.Duration, ok bool, err error){
+	const queryCmd = "SELECT TIMESTAMPDIFF(SECOND,NOW(),MIN(`next_attempt`)) FROM webhook_deliveries WHERE `status`='pending'"
+
+	var secs sql.NullInt64
+	if err = DB.QueryRowContext(ctx, queryCmd).Scan(&secs); err != nil || !secs.Valid {
+		return
+	}
+	if secs.Int64 < 0 {
+		secs.Int64 = 0
+	}
+	return time.Duration(secs.Int64) * time.Second, true, nil
+}
+
+// DeliverWebhooks sends the pending deliveries, and retries the failed ones with backoff.
+// It returns when there is no pending delivery due within maxWait, the rest are left for the next call
+func DeliverWebhooks(ctx context.Context, DB *sql.DB, maxWait time.Duration)(err error){
+	deadline := time.Now().Add(maxWait)
+	for {
+		var deliveries []*pendingDelivery
+		if deliveries, err = queryDueDeliveries(ctx, DB); err != nil {
+			return
+		}
+		for _, d := range deliveries {
+			if err = d.deliver(ctx, DB); err != nil {
+				return
+			}
+		}
+		if len(deliveries) > 0 {
+			continue
+		}
+		wait, ok, err := nextAttemptAfter(ctx, DB)
+		if err != nil || !ok {
+			return err
+		}
+		if time.Now().Add(wait).After(deadline) {
+			loger.Infof("Pending webhook deliveries are left for the next run")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,531 @@
+
+package graphql
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/executor"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+// MaxDepth is the max nesting depth of the selections in a query, the introspection fields are not counted
+const MaxDepth = 12
+
+// MaxComplexity is the max cost of a query.
+// A field costs 1 plus its selections, each alias is charged as a separated field,
+// the lists are charged for each element, and reading a README or a changelog costs contentComplexity
+const MaxComplexity = 1000
+
+const (
+	// contentComplexity is the cost of reading and rendering a README or a changelog
+	contentComplexity = 20
+	// listComplexity is the assumed length of a list without a limit
+	listComplexity = 50
+)
+
+type Request struct{
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// cached loads the value once, the concurrent callers wait for the first one
+type cached[T any] struct{
+	once sync.Once
+	val  T
+	err  error
+}
+
+func (c *cached[T])get(load func()(T, error))(T, error){
+	c.once.Do(func(){
+		c.val, c.err = load()
+	})
+	return c.val, c.err
+}
+
+func loadedValue[T any](val T)(c *cached[T]){
+	c = new(cached[T])
+	c.once.Do(func(){
+		c.val = val
+	})
+	return
+}
+
+// loader caches the plugins and the releases loaded during a request,
+// so the plugins referenced by many dependencies are only queried once.
+// It's safe for the resolvers which are run concurrently
+type loader struct{
+	api      api.API
+	mux      sync.Mutex
+	plugins  map[string]*cached[*api.PluginInfo]
+	releases map[string]*cached[[]*api.PluginRelease]
+	all      cached[[]*api.PluginInfo]
+}
+
+type loaderKey struct{}
+
+func loaderOf(ctx context.Context)(*loader){
+	return ctx.Value(loaderKey{}).(*loader)
+}
+
+func (l *loader)plugin(ctx context.Context, id string)(*api.PluginInfo, error){
+	l.mux.Lock()
+	c, ok := l.plugins[id]
+	if !ok {
+		c = new(cached[*api.PluginInfo])
+		l.plugins[id] = c
+	}
+	l.mux.Unlock()
+	return c.get(func()(info *api.PluginInfo, err error){
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if info, err = l.api.GetPluginInfo(id, ""); err == api.ErrNotFound {
+			return nil, nil
+		}
+		return
+	})
+}
+
+// addPlugins caches the plugins got from the list
+func (l *loader)addPlugins(plugins []*api.PluginInfo){
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for _, p := range plugins {
+		if _, ok := l.plugins[p.Id]; !ok {
+			l.plugins[p.Id] = loadedValue(p)
+		}
+	}
+}
+
+func (l *loader)pluginReleases(ctx context.Context, id string)([]*api.PluginRelease, error){
+	l.mux.Lock()
+	c, ok := l.releases[id]
+	if !ok {
+		c = new(cached[[]*api.PluginRelease])
+		l.releases[id] = c
+	}
+	l.mux.Unlock()
+	return c.get(func()(releases []*api.PluginRelease, err error){
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if releases, err = l.api.GetPluginReleases(id); err == api.ErrNotFound {
+			return nil, nil
+		}
+		return
+	})
+}
+
+func (l *loader)release(ctx context.Context, id string, tag string)(*api.PluginRelease, error){
+	v, err := api.VersionFromString(tag)
+	if err != nil {
+		return nil, err
+	}
+	releases, err := l.pluginReleases(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range releases {
+		if r.Tag.Equal(v) {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (l *loader)allPlugins(ctx context.Context)([]*api.PluginInfo, error){
+	return l.all.get(func()(plugins []*api.PluginInfo, err error){
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if plugins, err = l.api.GetPluginList(api.PluginListOpt{}); err != nil {
+			return
+		}
+		l.addPlugins(plugins)
+		return
+	})
+}
+
+// latestRelease returns the newest release that matches the condition, and is stable if stable is true
+func (l *loader)latestRelease(ctx context.Context, id string, cond api.VersionCondList, stable bool)(*api.PluginRelease, error){
+	releases, err := l.pluginReleases(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// the releases are sorted from the newest
+	for _, r := range releases {
+		if (!stable || r.Stable) && cond.IsMatch(r.Tag) {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// readContent returns the markdown source, or the rendered html if render is true
+func readContent(content api.Content, err error, render *bool)(*string, error){
+	if err != nil {
+		if err == api.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer content.Close()
+	data, err := content.Data()
+	if err != nil {
+		return nil, err
+	}
+	if render != nil && *render {
+		if data, err = api.RenderMarkdown(data, &api.Option{
+			URLPrefix: content.URLPrefix,
+			DataURLPrefix: content.DataURLPrefix,
+			HeadingIDPrefix: "MDH~",
+		}); err != nil {
+			return nil, err
+		}
+	}
+	s := (string)(data)
+	return &s, nil
+}
+
+func formatTime(t time.Time)(string){
+	return t.UTC().Format(time.RFC3339)
+}
+
+func valueOr[T any](p *T, def T)(T){
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+type resolver struct{}
+
+func (resolver)Query()(QueryResolver){ return queryResolver{} }
+func (resolver)Plugin()(PluginResolver){ return pluginResolver{} }
+func (resolver)Release()(ReleaseResolver){ return releaseResolver{} }
+func (resolver)Asset()(AssetResolver){ return assetResolver{} }
+func (resolver)Author()(AuthorResolver){ return authorResolver{} }
+func (resolver)Dependency()(DependencyResolver){ return dependencyResolver{} }
+
+type queryResolver struct{}
+
+func (queryResolver)Plugin(ctx context.Context, id string)(*api.PluginInfo, error){
+	return loaderOf(ctx).plugin(ctx, id)
+}
+
+func (queryResolver)Plugins(ctx context.Context, filterBy *string, tags []string, sortBy *string, reversed *bool, offset *int, limit *int)(plugins []*api.PluginInfo, err error){
+	l := loaderOf(ctx)
+	if plugins, err = l.api.GetPluginList(api.PluginListOpt{
+		FilterBy: valueOr(filterBy, ""),
+		Tags: tags,
+		SortBy: valueOr(sortBy, ""),
+		Reversed: valueOr(reversed, false),
+		Offset: valueOr(offset, 0),
+		Limit: valueOr(limit, 0),
+	}); err != nil {
+		return
+	}
+	l.addPlugins(plugins)
+	return
+}
+
+func (queryResolver)PluginCounts(ctx context.Context, filterBy *string, tags []string)(*api.PluginCounts, error){
+	counts, err := loaderOf(ctx).api.GetPluginCounts(api.PluginListOpt{
+		FilterBy: valueOr(filterBy, ""),
+		Tags: tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &counts, nil
+}
+
+func (queryResolver)Release(ctx context.Context, plugin string, tag string)(*api.PluginRelease, error){
+	return loaderOf(ctx).release(ctx, plugin, tag)
+}
+
+func (queryResolver)Author(ctx context.Context, name string)(*Author, error){
+	plugins, err := loaderOf(ctx).allPlugins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		for _, a := range p.Authors {
+			if a == name {
+				return &Author{ Name: name }, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+type pluginResolver struct{}
+
+func (pluginResolver)Version(_ context.Context, p *api.PluginInfo)(string, error){
+	return p.Version.String(), nil
+}
+
+func (pluginResolver)Authors(_ context.Context, p *api.PluginInfo)([]*Author, error){
+	authors := make([]*Author, len(p.Authors))
+	for i, a := range p.Authors {
+		authors[i] = &Author{ Name: a }
+	}
+	return authors, nil
+}
+
+func (pluginResolver)Desc(_ context.Context, p *api.PluginInfo, lang *string)(*string, error){
+	if valueOr(lang, "") == "zh_cn" && len(p.Desc_zhCN) > 0 {
+		return &p.Desc_zhCN, nil
+	}
+	return &p.Desc, nil
+}
+
+func (pluginResolver)CreateAt(_ context.Context, p *api.PluginInfo)(string, error){
+	return formatTime(p.CreateAt), nil
+}
+
+func (pluginResolver)LastRelease(_ context.Context, p *api.PluginInfo)(*string, error){
+	if p.LastRelease == nil {
+		return nil, nil
+	}
+	s := formatTime(*p.LastRelease)
+	return &s, nil
+}
+
+func (pluginResolver)Labels(_ context.Context, p *api.PluginInfo)([]string, error){
+	return p.Labels.Names(), nil
+}
+
+func (pluginResolver)Dependencies(_ context.Context, p *api.PluginInfo)([]*Dependency, error){
+	list := make([]*Dependency, 0, len(p.Dependencies))
+	for id, cond := range p.Dependencies {
+		list = append(list, &Dependency{ Id: id, Condition: cond })
+	}
+	sort.Slice(list, func(i, j int)(bool){ return list[i].Id < list[j].Id })
+	return list, nil
+}
+
+func (pluginResolver)Requirements(_ context.Context, p *api.PluginInfo)([]*Requirement, error){
+	list := make([]*Requirement, 0, len(p.Requirements))
+	for name, cond := range p.Requirements {
+		list = append(list, &Requirement{ Name: name, Condition: cond })
+	}
+	sort.Slice(list, func(i, j int)(bool){ return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (pluginResolver)Readme(ctx context.Context, p *api.PluginInfo, render *bool)(*string, error){
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	content, err := loaderOf(ctx).api.GetPluginReadme(p.Id)
+	return readContent(content, err, render)
+}
+
+func (pluginResolver)Releases(ctx context.Context, p *api.PluginInfo, stable *bool, limit *int)([]*api.PluginRelease, error){
+	releases, err := loaderOf(ctx).pluginReleases(ctx, p.Id)
+	if err != nil {
+		return nil, err
+	}
+	n := valueOr(limit, 0)
+	list := make([]*api.PluginRelease, 0, len(releases))
+	for _, r := range releases {
+		if n > 0 && len(list) >= n {
+			break
+		}
+		if stable == nil || r.Stable == *stable {
+			list = append(list, r)
+		}
+	}
+	return list, nil
+}
+
+func (pluginResolver)Release(ctx context.Context, p *api.PluginInfo, tag string)(*api.PluginRelease, error){
+	return loaderOf(ctx).release(ctx, p.Id, tag)
+}
+
+func (pluginResolver)LatestRelease(ctx context.Context, p *api.PluginInfo, stable *bool)(*api.PluginRelease, error){
+	return loaderOf(ctx).latestRelease(ctx, p.Id, nil, valueOr(stable, true))
+}
+
+type releaseResolver struct{}
+
+func (releaseResolver)Plugin(ctx context.Context, r *api.PluginRelease)(*api.PluginInfo, error){
+	return loaderOf(ctx).plugin(ctx, r.Id)
+}
+
+func (releaseResolver)Tag(_ context.Context, r *api.PluginRelease)(string, error){
+	return r.Tag.String(), nil
+}
+
+func (releaseResolver)Uploaded(_ context.Context, r *api.PluginRelease)(string, error){
+	return formatTime(r.Uploaded), nil
+}
+
+func (releaseResolver)Changelog(ctx context.Context, r *api.PluginRelease, render *bool)(*string, error){
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	content, err := loaderOf(ctx).api.GetPluginReleaseChangelog(r.Id, r.Tag)
+	return readContent(content, err, render)
+}
+
+type assetResolver struct{}
+
+func (assetResolver)Uploaded(_ context.Context, a *api.ReleaseAsset)(string, error){
+	return formatTime(a.Uploaded), nil
+}
+
+type authorResolver struct{}
+
+func (authorResolver)Plugins(ctx context.Context, a *Author)(list []*api.PluginInfo, err error){
+	plugins, err := loaderOf(ctx).allPlugins(ctx)
+	if err != nil {
+		return
+	}
+	list = make([]*api.PluginInfo, 0)
+	for _, p := range plugins {
+		for _, name := range p.Authors {
+			if name == a.Name {
+				list = append(list, p)
+				break
+			}
+		}
+	}
+	return
+}
+
+type dependencyResolver struct{}
+
+func (dependencyResolver)Condition(_ context.Context, d *Dependency)(string, error){
+	return d.Condition.String(), nil
+}
+
+func (dependencyResolver)Plugin(ctx context.Context, d *Dependency)(*api.PluginInfo, error){
+	return loaderOf(ctx).plugin(ctx, d.Id)
+}
+
+func (dependencyResolver)LatestRelease(ctx context.Context, d *Dependency, stable *bool)(*api.PluginRelease, error){
+	return loaderOf(ctx).latestRelease(ctx, d.Id, d.Condition, valueOr(stable, true))
+}
+
+// listCost charges the selections for each element of a list with the optional limit
+func listCost(childComplexity int, limit *int)(int){
+	n := listComplexity
+	if limit != nil && *limit > 0 {
+		n = *limit
+	}
+	return 1 + childComplexity * n
+}
+
+func contentCost(int, *bool)(int){
+	return contentComplexity
+}
+
+func catalogueComplexity()(c ComplexityRoot){
+	c.Query.Plugins = func(childComplexity int, _ *string, _ []string, _ *string, _ *bool, _ *int, limit *int)(int){
+		return listCost(childComplexity, limit)
+	}
+	c.Plugin.Releases = func(childComplexity int, _ *bool, limit *int)(int){
+		return listCost(childComplexity, limit)
+	}
+	c.Author.Plugins = func(childComplexity int)(int){
+		return listCost(childComplexity, nil)
+	}
+	c.Plugin.Readme = contentCost
+	c.Release.Changelog = contentCost
+	return
+}
+
+// depthLimit rejects the queries deeper than MaxDepth before they are executed
+type depthLimit struct{}
+
+var _ interface{
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit{}
+
+func (depthLimit)ExtensionName()(string){
+	return "DepthLimit"
+}
+
+func (depthLimit)Validate(graphql.ExecutableSchema)(error){
+	return nil
+}
+
+func (depthLimit)MutateOperationContext(_ context.Context, rc *graphql.OperationContext)(*gqlerror.Error){
+	if selectionDepth(rc.Operation.SelectionSet) > MaxDepth {
+		return gqlerror.Errorf("The query exceeds the max depth %d", MaxDepth)
+	}
+	return nil
+}
+
+// selectionDepth returns the depth of the selections, the fragments are already validated to be acyclic
+func selectionDepth(set ast.SelectionSet)(depth int){
+	for _, sel := range set {
+		var d int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if len(sel.Name) > 2 && sel.Name[:2] == "__" {
+				continue
+			}
+			d = 1 + selectionDepth(sel.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			if sel.Definition != nil {
+				d = selectionDepth(sel.Definition.SelectionSet)
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+	return
+}
+
+var catalogueExecutor = func()(*executor.Executor){
+	e := executor.New(NewExecutableSchema(Config{
+		Resolvers: resolver{},
+		Complexity: catalogueComplexity(),
+	}))
+	e.Use(extension.Introspection{})
+	e.Use(depthLimit{})
+	e.Use(extension.FixedComplexityLimit(MaxComplexity))
+	return e
+}()
+
+// SDL returns the schema of the plugin catalogue in the GraphQL schema definition language
+func SDL()(string){
+	return sourceData("schema.graphqls")
+}
+
+// ExecuteCatalogue runs the query against the plugin catalogue of the api,
+// the resolvers stop loading once ctx is done
+func ExecuteCatalogue(ctx context.Context, ins api.API, req *Request)(*graphql.Response){
+	ctx = context.WithValue(ctx, loaderKey{}, &loader{
+		api: ins,
+		plugins: make(map[string]*cached[*api.PluginInfo]),
+		releases: make(map[string]*cached[[]*api.PluginRelease]),
+	})
+	ctx = graphql.StartOperationTrace(ctx)
+	rc, errs := catalogueExecutor.CreateOperationContext(ctx, &graphql.RawParams{
+		Query: req.Query,
+		OperationName: req.OperationName,
+		Variables: req.Variables,
+	})
+	if errs != nil {
+		return catalogueExecutor.DispatchError(graphql.WithOperationContext(ctx, rc), errs)
+	}
+	handler, ctx := catalogueExecutor.DispatchOperation(ctx, rc)
+	return handler(ctx)
+}
@@ -0,0 +1,181 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api/graphql"
+	"github.com/kmcsr/PluginWebPoint/api/internal/apitest"
+)
+
+func execute(t *testing.T, ins api.API, req *graphql.Request)(string){
+	data, err := json.Marshal(graphql.ExecuteCatalogue(context.Background(), ins, req))
+	if err != nil {
+		t.Fatalf("Cannot marshal the result: %v", err)
+	}
+	return (string)(data)
+}
+
+func TestExecuteCatalogue(t *testing.T){
+	ins := apitest.NewFakeAPI()
+	res := execute(t, ins, &graphql.Request{
+		Query: `query Page($id: String!) {
+			plugin(id: $id) {
+				id
+				labels
+				releases { tag stable }
+				dependencies {
+					id
+					condition
+					plugin { name }
+					latestRelease { tag }
+					prerelease: latestRelease(stable: false) { tag }
+				}
+				lib: release(tag: "9.9.9") { tag }
+			}
+		}`,
+		Variables: map[string]any{"id": "hello"},
+	})
+	want := `{"data":{"plugin":{"id":"hello","labels":["tool"],` +
+		`"releases":[{"tag":"1.2.0","stable":true}],` +
+		`"dependencies":[` +
+		`{"id":"lib","condition":"\u003e=1.0.0 \u003c2.0.0","plugin":{"name":"Lib"},"latestRelease":{"tag":"1.4.0"},"prerelease":{"tag":"1.5.0"}},` +
+		`{"id":"mcdreforged","condition":"\u003e=2.0.0","plugin":null,"latestRelease":null,"prerelease":null}],` +
+		`"lib":null}}}`
+	if res != want {
+		t.Errorf("Unexpected result:\n%s\nexpect:\n%s", res, want)
+	}
+	if n := ins.Calls()["releases:lib"]; n != 1 {
+		t.Errorf("The releases of lib are queried %d times, expect once", n)
+	}
+}
+
+func TestExecuteFragments(t *testing.T){
+	res := execute(t, apitest.NewFakeAPI(), &graphql.Request{
+		Query: `
+			query A { author(name: "bob") { name plugins { ...Info } } }
+			query B($skip: Boolean = true) {
+				plugins { __typename ... on Plugin { id } name @skip(if: $skip) }
+				nobody: author(name: "carol") { name }
+			}
+			fragment Info on Plugin { id authors { name } }`,
+		OperationName: "B",
+	})
+	want := `{"data":{"plugins":[{"__typename":"Plugin","id":"hello"},{"__typename":"Plugin","id":"lib"}],"nobody":null}}`
+	if res != want {
+		t.Errorf("Unexpected result:\n%s\nexpect:\n%s", res, want)
+	}
+
+	res = execute(t, apitest.NewFakeAPI(), &graphql.Request{
+		Query: `query A { author(name: "bob") { name plugins { ...Info } } } fragment Info on Plugin { id authors { name } }`,
+	})
+	want = `{"data":{"author":{"name":"bob","plugins":[{"id":"lib","authors":[{"name":"alice"},{"name":"bob"}]}]}}}`
+	if res != want {
+		t.Errorf("Unexpected result:\n%s\nexpect:\n%s", res, want)
+	}
+}
+
+func TestExecuteErrors(t *testing.T){
+	cases := []struct{
+		req  *graphql.Request
+		want string
+	}{
+		{ &graphql.Request{ Query: `{ plugin(id: "hello") { id ` }, `{"errors":[{"message":"Expected Name, found \u003cEOF\u003e","locations":[{"line":1,"column":28}],"extensions":{"code":"GRAPHQL_PARSE_FAILED"}}],"data":null}` },
+		{ &graphql.Request{ Query: `{ plugin(id: "hello") { unknown } }` }, `{"errors":[{"message":"Cannot query field \"unknown\" on type \"Plugin\".` },
+		{ &graphql.Request{ Query: `{ plugin { id } }` }, `{"errors":[{"message":"Field \"plugin\" argument \"id\" of type \"String!\" is required, but it was not provided."` },
+		{ &graphql.Request{ Query: `{ plugin(id: 1) { id } }` }, `{"errors":[{"message":"String cannot represent a non string value: 1"` },
+		{ &graphql.Request{ Query: `{ plugin(id: "hello") }` }, `{"errors":[{"message":"Field \"plugin\" of type \"Plugin\" must have a selection of subfields.` },
+		{ &graphql.Request{ Query: `mutation { plugin(id: "hello") { id } }` }, `{"errors":[{"message":"Schema does not support operation type \"mutation\""` },
+		{ &graphql.Request{ Query: `query ($id: String!) { plugin(id: $id) { id } }` }, `{"errors":[{"message":"must be defined","path":["variable","id"]` },
+		{ &graphql.Request{ Query: `{ ...F } fragment F on Query { ...F }` }, `{"errors":[{"message":"Cannot spread fragment \"F\" within itself.` },
+		{ &graphql.Request{ Query: `{ plugin(id: "hello") { release(tag: "latest") { tag } } }` },
+			`{"errors":[{"message":"Format error for \"latest\", not match the version regexp","path":["plugin","release"]}],"data":{"plugin":{"release":null}}}` },
+		{ &graphql.Request{ Query: `{ plugin(id: "missing") { id } }` }, `{"data":{"plugin":null}}` },
+	}
+	for _, c := range cases {
+		res := execute(t, apitest.NewFakeAPI(), c.req)
+		if !strings.HasPrefix(res, c.want) {
+			t.Errorf("Unexpected result of %q:\n%s\nexpect:\n%s", c.req.Query, res, c.want)
+		}
+	}
+
+	deep := "plugin(id: \"hello\") { " + strings.Repeat("releases { plugin { ", 6) + "id" + strings.Repeat(" } }", 6) + " }"
+	if res := execute(t, apitest.NewFakeAPI(), &graphql.Request{ Query: "{ " + deep + " }" }); !strings.Contains(res, "exceeds the max depth") {
+		t.Errorf("Unexpected result of the deep query: %s", res)
+	}
+}
+
+func TestComplexityLimit(t *testing.T){
+	cases := []struct{
+		query  string
+		reject bool
+	}{
+		{ `{ plugins { id name releases(limit: 5) { tag } } }`, false },
+		{ `{ plugins(limit: 20) { readme } }`, false },
+		{ `{ plugins { readme } }`, true },
+		{ `{ plugin(id: "hello") { releases { changelog } } }`, true },
+		{ `{ a: plugin(id: "hello") { readme } b: plugin(id: "hello") { readme } }`, false },
+	}
+	// each alias is charged as a separated field
+	var aliases strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&aliases, "p%d: plugin(id: \"hello\") { readme } ", i)
+	}
+	cases = append(cases, struct{
+		query  string
+		reject bool
+	}{ "{ " + aliases.String() + "}", true })
+	for _, c := range cases {
+		ins := apitest.NewFakeAPI()
+		res := execute(t, ins, &graphql.Request{ Query: c.query })
+		if rejected := strings.Contains(res, "COMPLEXITY_LIMIT_EXCEEDED"); rejected != c.reject {
+			t.Errorf("Unexpected result of %q: %s", c.query, res)
+		}
+		if c.reject && len(ins.Calls()) > 0 {
+			t.Errorf("The rejected query %q is executed: %v", c.query, ins.Calls())
+		}
+	}
+}
+
+func TestExecuteCanceled(t *testing.T){
+	ins := apitest.NewFakeAPI()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := json.Marshal(graphql.ExecuteCatalogue(ctx, ins, &graphql.Request{
+		Query: `{ plugin(id: "hello") { id } }`,
+	}))
+	if err != nil {
+		t.Fatalf("Cannot marshal the result: %v", err)
+	}
+	if !strings.Contains((string)(res), context.Canceled.Error()) || len(ins.Calls()) > 0 {
+		t.Errorf("The canceled query is executed: %s, %v", res, ins.Calls())
+	}
+}
+
+func TestIntrospection(t *testing.T){
+	res := execute(t, apitest.NewFakeAPI(), &graphql.Request{
+		Query: `{ __schema { queryType { name } } __type(name: "Dependency") { fields { name } } }`,
+	})
+	want := `{"data":{"__schema":{"queryType":{"name":"Query"}},"__type":{"fields":[{"name":"id"},{"name":"condition"},{"name":"plugin"},{"name":"latestRelease"}]}}}`
+	if res != want {
+		t.Errorf("Unexpected result:\n%s\nexpect:\n%s", res, want)
+	}
+}
+
+func TestSDL(t *testing.T){
+	sdl := graphql.SDL()
+	for _, s := range []string{
+		"type Query {\n",
+		"\tplugins(filterBy: String, tags: [String!], sortBy: String, reversed: Boolean = false, offset: Int = 0, limit: Int = 0): [Plugin!]!\n",
+		"\tlatestRelease(stable: Boolean = true): Release\n",
+		"type Dependency {\n",
+	} {
+		if !strings.Contains(sdl, s) {
+			t.Errorf("%q not found in the SDL:\n%s", s, sdl)
+		}
+	}
+}
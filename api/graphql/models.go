@@ -0,0 +1,24 @@
+
+// Package graphql serves the read-only GraphQL queries over the plugin catalogue.
+// The executor is generated by gqlgen from schema.graphqls, run `go generate` after the schema is changed
+package graphql
+
+import (
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+//go:generate go run github.com/99designs/gqlgen@v0.17.40 generate
+
+type Author struct{
+	Name string
+}
+
+type Dependency struct{
+	Id        string
+	Condition api.VersionCondList
+}
+
+type Requirement struct{
+	Name      string
+	Condition string
+}
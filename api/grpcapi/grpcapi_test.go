@@ -0,0 +1,208 @@
+package grpcapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api/grpcapi"
+	pb "github.com/kmcsr/PluginWebPoint/api/grpcapi/pwpv1"
+	"github.com/kmcsr/PluginWebPoint/api/internal/apitest"
+)
+
+// newClient serves the api in memory and returns the connection to it
+func newClient(t *testing.T, ins api.API)(*grpc.ClientConn){
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpcapi.NewGRPCServer(ins)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string)(net.Conn, error){
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Cannot dial the server: %v", err)
+	}
+	t.Cleanup(func(){ conn.Close() })
+	return conn
+}
+
+func TestServer(t *testing.T){
+	ctx := context.Background()
+	ins := apitest.NewFakeAPI()
+	// the server should sort the releases whatever the api returns
+	lib := ins.Releases["lib"]
+	ins.Releases["lib"] = []*api.PluginRelease{lib[2], lib[0], lib[1]}
+	c := pb.NewPluginServiceClient(newClient(t, ins))
+
+	plugin, err := c.GetPlugin(ctx, &pb.GetPluginRequest{ Id: "hello" })
+	if err != nil {
+		t.Fatalf("Cannot get the plugin: %v", err)
+	}
+	if plugin.Id != "hello" || plugin.Version != "1.2.0" || len(plugin.Labels) != 1 || plugin.Labels[0] != "tool" || !plugin.GithubSync {
+		t.Errorf("Unexpected plugin: %v", plugin)
+	}
+	if len(plugin.Dependencies) != 2 || plugin.Dependencies["lib"] != ">=1.0.0 <2.0.0" {
+		t.Errorf("Unexpected dependencies: %v", plugin.Dependencies)
+	}
+	if plugin.CreateAt.GetSeconds() != 1682942400 || plugin.LastRelease != nil {
+		t.Errorf("Unexpected times: %v, %v", plugin.CreateAt, plugin.LastRelease)
+	}
+
+	list, err := c.ListPlugins(ctx, &pb.ListPluginsRequest{})
+	if err != nil || len(list.Plugins) != 2 {
+		t.Errorf("Unexpected result of ListPlugins: %v, %v", list, err)
+	}
+
+	releases, err := c.ListReleases(ctx, &pb.ListReleasesRequest{ Id: "lib" })
+	if err != nil {
+		t.Fatalf("Cannot list the releases: %v", err)
+	}
+	var tags []string
+	for _, r := range releases.Releases {
+		tags = append(tags, r.Tag)
+	}
+	if len(tags) != 3 || tags[0] != "2.0.0" || tags[1] != "1.5.0" || tags[2] != "1.4.0" {
+		t.Errorf("The releases are not sorted from the newest: %v", tags)
+	}
+	if assets := releases.Releases[0].Assets; len(assets) != 1 || assets[0].Checksums.GetSha256() != "abcd" {
+		t.Errorf("Unexpected assets: %v", assets)
+	}
+
+	cases := []struct{
+		call    func()(error)
+		code    codes.Code
+		message string
+	}{
+		{ func()(err error){ _, err = c.GetPlugin(ctx, &pb.GetPluginRequest{ Id: "missing" }); return }, codes.NotFound, "ErrNotFound" },
+		{ func()(err error){ _, err = c.GetPlugin(ctx, &pb.GetPluginRequest{}); return }, codes.InvalidArgument, "id is required" },
+		{ func()(err error){ _, err = c.GetRelease(ctx, &pb.GetReleaseRequest{ Id: "lib", Tag: "latest" }); return }, codes.InvalidArgument, "" },
+		{ func()(err error){ _, err = c.ResolveDependencies(ctx, &pb.ResolveRequest{}); return }, codes.InvalidArgument, "plugins is required" },
+		{ func()(error){
+			return newClient(t, ins).Invoke(ctx, "/" + pb.PluginService_ServiceDesc.ServiceName + "/Unknown", &pb.GetPluginRequest{}, &pb.Plugin{})
+		}, codes.Unimplemented, "" },
+	}
+	for i, cs := range cases {
+		st := status.Convert(cs.call())
+		if st.Code() != cs.code || (len(cs.message) > 0 && st.Message() != cs.message) {
+			t.Errorf("Unexpected status of case %d: %v, expect %s %q", i, st, cs.code, cs.message)
+		}
+	}
+}
+
+// download reads all chunks of the asset
+func download(c pb.PluginServiceClient, offset int64)(chunks []*pb.AssetChunk, err error){
+	stream, err := c.DownloadAsset(context.Background(), &pb.DownloadAssetRequest{
+		Id: "lib", Tag: "2.0.0", Filename: "lib.mcdr", Offset: offset,
+	})
+	if err != nil {
+		return
+	}
+	for {
+		var chunk *pb.AssetChunk
+		if chunk, err = stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestDownloadAsset(t *testing.T){
+	ins := apitest.NewFakeAPI()
+	c := pb.NewPluginServiceClient(newClient(t, ins))
+
+	chunks, err := download(c, 0)
+	if err != nil {
+		t.Fatalf("Cannot download the asset: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("Expect 3 chunks, got %d", len(chunks))
+	}
+	var data []byte
+	for i, chunk := range chunks {
+		if chunk.Offset != (int64)(len(data)) {
+			t.Errorf("Unexpected offset %d of chunk %d", chunk.Offset, i)
+		}
+		if i == 0 {
+			if chunk.TotalSize != 70000 || chunk.Checksums.GetSha256() != "abcd" {
+				t.Errorf("The size or the checksums are missing in the first chunk: %v", chunk)
+			}
+		}else if chunk.TotalSize != 0 || chunk.Checksums != nil {
+			t.Errorf("The size or the checksums are set in chunk %d", i)
+		}
+		data = append(data, chunk.Data...)
+	}
+	if !bytes.Equal(data, ins.Asset) {
+		t.Errorf("The downloaded asset does not match")
+	}
+
+	if chunks, err = download(c, 69999); err != nil || len(chunks) != 1 {
+		t.Fatalf("Unexpected result of resuming: %v, %d chunks", err, len(chunks))
+	}
+	if chunk := chunks[0]; chunk.Offset != 69999 || len(chunk.Data) != 1 {
+		t.Errorf("Unexpected resumed chunk: %v", chunk)
+	}
+
+	if _, err = download(c, 70001); status.Code(err) != codes.OutOfRange {
+		t.Errorf("Expect OutOfRange, got %v", err)
+	}
+}
+
+func TestResolve(t *testing.T){
+	ins := apitest.NewFakeAPI()
+	res, err := grpcapi.Resolve(ins, map[string]api.VersionCondList{"hello": nil}, false)
+	if err != nil {
+		t.Fatalf("Cannot resolve: %v", err)
+	}
+	if len(res.Plugins) != 2 || res.Plugins[0].Id != "hello" || res.Plugins[1].Id != "lib" {
+		t.Fatalf("Unexpected resolved plugins: %v", res.Plugins)
+	}
+	if lib := res.Plugins[1]; lib.Release.Tag.String() != "1.4.0" || len(lib.RequiredBy) != 1 || lib.RequiredBy[0] != "hello" {
+		t.Errorf("Unexpected resolved lib: %s %v", lib.Release.Tag, lib.RequiredBy)
+	}
+	if len(res.Unresolved) != 1 || res.Unresolved[0].Id != "mcdreforged" || res.Unresolved[0].Reason != "NotFound" {
+		t.Errorf("Unexpected unresolved plugins: %v", res.Unresolved)
+	}
+
+	if res, err = grpcapi.Resolve(ins, map[string]api.VersionCondList{"hello": nil}, true); err != nil {
+		t.Fatalf("Cannot resolve: %v", err)
+	}
+	if tag := res.Plugins[1].Release.Tag.String(); tag != "1.5.0" {
+		t.Errorf("Expect the pre-release 1.5.0, got %s", tag)
+	}
+
+	if res, err = grpcapi.Resolve(ins, map[string]api.VersionCondList{"hello": nil, "lib": apitest.MustCond(">=2.0.0")}, false); err != nil {
+		t.Fatalf("Cannot resolve: %v", err)
+	}
+	if len(res.Plugins) != 1 || len(res.Unresolved) != 2 {
+		t.Fatalf("Unexpected resolution: %v, %v", res.Plugins, res.Unresolved)
+	}
+	if lib := res.Unresolved[0]; lib.Id != "lib" || lib.Reason != "NoMatchingRelease" || lib.Condition.String() != ">=2.0.0 >=1.0.0 <2.0.0" {
+		t.Errorf("Unexpected unresolved lib: %v", lib)
+	}
+
+	// the generated client gets the same resolution
+	c := pb.NewPluginServiceClient(newClient(t, ins))
+	resp, err := c.ResolveDependencies(context.Background(), &pb.ResolveRequest{ Plugins: map[string]string{"hello": ""} })
+	if err != nil {
+		t.Fatalf("Cannot resolve: %v", err)
+	}
+	if len(resp.Plugins) != 2 || resp.Plugins[1].Release.GetTag() != "1.4.0" || len(resp.Unresolved) != 1 || resp.Unresolved[0].Condition != ">=2.0.0" {
+		t.Errorf("Unexpected response: %v", resp)
+	}
+}
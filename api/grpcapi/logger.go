@@ -0,0 +1,26 @@
+
+package grpcapi
+
+import (
+	"github.com/kmcsr/go-logger"
+	"github.com/kmcsr/go-logger/logrus"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+var loger logger.Logger = initLogger()
+
+func initLogger()(loger logger.Logger){
+	loger = logrus.Logger
+	if api.DEBUG {
+		loger.SetLevel(logger.TraceLevel)
+	}else{
+		loger.SetLevel(logger.InfoLevel)
+	}
+	return
+}
+
+// SetLogger replaces the logger of the package
+func SetLogger(l logger.Logger){
+	loger = l
+}
@@ -0,0 +1,123 @@
+
+package grpcapi
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+	pb "github.com/kmcsr/PluginWebPoint/api/grpcapi/pwpv1"
+)
+
+// The api types are converted to the messages generated from pwpv1/pwp.proto
+
+// timestampOf returns nil for the zero time
+func timestampOf(t time.Time)(*timestamppb.Timestamp){
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func checksumsOf(c api.Checksums)(*pb.Checksums){
+	if len(c.Sha256) == 0 && len(c.Sha1) == 0 {
+		return nil
+	}
+	return &pb.Checksums{
+		Sha256: c.Sha256,
+		Sha1: c.Sha1,
+	}
+}
+
+func pluginOf(info *api.PluginInfo)(p *pb.Plugin){
+	p = &pb.Plugin{
+		Id: info.Id,
+		Name: info.Name,
+		Version: info.Version.String(),
+		Authors: info.Authors,
+		Desc: info.Desc,
+		DescZhCn: info.Desc_zhCN,
+		CreateAt: timestampOf(info.CreateAt),
+		Repo: info.Repo,
+		RepoBranch: info.RepoBranch,
+		RepoSubdir: info.RepoSubdir,
+		Link: info.Link,
+		Labels: info.Labels.Names(),
+		Downloads: info.Downloads,
+		LocalDownloads: info.LocalDownloads,
+		Requirements: info.Requirements,
+		GithubSync: info.GithubSync,
+		GhRepoOwner: info.GhRepoOwner,
+		GhRepoName: info.GhRepoName,
+	}
+	if info.LastRelease != nil {
+		p.LastRelease = timestampOf(*info.LastRelease)
+	}
+	if info.LastSync != nil {
+		p.LastSync = timestampOf(*info.LastSync)
+	}
+	if len(info.Dependencies) > 0 {
+		p.Dependencies = make(map[string]string, len(info.Dependencies))
+		for id, cond := range info.Dependencies {
+			p.Dependencies[id] = cond.String()
+		}
+	}
+	return
+}
+
+func assetOf(a *api.ReleaseAsset)(*pb.Asset){
+	return &pb.Asset{
+		Name: a.Name,
+		Size: a.Size,
+		ContentType: a.ContentType,
+		Uploaded: timestampOf(a.Uploaded),
+		Downloads: (int64)(a.Downloads),
+		LocalDownloads: (int64)(a.LocalDownloads),
+		GithubUrl: a.GithubUrl,
+		Checksums: checksumsOf(a.Checksums),
+	}
+}
+
+func releaseOf(r *api.PluginRelease)(*pb.Release){
+	assets := make([]*pb.Asset, len(r.Assets))
+	for i, a := range r.Assets {
+		assets[i] = assetOf(a)
+	}
+	return &pb.Release{
+		Id: r.Id,
+		Tag: r.Tag.String(),
+		Name: r.Name,
+		Enabled: r.Enabled,
+		Stable: r.Stable,
+		Size: r.Size,
+		Uploaded: timestampOf(r.Uploaded),
+		Filename: r.FileName,
+		Downloads: (int64)(r.Downloads),
+		LocalDownloads: (int64)(r.LocalDownloads),
+		GithubUrl: r.GithubUrl,
+		Checksums: checksumsOf(r.Checksums),
+		Problems: r.Problems,
+		Assets: assets,
+	}
+}
+
+func resolutionOf(res *Resolution)(resp *pb.ResolveResponse){
+	resp = new(pb.ResolveResponse)
+	for _, p := range res.Plugins {
+		resp.Plugins = append(resp.Plugins, &pb.ResolvedPlugin{
+			Id: p.Id,
+			Release: releaseOf(p.Release),
+			RequiredBy: p.RequiredBy,
+		})
+	}
+	for _, p := range res.Unresolved {
+		resp.Unresolved = append(resp.Unresolved, &pb.UnresolvedPlugin{
+			Id: p.Id,
+			Condition: p.Condition.String(),
+			RequiredBy: p.RequiredBy,
+			Reason: p.Reason,
+		})
+	}
+	return
+}
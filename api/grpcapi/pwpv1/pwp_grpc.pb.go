@@ -0,0 +1,341 @@
+// The gRPC service of PluginWebPoint, it mirrors the read-only part of the v1 API.
+// The Go code is generated by protoc-gen-go and protoc-gen-go-grpc, run `go generate ./api/grpcapi` after changing this file
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: pwp.proto
+
+package pwpv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	PluginService_ListPlugins_FullMethodName         = "/pwp.v1.PluginService/ListPlugins"
+	PluginService_GetPlugin_FullMethodName           = "/pwp.v1.PluginService/GetPlugin"
+	PluginService_ListReleases_FullMethodName        = "/pwp.v1.PluginService/ListReleases"
+	PluginService_GetRelease_FullMethodName          = "/pwp.v1.PluginService/GetRelease"
+	PluginService_DownloadAsset_FullMethodName       = "/pwp.v1.PluginService/DownloadAsset"
+	PluginService_ResolveDependencies_FullMethodName = "/pwp.v1.PluginService/ResolveDependencies"
+)
+
+// PluginServiceClient is the client API for PluginService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PluginServiceClient interface {
+	// ListPlugins is the same as `GET /v1/plugins`
+	ListPlugins(ctx context.Context, in *ListPluginsRequest, opts ...grpc.CallOption) (*ListPluginsResponse, error)
+	// GetPlugin is the same as `GET /v1/plugin/{id}/info`
+	GetPlugin(ctx context.Context, in *GetPluginRequest, opts ...grpc.CallOption) (*Plugin, error)
+	// ListReleases is the same as `GET /v1/plugin/{id}/releases`, the releases are sorted from the newest
+	ListReleases(ctx context.Context, in *ListReleasesRequest, opts ...grpc.CallOption) (*ListReleasesResponse, error)
+	// GetRelease is the same as `GET /v1/plugin/{id}/release/{tag}`
+	GetRelease(ctx context.Context, in *GetReleaseRequest, opts ...grpc.CallOption) (*Release, error)
+	// DownloadAsset streams the asset of a release in chunks,
+	// the download is only counted when the offset is zero
+	DownloadAsset(ctx context.Context, in *DownloadAssetRequest, opts ...grpc.CallOption) (PluginService_DownloadAssetClient, error)
+	// ResolveDependencies picks the newest release of the plugins and all of their dependencies
+	// which matches every version condition
+	ResolveDependencies(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error)
+}
+
+type pluginServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPluginServiceClient(cc grpc.ClientConnInterface) PluginServiceClient {
+	return &pluginServiceClient{cc}
+}
+
+func (c *pluginServiceClient) ListPlugins(ctx context.Context, in *ListPluginsRequest, opts ...grpc.CallOption) (*ListPluginsResponse, error) {
+	out := new(ListPluginsResponse)
+	err := c.cc.Invoke(ctx, PluginService_ListPlugins_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pluginServiceClient) GetPlugin(ctx context.Context, in *GetPluginRequest, opts ...grpc.CallOption) (*Plugin, error) {
+	out := new(Plugin)
+	err := c.cc.Invoke(ctx, PluginService_GetPlugin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pluginServiceClient) ListReleases(ctx context.Context, in *ListReleasesRequest, opts ...grpc.CallOption) (*ListReleasesResponse, error) {
+	out := new(ListReleasesResponse)
+	err := c.cc.Invoke(ctx, PluginService_ListReleases_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pluginServiceClient) GetRelease(ctx context.Context, in *GetReleaseRequest, opts ...grpc.CallOption) (*Release, error) {
+	out := new(Release)
+	err := c.cc.Invoke(ctx, PluginService_GetRelease_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pluginServiceClient) DownloadAsset(ctx context.Context, in *DownloadAssetRequest, opts ...grpc.CallOption) (PluginService_DownloadAssetClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PluginService_ServiceDesc.Streams[0], PluginService_DownloadAsset_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &pluginServiceDownloadAssetClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PluginService_DownloadAssetClient interface {
+	Recv() (*AssetChunk, error)
+	grpc.ClientStream
+}
+
+type pluginServiceDownloadAssetClient struct {
+	grpc.ClientStream
+}
+
+func (x *pluginServiceDownloadAssetClient) Recv() (*AssetChunk, error) {
+	m := new(AssetChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *pluginServiceClient) ResolveDependencies(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error) {
+	out := new(ResolveResponse)
+	err := c.cc.Invoke(ctx, PluginService_ResolveDependencies_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PluginServiceServer is the server API for PluginService service.
+// All implementations must embed UnimplementedPluginServiceServer
+// for forward compatibility
+type PluginServiceServer interface {
+	// ListPlugins is the same as `GET /v1/plugins`
+	ListPlugins(context.Context, *ListPluginsRequest) (*ListPluginsResponse, error)
+	// GetPlugin is the same as `GET /v1/plugin/{id}/info`
+	GetPlugin(context.Context, *GetPluginRequest) (*Plugin, error)
+	// ListReleases is the same as `GET /v1/plugin/{id}/releases`, the releases are sorted from the newest
+	ListReleases(context.Context, *ListReleasesRequest) (*ListReleasesResponse, error)
+	// GetRelease is the same as `GET /v1/plugin/{id}/release/{tag}`
+	GetRelease(context.Context, *GetReleaseRequest) (*Release, error)
+	// DownloadAsset streams the asset of a release in chunks,
+	// the download is only counted when the offset is zero
+	DownloadAsset(*DownloadAssetRequest, PluginService_DownloadAssetServer) error
+	// ResolveDependencies picks the newest release of the plugins and all of their dependencies
+	// which matches every version condition
+	ResolveDependencies(context.Context, *ResolveRequest) (*ResolveResponse, error)
+	mustEmbedUnimplementedPluginServiceServer()
+}
+
+// UnimplementedPluginServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPluginServiceServer struct {
+}
+
+func (UnimplementedPluginServiceServer) ListPlugins(context.Context, *ListPluginsRequest) (*ListPluginsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPlugins not implemented")
+}
+func (UnimplementedPluginServiceServer) GetPlugin(context.Context, *GetPluginRequest) (*Plugin, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPlugin not implemented")
+}
+func (UnimplementedPluginServiceServer) ListReleases(context.Context, *ListReleasesRequest) (*ListReleasesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListReleases not implemented")
+}
+func (UnimplementedPluginServiceServer) GetRelease(context.Context, *GetReleaseRequest) (*Release, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRelease not implemented")
+}
+func (UnimplementedPluginServiceServer) DownloadAsset(*DownloadAssetRequest, PluginService_DownloadAssetServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadAsset not implemented")
+}
+func (UnimplementedPluginServiceServer) ResolveDependencies(context.Context, *ResolveRequest) (*ResolveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveDependencies not implemented")
+}
+func (UnimplementedPluginServiceServer) mustEmbedUnimplementedPluginServiceServer() {}
+
+// UnsafePluginServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PluginServiceServer will
+// result in compilation errors.
+type UnsafePluginServiceServer interface {
+	mustEmbedUnimplementedPluginServiceServer()
+}
+
+func RegisterPluginServiceServer(s grpc.ServiceRegistrar, srv PluginServiceServer) {
+	s.RegisterService(&PluginService_ServiceDesc, srv)
+}
+
+func _PluginService_ListPlugins_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPluginsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PluginServiceServer).ListPlugins(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PluginService_ListPlugins_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PluginServiceServer).ListPlugins(ctx, req.(*ListPluginsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PluginService_GetPlugin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPluginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PluginServiceServer).GetPlugin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PluginService_GetPlugin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PluginServiceServer).GetPlugin(ctx, req.(*GetPluginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PluginService_ListReleases_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListReleasesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PluginServiceServer).ListReleases(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PluginService_ListReleases_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PluginServiceServer).ListReleases(ctx, req.(*ListReleasesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PluginService_GetRelease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReleaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PluginServiceServer).GetRelease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PluginService_GetRelease_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PluginServiceServer).GetRelease(ctx, req.(*GetReleaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PluginService_DownloadAsset_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadAssetRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginServiceServer).DownloadAsset(m, &pluginServiceDownloadAssetServer{stream})
+}
+
+type PluginService_DownloadAssetServer interface {
+	Send(*AssetChunk) error
+	grpc.ServerStream
+}
+
+type pluginServiceDownloadAssetServer struct {
+	grpc.ServerStream
+}
+
+func (x *pluginServiceDownloadAssetServer) Send(m *AssetChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PluginService_ResolveDependencies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PluginServiceServer).ResolveDependencies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PluginService_ResolveDependencies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PluginServiceServer).ResolveDependencies(ctx, req.(*ResolveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PluginService_ServiceDesc is the grpc.ServiceDesc for PluginService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PluginService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pwp.v1.PluginService",
+	HandlerType: (*PluginServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListPlugins",
+			Handler:    _PluginService_ListPlugins_Handler,
+		},
+		{
+			MethodName: "GetPlugin",
+			Handler:    _PluginService_GetPlugin_Handler,
+		},
+		{
+			MethodName: "ListReleases",
+			Handler:    _PluginService_ListReleases_Handler,
+		},
+		{
+			MethodName: "GetRelease",
+			Handler:    _PluginService_GetRelease_Handler,
+		},
+		{
+			MethodName: "ResolveDependencies",
+			Handler:    _PluginService_ResolveDependencies_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "DownloadAsset",
+			Handler:       _PluginService_DownloadAsset_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pwp.proto",
+}
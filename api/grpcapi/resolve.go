@@ -0,0 +1,134 @@
+
+package grpcapi
+
+import (
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+// maxResolvePlugins is the max number of the plugins that can be visited by a resolution
+const maxResolvePlugins = 256
+
+type ResolvedPlugin struct{
+	Id         string
+	Release    *api.PluginRelease
+	RequiredBy []string
+}
+
+type UnresolvedPlugin struct{
+	Id         string
+	Condition  api.VersionCondList
+	RequiredBy []string
+	Reason     string // `NotFound` or `NoMatchingRelease`
+}
+
+type Resolution struct{
+	Plugins    []*ResolvedPlugin
+	Unresolved []*UnresolvedPlugin
+}
+
+type resolveNode struct{
+	conds      api.VersionCondList
+	requiredBy []string
+	found      bool
+}
+
+func sortedKeys[T any](m map[string]T)(keys []string){
+	keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// pickRelease returns the newest release which matches the conditions, or nil if there is none
+func pickRelease(ins api.API, id string, conds api.VersionCondList, allowPrerelease bool)(*api.PluginRelease, error){
+	releases, err := ins.GetPluginReleases(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range sortReleases(releases) {
+		if (allowPrerelease || r.Stable) && conds.IsMatch(r.Tag) {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// Resolve picks the newest release of the plugins and all of their dependencies, which matches all version conditions.
+// The dependencies are read from the latest metadata of the plugins, since the older ones are not stored.
+// The pre-releases are skipped unless allowPrerelease is true
+func Resolve(ins api.API, plugins map[string]api.VersionCondList, allowPrerelease bool)(res *Resolution, err error){
+	nodes := make(map[string]*resolveNode)
+	var queue []string
+	add := func(id string, cond api.VersionCondList, by string){
+		n := nodes[id]
+		if n == nil {
+			n = new(resolveNode)
+			nodes[id] = n
+			queue = append(queue, id)
+		}
+		n.conds = append(n.conds, cond...)
+		if len(by) > 0 {
+			n.requiredBy = append(n.requiredBy, by)
+		}
+	}
+	for _, id := range sortedKeys(plugins) {
+		add(id, plugins[id], "")
+	}
+	for len(queue) > 0 {
+		if len(nodes) > maxResolvePlugins {
+			return nil, status.Errorf(codes.ResourceExhausted, "too many dependencies, the limit is %d", maxResolvePlugins)
+		}
+		id := queue[0]
+		queue = queue[1:]
+		var info *api.PluginInfo
+		if info, err = ins.GetPluginInfo(id, "latest"); err != nil {
+			if err == api.ErrNotFound {
+				continue
+			}
+			return
+		}
+		nodes[id].found = true
+		for _, dep := range sortedKeys(info.Dependencies) {
+			add(dep, info.Dependencies[dep], id)
+		}
+	}
+	err = nil
+
+	res = new(Resolution)
+	for _, id := range sortedKeys(nodes) {
+		n := nodes[id]
+		sort.Strings(n.requiredBy)
+		if n.found {
+			var release *api.PluginRelease
+			if release, err = pickRelease(ins, id, n.conds, allowPrerelease); err != nil {
+				return nil, err
+			}
+			if release != nil {
+				res.Plugins = append(res.Plugins, &ResolvedPlugin{
+					Id: id,
+					Release: release,
+					RequiredBy: n.requiredBy,
+				})
+				continue
+			}
+		}
+		reason := "NotFound"
+		if n.found {
+			reason = "NoMatchingRelease"
+		}
+		res.Unresolved = append(res.Unresolved, &UnresolvedPlugin{
+			Id: id,
+			Condition: n.conds,
+			RequiredBy: n.requiredBy,
+			Reason: reason,
+		})
+	}
+	return
+}
@@ -0,0 +1,73 @@
+
+// Package grpcapi serves the read-only part of the v1 API over gRPC, see pwpv1/pwp.proto for the service definition
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // accept the gzip compressed requests
+	"google.golang.org/grpc/status"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+	pb "github.com/kmcsr/PluginWebPoint/api/grpcapi/pwpv1"
+)
+
+//go:generate protoc --proto_path=pwpv1 --go_out=pwpv1 --go_opt=paths=source_relative --go-grpc_out=pwpv1 --go-grpc_opt=paths=source_relative pwp.proto
+
+// assetChunkSize is the max size of the data in an AssetChunk
+const assetChunkSize = 32 * 1024
+
+// statusOf converts the error returned by the api to a gRPC status error
+func statusOf(err error)(error){
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	}
+	loger.Errorf("Unexpected error: %v", err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+func unaryStatus(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler)(resp any, err error){
+	loger.Debugf("Calling %s", info.FullMethod)
+	resp, err = handler(ctx, req)
+	return resp, statusOf(err)
+}
+
+func streamStatus(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler)(error){
+	loger.Debugf("Calling %s", info.FullMethod)
+	return statusOf(handler(srv, ss))
+}
+
+// Server implements PluginService with the api
+type Server struct{
+	pb.UnimplementedPluginServiceServer
+	ins api.API
+}
+
+var _ pb.PluginServiceServer = (*Server)(nil)
+
+func NewServer(ins api.API)(*Server){
+	return &Server{
+		ins: ins,
+	}
+}
+
+// NewGRPCServer creates a grpc.Server which serves PluginService,
+// the errors of the api are converted to the gRPC status codes, e.g. ErrNotFound to NOT_FOUND
+func NewGRPCServer(ins api.API, opts ...grpc.ServerOption)(s *grpc.Server){
+	opts = append(opts, grpc.ChainUnaryInterceptor(unaryStatus), grpc.ChainStreamInterceptor(streamStatus))
+	s = grpc.NewServer(opts...)
+	pb.RegisterPluginServiceServer(s, NewServer(ins))
+	return
+}
@@ -0,0 +1,184 @@
+
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+	pb "github.com/kmcsr/PluginWebPoint/api/grpcapi/pwpv1"
+)
+
+func parseTag(tag string)(v api.Version, err error){
+	if v, err = api.VersionFromString(tag); err != nil {
+		return v, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return
+}
+
+// sortReleases sorts a copy of the releases from the newest
+func sortReleases(releases []*api.PluginRelease)([]*api.PluginRelease){
+	releases = append(([]*api.PluginRelease)(nil), releases...)
+	sort.SliceStable(releases, func(i, j int)(bool){
+		return releases[j].Tag.Less(releases[i].Tag)
+	})
+	return releases
+}
+
+func (s *Server)ListPlugins(ctx context.Context, req *pb.ListPluginsRequest)(resp *pb.ListPluginsResponse, err error){
+	list, err := s.ins.GetPluginList(api.PluginListOpt{
+		FilterBy: req.FilterBy,
+		Tags: req.Tags,
+		SortBy: req.SortBy,
+		Reversed: req.Reversed,
+		Offset: (int)(req.Offset),
+		Limit: (int)(req.Limit),
+	})
+	if err != nil {
+		return
+	}
+	resp = &pb.ListPluginsResponse{
+		Plugins: make([]*pb.Plugin, len(list)),
+	}
+	for i, info := range list {
+		resp.Plugins[i] = pluginOf(info)
+	}
+	return
+}
+
+func (s *Server)GetPlugin(ctx context.Context, req *pb.GetPluginRequest)(*pb.Plugin, error){
+	if len(req.Id) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	info, err := s.ins.GetPluginInfo(req.Id, "latest")
+	if err != nil {
+		return nil, err
+	}
+	return pluginOf(info), nil
+}
+
+func (s *Server)ListReleases(ctx context.Context, req *pb.ListReleasesRequest)(resp *pb.ListReleasesResponse, err error){
+	if len(req.Id) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	releases, err := s.ins.GetPluginReleases(req.Id)
+	if err != nil {
+		return
+	}
+	resp = &pb.ListReleasesResponse{
+		Releases: make([]*pb.Release, len(releases)),
+	}
+	for i, r := range sortReleases(releases) {
+		resp.Releases[i] = releaseOf(r)
+	}
+	return
+}
+
+func (s *Server)GetRelease(ctx context.Context, req *pb.GetReleaseRequest)(*pb.Release, error){
+	tag, err := parseTag(req.Tag)
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.ins.GetPluginRelease(req.Id, tag)
+	if err != nil {
+		return nil, err
+	}
+	return releaseOf(release), nil
+}
+
+func (s *Server)DownloadAsset(req *pb.DownloadAssetRequest, stream pb.PluginService_DownloadAssetServer)(err error){
+	ctx := stream.Context()
+	tag, err := parseTag(req.Tag)
+	if err != nil {
+		return
+	}
+	if req.Offset < 0 {
+		return status.Error(codes.InvalidArgument, "offset cannot be negative")
+	}
+	fd, _, err := s.ins.GetPluginReleaseAsset(req.Id, tag, req.Filename)
+	if err != nil {
+		return
+	}
+	defer fd.Close()
+	size, err := fd.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	if req.Offset > size {
+		return status.Errorf(codes.OutOfRange, "offset %d is out of the asset size %d", req.Offset, size)
+	}
+	if _, err = fd.Seek(req.Offset, io.SeekStart); err != nil {
+		return
+	}
+	var sums api.Checksums
+	if release, err := s.ins.GetPluginRelease(req.Id, tag); err == nil {
+		if asset := release.GetAsset(req.Filename); asset != nil {
+			sums = asset.Checksums
+		}
+	}
+	if req.Offset == 0 {
+		// the resumed downloads are not counted, the same as the ranges of the v1 API
+		go func(){
+			if err := s.ins.RecordPluginDownload(req.Id, tag, req.Filename); err != nil {
+				loger.Errorf("Cannot record download for %s@%s:%s: %v", req.Id, tag, req.Filename, err)
+			}
+		}()
+	}
+
+	buf := make([]byte, assetChunkSize)
+	offset := req.Offset
+	first := true
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		n, er := io.ReadFull(fd, buf)
+		if n > 0 || first {
+			chunk := &pb.AssetChunk{
+				Offset: offset,
+				Data: buf[:n],
+			}
+			if first {
+				chunk.TotalSize = size
+				chunk.Checksums = checksumsOf(sums)
+				first = false
+			}
+			if err = stream.Send(chunk); err != nil {
+				return
+			}
+			offset += (int64)(n)
+		}
+		if er == io.EOF || er == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if er != nil {
+			return er
+		}
+	}
+}
+
+func (s *Server)ResolveDependencies(ctx context.Context, req *pb.ResolveRequest)(*pb.ResolveResponse, error){
+	if len(req.Plugins) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "plugins is required")
+	}
+	plugins := make(map[string]api.VersionCondList, len(req.Plugins))
+	for id, cond := range req.Plugins {
+		if len(cond) == 0 {
+			plugins[id] = nil
+			continue
+		}
+		var err error
+		if plugins[id], err = api.VersionCondListFromString(cond); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid condition for %s: %v", id, err)
+		}
+	}
+	res, err := Resolve(s.ins, plugins, req.AllowPrerelease)
+	if err != nil {
+		return nil, err
+	}
+	return resolutionOf(res), nil
+}
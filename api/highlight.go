@@ -0,0 +1,45 @@
+
+package api
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+var codeFormatter = chromahtml.New(
+	chromahtml.WithLineNumbers(true),
+	chromahtml.LineNumbersInTable(true),
+	chromahtml.TabWidth(4),
+)
+
+// IsTextFile reports whether the data looks like a UTF-8 text file
+func IsTextFile(data []byte)(bool){
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) == -1
+}
+
+// HighlightCode renders the source code as a HTML fragment with inline styles,
+// the language is detected by the filename first and then by the content
+func HighlightCode(filename string, src []byte)(out []byte, err error){
+	lexer := lexers.Match(filename)
+	if lexer == nil {
+		lexer = lexers.Analyse((string)(src))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+	iterator, err := lexer.Tokenise(nil, (string)(src))
+	if err != nil {
+		return
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(src) * 4))
+	if err = codeFormatter.Format(buf, styles.Get("github"), iterator); err != nil {
+		return
+	}
+	return buf.Bytes(), nil
+}
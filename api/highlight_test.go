@@ -0,0 +1,31 @@
+package api_test
+
+import (
+	"strings"
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestHighlightCode(t *testing.T){
+	out, err := api.HighlightCode("hello_world/__init__.py", ([]byte)("def on_load(server, prev):\n\tprint('<script>')\n"))
+	if err != nil {
+		t.Fatalf("Cannot highlight: %v", err)
+	}
+	html := string(out)
+	if strings.Contains(html, "<script>") {
+		t.Errorf("The source is not escaped: %s", html)
+	}
+	if !strings.Contains(html, "on_load") || !strings.Contains(html, "<table") {
+		t.Errorf("Unexpected output: %s", html)
+	}
+}
+
+func TestIsTextFile(t *testing.T){
+	if !api.IsTextFile(([]byte)("PyYAML\nrequests>=2.0\n")) {
+		t.Errorf("Expect text file")
+	}
+	if api.IsTextFile([]byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00}) {
+		t.Errorf("Expect binary file")
+	}
+}
@@ -0,0 +1,143 @@
+
+// Package apitest provides a small in-memory catalogue for the tests of the API frontends
+package apitest
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+type nopCloser struct{
+	*bytes.Reader
+}
+
+func (nopCloser)Close()(error){ return nil }
+
+// FakeAPI serves the plugins and the releases in the maps, and counts the queries.
+// The methods not implemented by FakeAPI panic
+type FakeAPI struct{
+	api.API
+	Plugins  map[string]*api.PluginInfo
+	// Releases are sorted from the newest, the same as the api
+	Releases map[string][]*api.PluginRelease
+	// Asset is the content of lib.mcdr of lib@2.0.0
+	Asset    []byte
+
+	mux   sync.Mutex
+	calls map[string]int
+}
+
+func (f *FakeAPI)call(key string){
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.calls[key]++
+}
+
+// Calls returns how many times each plugin is queried, e.g. `info:hello` and `releases:lib`
+func (f *FakeAPI)Calls()(calls map[string]int){
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	calls = make(map[string]int, len(f.calls))
+	for k, n := range f.calls {
+		calls[k] = n
+	}
+	return
+}
+
+func (f *FakeAPI)GetPluginList(opt api.PluginListOpt)(list []*api.PluginInfo, err error){
+	for _, id := range []string{"hello", "lib"} {
+		list = append(list, f.Plugins[id])
+	}
+	return
+}
+
+func (f *FakeAPI)GetPluginInfo(id string, version string)(*api.PluginInfo, error){
+	f.call("info:" + id)
+	if p, ok := f.Plugins[id]; ok {
+		return p, nil
+	}
+	return nil, api.ErrNotFound
+}
+
+func (f *FakeAPI)GetPluginReleases(id string)([]*api.PluginRelease, error){
+	f.call("releases:" + id)
+	return f.Releases[id], nil
+}
+
+func (f *FakeAPI)GetPluginRelease(id string, tag api.Version)(*api.PluginRelease, error){
+	for _, r := range f.Releases[id] {
+		if r.Tag.Equal(tag) {
+			return r, nil
+		}
+	}
+	return nil, api.ErrNotFound
+}
+
+func (f *FakeAPI)GetPluginReleaseAsset(id string, tag api.Version, filename string)(io.ReadSeekCloser, time.Time, error){
+	if id != "lib" || filename != "lib.mcdr" {
+		return nil, time.Time{}, api.ErrNotFound
+	}
+	return nopCloser{bytes.NewReader(f.Asset)}, time.Time{}, nil
+}
+
+func (f *FakeAPI)RecordPluginDownload(id string, tag api.Version, filename string)(error){
+	return nil
+}
+
+func MustVersion(s string)(api.Version){
+	v, err := api.VersionFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func MustCond(s string)(api.VersionCondList){
+	v, err := api.VersionCondListFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// NewFakeAPI returns the catalogue of two plugins:
+// hello@1.2.0 depends on lib `>=1.0.0 <2.0.0` and mcdreforged (not in the catalogue),
+// and lib has the releases 2.0.0, 1.5.0 (pre-release) and 1.4.0
+func NewFakeAPI()(*FakeAPI){
+	t := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	asset := make([]byte, 70000)
+	for i := range asset {
+		asset[i] = (byte)(i)
+	}
+	return &FakeAPI{
+		Plugins: map[string]*api.PluginInfo{
+			"hello": {
+				Id: "hello", Name: "Hello", Version: MustVersion("1.2.0"), Authors: []string{"alice"}, CreateAt: t,
+				Labels: api.PluginLabels{ Tool: true },
+				Dependencies: api.DependMap{
+					"lib": MustCond(">=1.0.0 <2.0.0"),
+					"mcdreforged": MustCond(">=2.0.0"),
+				},
+				GithubSync: true,
+			},
+			"lib": { Id: "lib", Name: "Lib", Version: MustVersion("2.0.0"), Authors: []string{"alice", "bob"}, CreateAt: t },
+		},
+		Releases: map[string][]*api.PluginRelease{
+			"hello": {
+				{ Id: "hello", Tag: MustVersion("1.2.0"), Stable: true, Uploaded: t },
+			},
+			"lib": {
+				{ Id: "lib", Tag: MustVersion("2.0.0"), Stable: true, Uploaded: t,
+					Assets: []*api.ReleaseAsset{{ Name: "lib.mcdr", Checksums: api.Checksums{ Sha256: "abcd" } }} },
+				{ Id: "lib", Tag: MustVersion("1.5.0"), Stable: false, Uploaded: t },
+				{ Id: "lib", Tag: MustVersion("1.4.0"), Stable: true, Uploaded: t },
+			},
+		},
+		Asset: asset,
+		calls: make(map[string]int),
+	}
+}
@@ -0,0 +1,187 @@
+
+package api
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	McdrMetaFile = "mcdreforged.plugin.json"
+	McdrRequirementsFile = "requirements.txt"
+	// McdrMaxSize is the max size of a .mcdr file that will be inspected
+	McdrMaxSize = 64 * 1024 * 1024
+	// McdrMaxViewSize is the max size of a file inside the .mcdr package that can be viewed
+	McdrMaxViewSize = 4 * 1024 * 1024
+)
+
+var (
+	ErrNotMcdrPackage = errors.New("ErrNotMcdrPackage")
+	ErrMcdrTooLarge = errors.New("ErrMcdrTooLarge")
+	ErrFileTooLarge = errors.New("ErrFileTooLarge")
+)
+
+// McdrMeta is the plugin metadata embedded in the .mcdr package,
+// see <https://mcdreforged.readthedocs.io/en/latest/plugin_dev/metadata.html>
+type McdrMeta struct {
+	Id           string            `json:"id"`
+	Version      string            `json:"version"`
+	Name         any               `json:"name,omitempty"`        // a string or a translation map
+	Description  any               `json:"description,omitempty"` // a string or a translation map
+	Author       any               `json:"author,omitempty"`      // a string or a string list
+	Link         string            `json:"link,omitempty"`
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	Entrypoint   string            `json:"entrypoint"`
+	ArchiveName  string            `json:"archive_name,omitempty"`
+	Resources    []string          `json:"resources,omitempty"`
+}
+
+type McdrFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+type McdrInfo struct {
+	Meta         McdrMeta    `json:"meta"`
+	Requirements []string    `json:"requirements,omitempty"`
+	Files        []*McdrFile `json:"files"`
+	// Problems lists the disagreements between the package and the release
+	Problems     []string    `json:"problems"`
+}
+
+// ReadMcdr reads the metadata and the file list of a .mcdr package
+func ReadMcdr(r io.ReaderAt, size int64)(info *McdrInfo, err error){
+	var zr *zip.Reader
+	if zr, err = zip.NewReader(r, size); err != nil {
+		return nil, ErrNotMcdrPackage
+	}
+	info = &McdrInfo{
+		Files: make([]*McdrFile, 0, len(zr.File)),
+	}
+	var metaFile, reqFile *zip.File
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		switch f.Name {
+		case McdrMetaFile:
+			metaFile = f
+		case McdrRequirementsFile:
+			reqFile = f
+		}
+		info.Files = append(info.Files, &McdrFile{
+			Name: f.Name,
+			Size: (int64)(f.UncompressedSize64),
+			ModTime: f.Modified,
+		})
+	}
+	if metaFile == nil {
+		return nil, ErrNotMcdrPackage
+	}
+	var data []byte
+	if data, err = readZipFile(metaFile, 1024 * 1024); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &info.Meta); err != nil {
+		return nil, fmt.Errorf("Cannot parse %s: %w", McdrMetaFile, err)
+	}
+	if len(info.Meta.Entrypoint) == 0 {
+		info.Meta.Entrypoint = info.Meta.Id
+	}
+	if reqFile != nil {
+		if data, err = readZipFile(reqFile, 1024 * 1024); err != nil {
+			return
+		}
+		sc := bufio.NewScanner(strings.NewReader((string)(data)))
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if len(line) > 0 && line[0] != '#' {
+				info.Requirements = append(info.Requirements, line)
+			}
+		}
+	}
+	return
+}
+
+// ReadMcdrFile reads a file inside the .mcdr package, ErrNotFound will be returned if the file does not exist
+func ReadMcdrFile(r io.ReaderAt, size int64, name string)(data []byte, file *McdrFile, err error){
+	var zr *zip.Reader
+	if zr, err = zip.NewReader(r, size); err != nil {
+		return nil, nil, ErrNotMcdrPackage
+	}
+	for _, f := range zr.File {
+		if f.Name != name || f.FileInfo().IsDir() {
+			continue
+		}
+		if (int64)(f.UncompressedSize64) > McdrMaxViewSize {
+			return nil, nil, ErrFileTooLarge
+		}
+		if data, err = readZipFile(f, McdrMaxViewSize); err != nil {
+			return
+		}
+		file = &McdrFile{
+			Name: f.Name,
+			Size: (int64)(f.UncompressedSize64),
+			ModTime: f.Modified,
+		}
+		return
+	}
+	return nil, nil, ErrNotFound
+}
+
+func readZipFile(f *zip.File, limit int64)(data []byte, err error){
+	if (int64)(f.UncompressedSize64) > limit {
+		return nil, fmt.Errorf("%s is too large", f.Name)
+	}
+	var rc io.ReadCloser
+	if rc, err = f.Open(); err != nil {
+		return
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, limit))
+}
+
+// GetProblems returns the problems, it's safe to call on nil
+func (info *McdrInfo)GetProblems()([]string){
+	if info == nil {
+		return nil
+	}
+	return info.Problems
+}
+
+// HasFile reports whether the package contains the file
+func (info *McdrInfo)HasFile(name string)(bool){
+	for _, f := range info.Files {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Check compares the embedded metadata with the plugin id and the release tag,
+// and checks if the entrypoint module exists
+func (info *McdrInfo)Check(id string, tag Version)(problems []string){
+	meta := &info.Meta
+	if meta.Id != id {
+		problems = append(problems, fmt.Sprintf("Embedded plugin id %q does not match %q", meta.Id, id))
+	}
+	if v, err := VersionFromString(meta.Version); err != nil {
+		problems = append(problems, fmt.Sprintf("Embedded version %q is invalid: %v", meta.Version, err))
+	}else if !v.Equal(tag) {
+		problems = append(problems, fmt.Sprintf("Embedded version %q does not match the release tag %q", meta.Version, tag))
+	}
+	module := strings.ReplaceAll(meta.Entrypoint, ".", "/")
+	if !info.HasFile(module + ".py") && !info.HasFile(path.Join(module, "__init__.py")) {
+		problems = append(problems, fmt.Sprintf("Entrypoint module %q does not exist", meta.Entrypoint))
+	}
+	return
+}
@@ -0,0 +1,135 @@
+package api_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func makeZip(t *testing.T, files map[string]string)(*bytes.Reader){
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Cannot create zip entry: %v", err)
+		}
+		w.Write(([]byte)(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Cannot close zip: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadMcdr(t *testing.T){
+	r := makeZip(t, map[string]string{
+		"mcdreforged.plugin.json": `{
+			"id": "hello_world",
+			"version": "1.2.0",
+			"name": "Hello World",
+			"author": ["Alice", "Bob"],
+			"dependencies": {"mcdreforged": ">=2.0.0"}
+		}`,
+		"requirements.txt": "# comment\nrequests>=2.0\n\nPyYAML\n",
+		"hello_world/__init__.py": "def on_load(server, prev): pass\n",
+		"lang/en_us.yml": "",
+	})
+	info, err := api.ReadMcdr(r, r.Size())
+	if err != nil {
+		t.Fatalf("Cannot read mcdr: %v", err)
+	}
+	if info.Meta.Id != "hello_world" || info.Meta.Version != "1.2.0" {
+		t.Errorf("Wrong metadata: %#v", info.Meta)
+	}
+	if info.Meta.Entrypoint != "hello_world" {
+		t.Errorf("Entrypoint should default to the id, got %q", info.Meta.Entrypoint)
+	}
+	if !reflect.DeepEqual(info.Requirements, []string{"requests>=2.0", "PyYAML"}) {
+		t.Errorf("Wrong requirements: %v", info.Requirements)
+	}
+	if len(info.Files) != 4 || !info.HasFile("lang/en_us.yml") {
+		t.Errorf("Wrong file list: %v", info.Files)
+	}
+
+	if problems := info.Check("hello_world", V{Comps: []int{1, 2, 0}}); len(problems) != 0 {
+		t.Errorf("Expect no problem, got %v", problems)
+	}
+	if problems := info.Check("hello", V{Comps: []int{1, 3, 0}}); len(problems) != 2 {
+		t.Errorf("Expect id and version problems, got %v", problems)
+	}
+	info.Meta.Entrypoint = "hello_world.entry"
+	if problems := info.Check("hello_world", V{Comps: []int{1, 2, 0}}); len(problems) != 1 {
+		t.Errorf("Expect entrypoint problem, got %v", problems)
+	}
+}
+
+func TestReadMcdrInvalid(t *testing.T){
+	if _, err := api.ReadMcdr(bytes.NewReader([]byte("PLUGIN_METADATA = {}")), 20); err != api.ErrNotMcdrPackage {
+		t.Errorf("Expect ErrNotMcdrPackage for a python file, got %v", err)
+	}
+	r := makeZip(t, map[string]string{
+		"hello_world/__init__.py": "",
+	})
+	if _, err := api.ReadMcdr(r, r.Size()); err != api.ErrNotMcdrPackage {
+		t.Errorf("Expect ErrNotMcdrPackage without metadata, got %v", err)
+	}
+}
+
+func TestReadMcdrFile(t *testing.T){
+	r := makeZip(t, map[string]string{
+		"mcdreforged.plugin.json": `{"id": "hello_world", "version": "1.0.0"}`,
+		"hello_world/__init__.py": "def on_load(server, prev): pass\n",
+	})
+	data, file, err := api.ReadMcdrFile(r, r.Size(), "hello_world/__init__.py")
+	if err != nil {
+		t.Fatalf("Cannot read file: %v", err)
+	}
+	if string(data) != "def on_load(server, prev): pass\n" || file.Size != (int64)(len(data)) {
+		t.Errorf("Wrong file %#v: %q", file, data)
+	}
+	if _, _, err := api.ReadMcdrFile(r, r.Size(), "hello_world"); err != api.ErrNotFound {
+		t.Errorf("Expect ErrNotFound for a directory, got %v", err)
+	}
+	if _, _, err := api.ReadMcdrFile(r, r.Size(), "not_exists.py"); err != api.ErrNotFound {
+		t.Errorf("Expect ErrNotFound, got %v", err)
+	}
+}
+
+func TestValidateReleasePackage(t *testing.T){
+	r := makeZip(t, map[string]string{
+		"mcdreforged.plugin.json": `{"id": "hello_world", "version": "1.0.0"}`,
+		"hello_world/__init__.py": "",
+	})
+	data := make([]byte, r.Size())
+	r.ReadAt(data, 0)
+	if _, err := api.ValidateReleasePackage("hello_world", V{Comps: []int{1, 0, 0}}, data); err != nil {
+		t.Errorf("Expect a valid package, got %v", err)
+	}
+	var perr *api.PackageProblemsErr
+	if _, err := api.ValidateReleasePackage("hello_world", V{Comps: []int{1, 1, 0}}, data); !errors.As(err, &perr) || len(perr.Problems) != 1 {
+		t.Errorf("Expect a version problem, got %v", err)
+	}
+}
+
+func TestReleaseAssetName(t *testing.T){
+	tag := V{Comps: []int{1, 0, 0}}
+	cases := map[string]string{
+		"": "hello_world-v1.0.0.mcdr",
+		"HelloWorld-v1.0.0.mcdr": "HelloWorld-v1.0.0.mcdr",
+		"../../etc/HelloWorld.mcdr": "HelloWorld.mcdr",
+		"C:\\build\\HelloWorld.mcdr": "HelloWorld.mcdr",
+	}
+	for name, expect := range cases {
+		if out, err := api.ReleaseAssetName("hello_world", tag, name); err != nil || out != expect {
+			t.Errorf("ReleaseAssetName(%q) returned (%q, %v), expect %q", name, out, err, expect)
+		}
+	}
+	if _, err := api.ReleaseAssetName("hello_world", tag, "hello_world.py"); err == nil {
+		t.Errorf("Expect an error for a non .mcdr file")
+	}
+}
@@ -0,0 +1,150 @@
+
+package mysqlimpl
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	. "github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api/ghsync"
+)
+
+// releaseExists checks the release whether it's enabled or not
+func (api *MySqlAPI)releaseExists(ctx context.Context, id string, tag Version)(err error){
+	const queryCmd = "SELECT 1 FROM plugin_releases WHERE `id`=? AND `tag`=?"
+
+	var n int
+	if err = api.DB.QueryRowContext(ctx, queryCmd, id, tag).Scan(&n); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return
+	}
+	return
+}
+
+// SetPluginEnabled shows or hides the plugin, the value will not be overwritten by the github syncs.
+// The plugin is reported as added or removed to the change log and the event subscribers if it's changed
+func (api *MySqlAPI)SetPluginEnabled(id string, enabled bool)(err error){
+	const queryCmd = "SELECT `enabled` FROM plugins WHERE `id`=? FOR UPDATE"
+	const updateCmd = "UPDATE plugins SET `enabled`=?,`enabled_locked`=TRUE WHERE `id`=?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var tx *sql.Tx
+	if tx, err = api.DB.BeginTx(ctx, nil); err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	var old bool
+	if err = tx.QueryRowContext(ctx, queryCmd, id).Scan(&old); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return
+	}
+	if _, err = tx.ExecContext(ctx, updateCmd, enabled, id); err != nil {
+		return
+	}
+	if err = touchPlugin(ctx, tx, id); err != nil {
+		return
+	}
+	if old != enabled {
+		ev := &CatalogueEvent{ Type: EventPluginRemoved, Plugin: id }
+		if enabled {
+			ev.Type = EventPluginAdded
+		}
+		if err = ghsync.RecordEvent(tx, ev); err != nil {
+			return
+		}
+	}
+	if err = ghsync.RecordChanges(ctx, tx, id); err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+// updateRelease runs the update command of the release, and bumps the `lastUpdate` of the plugin in the same transaction
+func (api *MySqlAPI)updateRelease(ctx context.Context, id string, tag Version, updateCmd string, args ...any)(err error){
+	var tx *sql.Tx
+	if tx, err = api.DB.BeginTx(ctx, nil); err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	var res sql.Result
+	if res, err = tx.ExecContext(ctx, updateCmd, args...); err != nil {
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return api.releaseExists(ctx, id, tag)
+	}
+	if err = touchPlugin(ctx, tx, id); err != nil {
+		return
+	}
+	if err = ghsync.RecordChanges(ctx, tx, id); err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+func (api *MySqlAPI)SetReleaseEnabled(id string, tag Version, enabled bool)(err error){
+	const updateCmd = "UPDATE plugin_releases SET `enabled`=? WHERE `id`=? AND `tag`=?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	return api.updateRelease(ctx, id, tag, updateCmd, enabled, id, tag)
+}
+
+// OverrideReleaseStable marks the release as stable or prerelease, the value will not be overwritten by the github syncs
+func (api *MySqlAPI)OverrideReleaseStable(id string, tag Version, stable bool)(err error){
+	const updateCmd = "UPDATE plugin_releases SET `stable`=?,`stable_locked`=TRUE WHERE `id`=? AND `tag`=?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	return api.updateRelease(ctx, id, tag, updateCmd, stable, id, tag)
+}
+
+// ResyncPlugin syncs the plugin from the catalogue immediately, instead of waiting for the next ghupdater run
+func (api *MySqlAPI)ResyncPlugin(id string)(err error){
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	if err = api.checkEditable(ctx, id); err == nil {
+		return ErrNotGithubSynced
+	}else if err != ErrGithubSynced && err != ErrNotFound {
+		return
+	}
+	loger.Infof("Resyncing plugin %s", id)
+	return ghsync.ResyncPlugin(api.DB, id)
+}
+
+// PurgePluginCache removes the cached assets and the cached github responses of the plugin,
+// the github responses are only removed from this instance
+func (api *MySqlAPI)PurgePluginCache(id string)(n int, err error){
+	const queryCmd = "SELECT `ghRepoOwner`,`ghRepoName` FROM plugins WHERE `id`=?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var owner, name string
+	if err = api.DB.QueryRowContext(ctx, queryCmd, id).Scan(&owner, &name); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return
+	}
+	if n, err = api.AssetCache.Purge(id + "/"); err != nil {
+		return
+	}
+	if len(owner) > 0 && len(name) > 0 {
+		n += api.GithubCli.PurgeCache("https://api.github.com/repos/" + owner + "/" + name + "/")
+	}
+	loger.Infof("Purged %d cached files of plugin %s", n, id)
+	return
+}
@@ -7,24 +7,47 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
+	"path"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
 	. "github.com/kmcsr/PluginWebPoint/api"
 )
 
+// The sub query commands that sum the downloads which served by ourselves.
+// `a` is the alias of table `plugins`, and `r` is the alias of table `plugin_releases`
+const (
+	// the local downloads of the plugins and the releases only count the main files of the releases,
+	// the same as the downloads from github
+	localDownloadsSubCmd = "(SELECT SUM(c.`count`) FROM plugin_downloads AS c JOIN plugin_releases AS cr" +
+		" ON cr.`id`=c.`id` AND cr.`tag`=c.`tag` AND cr.`filename`=c.`filename` WHERE c.`id`=a.`id`)"
+	releaseLocalDownloadsSubCmd = "(SELECT SUM(c.`count`) FROM plugin_downloads AS c" +
+		" WHERE c.`id`=r.`id` AND c.`tag`=r.`tag` AND c.`filename`=r.`filename`)"
+)
+
 type MySqlAPI struct {
 	name string
 	DB *sql.DB
 	GithubCli *GhClient
+	PluginStore BlobStore // stores the READMEs and the assets of the plugins that not synced from github
+	AssetCache *AssetCache
+
+	fetchMux sync.Mutex
+	fetching map[string]*StreamFile // the assets that are downloading, keyed by the cache key
+
+	keyMux sync.Mutex
+	keys map[string]*cachedAPIKey // the verified API keys, keyed by the key id
+	keyUsage map[string]int64 // the requests of the API keys that not flushed yet
 }
 
 var _ API = (*MySqlAPI)(nil)
@@ -34,6 +57,9 @@ func NewMySqlAPI(username string, passwd string, address string, database string
 	api = &MySqlAPI{
 		name: database,
 		GithubCli: ghCli,
+		fetching: make(map[string]*StreamFile),
+		keys: make(map[string]*cachedAPIKey),
+		keyUsage: make(map[string]int64),
 	}
 
 	loger.Infof("Connecting to db %s:*@%s/%s", username, address, database)
@@ -54,6 +80,12 @@ func NewMySqlAPI(username string, passwd string, address string, database string
 	if api.GithubCli == nil {
 		api.GithubCli = InitGithubCli()
 	}
+	if api.PluginStore == nil {
+		api.PluginStore = InitBlobStore(PLUGIN_DIR)
+	}
+	if api.AssetCache == nil {
+		api.AssetCache = InitAssetCache()
+	}
 	return
 }
 
@@ -138,28 +170,153 @@ func (api *MySqlAPI)GetPluginCounts(opt PluginListOpt)(count PluginCounts, err e
 }
 
 func (api *MySqlAPI)GetPluginList(opt PluginListOpt)(infos []*PluginInfo, err error){
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	err = api.eachPlugin(ctx, opt, nil, func(info *PluginInfo, _ pluginListKey)(error){
+		infos = append(infos, info)
+		return nil
+	})
+	return
+}
+
+// pluginListPageSize is how many rows are read from the database at a time when the plugin list is streamed
+const pluginListPageSize = 100
+
+// StreamPluginList reads the list in keyset pages, and calls fn with the rows of a page after the page is read,
+// so only one page is kept in memory and the database connection is not held while a slow client is receiving
+func (api *MySqlAPI)StreamPluginList(opt PluginListOpt, fn func(info *PluginInfo)(error))(err error){
+	return streamPluginPages(opt, pluginListPageSize, func(page pluginListPage)(rows []pluginListRow, err error){
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+		defer cancel()
+
+		err = api.eachPlugin(ctx, opt, &page, func(info *PluginInfo, key pluginListKey)(error){
+			rows = append(rows, pluginListRow{ info: info, key: key })
+			return nil
+		})
+		return
+	}, fn)
+}
+
+// pluginListKey is the position of a plugin in the sorted list
+type pluginListKey struct{
+	sort any // the value of the sort column, nil if the list is only sorted by id
+	id string
+}
+
+// pluginListPage selects the rows after the key, the offset is only used by the first page
+type pluginListPage struct{
+	after *pluginListKey
+	offset int
+	limit int
+}
+
+type pluginListRow struct{
+	info *PluginInfo
+	key pluginListKey
+}
+
+// streamPluginPages reads the pages with query until a page is not full or the limit of opt is reached,
+// and calls fn with the rows of each page before the next page is read
+func streamPluginPages(opt PluginListOpt, size int,
+	query func(page pluginListPage)([]pluginListRow, error), fn func(info *PluginInfo)(error))(err error){
+	page := pluginListPage{ offset: opt.Offset }
+	if page.offset < 0 {
+		page.offset = 0
+	}
+	remain := opt.Limit
+	for {
+		page.limit = size
+		if opt.Limit > 0 && remain < page.limit {
+			page.limit = remain
+		}
+		if page.limit <= 0 {
+			return
+		}
+		var rows []pluginListRow
+		if rows, err = query(page); err != nil {
+			return
+		}
+		for _, r := range rows {
+			if err = fn(r.info); err != nil {
+				return
+			}
+		}
+		if len(rows) < page.limit {
+			return
+		}
+		remain -= len(rows)
+		page.offset = 0
+		page.after = &rows[len(rows) - 1].key
+	}
+}
+
+// eachPlugin calls fn with the plugins of the list.
+// If page is not nil, the list is sorted by the sort column and then the id, and only the rows of the page are read
+func (api *MySqlAPI)eachPlugin(ctx context.Context, opt PluginListOpt, page *pluginListPage, fn func(info *PluginInfo, key pluginListKey)(error))(err error){
 	const queryCmd = "SELECT a.`id`,a.`name`,a.`version`,a.`authors`,a.`desc`,a.`desc_zhCN`," +
 		"CONVERT_TZ(a.`createAt`,@@session.time_zone,'+00:00') AS `utc_createAt`," +
 		"CONVERT_TZ(a.`lastRelease`,@@session.time_zone,'+00:00') AS `utc_lastRelease`," +
 		"`label_information`,`label_tool`,`label_management`,`label_api`," +
 		"`github_sync`," +
 		"CONVERT_TZ(`last_sync`,@@session.time_zone,'+00:00') AS `utc_last_sync`," +
-		"SUM(b.`downloads`) AS `downloads`" +
-		" FROM plugins as a LEFT JOIN plugin_releases as b" +
+		"IFNULL(SUM(b.`downloads`),0)+IFNULL(" + localDownloadsSubCmd + ",0) AS `downloads`," +
+		localDownloadsSubCmd + " AS `localDownloads`"
+	const fromCmd = " FROM plugins as a LEFT JOIN plugin_releases as b" +
 		" ON a.`id`=b.`id` WHERE a.`enabled`=TRUE"
 
 	loger.Debugf("Getting plugin list with option %#v", opt)
+	opt0 := pluginListOpt{opt}
+	sortCol, desc, aggregate := opt0.sortColumn()
 	cmd := queryCmd
+	if sortCol != "" && !aggregate {
+		cmd += "," + sortCol + " AS `sort_key`"
+	}else{
+		// the downloads are scanned as the key, since the alias cannot be selected again
+		cmd += ",NULL AS `sort_key`"
+	}
+	cmd += fromCmd
 	args := []any{}
-	opt0 := pluginListOpt{opt}
 	cmd, args = opt0.appendTextFilter(cmd, args)
 	cmd, args = opt0.appendTagFilter(cmd, args)
-	cmd += " GROUP BY a.`id`"
-	cmd, args = opt0.appendOrderBy(cmd, args)
-	cmd, args = opt0.appendLimit(cmd, args)
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
-	defer cancel()
+	if page == nil {
+		cmd += " GROUP BY a.`id`"
+		cmd, args = opt0.appendOrderBy(cmd, args)
+		cmd, args = opt0.appendLimit(cmd, args)
+	}else{
+		var after string
+		if page.after != nil {
+			op := ">"
+			if desc {
+				op = "<"
+			}
+			if sortCol == "" {
+				after = "a.`id`" + op + "?"
+				args = append(args, page.after.id)
+			}else{
+				after = "(" + sortCol + op + "? OR (" + sortCol + "=? AND a.`id`" + op + "?))"
+				args = append(args, page.after.sort, page.after.sort, page.after.id)
+			}
+		}
+		if after != "" && !aggregate {
+			cmd += " AND " + after
+		}
+		cmd += " GROUP BY a.`id`"
+		if after != "" && aggregate {
+			cmd += " HAVING " + after
+		}
+		dir := ""
+		if desc {
+			dir = " DESC"
+		}
+		if sortCol == "" {
+			cmd += " ORDER BY a.`id`" + dir
+		}else{
+			cmd += " ORDER BY " + sortCol + dir + ",a.`id`" + dir
+		}
+		cmd += " LIMIT ? OFFSET ?"
+		args = append(args, page.limit, page.offset)
+	}
 
 	var rows *sql.Rows
 	if rows, err = api.QueryContext(ctx, cmd, args...); err != nil {
@@ -174,10 +331,12 @@ func (api *MySqlAPI)GetPluginList(opt PluginListOpt)(infos []*PluginInfo, err er
 			lastRelease sql.NullTime
 			ghLastSync sql.NullTime
 			downloads sql.NullInt64
+			localDownloads sql.NullInt64
+			key pluginListKey
 		)
 		if err = rows.Scan(&info.Id, &info.Name, &info.Version, &authors, &info.Desc, &info.Desc_zhCN, &info.CreateAt, &lastRelease,
 			&info.Labels.Information, &info.Labels.Tool, &info.Labels.Management, &info.Labels.Api,
-			&info.GithubSync, &ghLastSync, &downloads); err != nil {
+			&info.GithubSync, &ghLastSync, &downloads, &localDownloads, &key.sort); err != nil {
 			return
 		}
 		info.Authors = strings.Split(authors, ",")
@@ -192,7 +351,16 @@ func (api *MySqlAPI)GetPluginList(opt PluginListOpt)(infos []*PluginInfo, err er
 		if downloads.Valid {
 			info.Downloads = downloads.Int64
 		}
-		infos = append(infos, &info)
+		if localDownloads.Valid {
+			info.LocalDownloads = localDownloads.Int64
+		}
+		key.id = info.Id
+		if aggregate {
+			key.sort = info.Downloads
+		}
+		if err = fn(&info, key); err != nil {
+			return
+		}
 	}
 	if err = rows.Err(); err != nil {
 		return
@@ -241,7 +409,8 @@ func (api *MySqlAPI)GetPluginInfos(id string)(infos []*PluginInfo, err error){
 		"`label_information`,`label_tool`,`label_management`,`label_api`," +
 		"`github_sync`,`ghRepoOwner`,`ghRepoName`," +
 		"CONVERT_TZ(`last_sync`,@@session.time_zone,'+00:00') AS `utc_last_sync`," +
-		"SUM(b.`downloads`) AS `downloads`" +
+		"IFNULL(SUM(b.`downloads`),0)+IFNULL(" + localDownloadsSubCmd + ",0) AS `downloads`," +
+		localDownloadsSubCmd + " AS `localDownloads`" +
 		" FROM plugins as a LEFT JOIN plugin_releases as b" +
 		" ON a.`id`=b.`id` WHERE a.`id`=? AND a.`enabled`=TRUE" +
 		" GROUP BY a.`id`"
@@ -262,7 +431,8 @@ func (api *MySqlAPI)GetPluginInfo(id string, version string)(info *PluginInfo, e
 		"`label_information`,`label_tool`,`label_management`,`label_api`," +
 		"`github_sync`,`ghRepoOwner`,`ghRepoName`," +
 		"CONVERT_TZ(`last_sync`,@@session.time_zone,'+00:00') AS `utc_last_sync`," +
-		"SUM(b.`downloads`) AS `downloads`" +
+		"IFNULL(SUM(b.`downloads`),0)+IFNULL(" + localDownloadsSubCmd + ",0) AS `downloads`," +
+		localDownloadsSubCmd + " AS `localDownloads`" +
 		" FROM plugins as a LEFT JOIN plugin_releases as b" +
 		" ON a.`id`=b.`id` WHERE a.`id`=? AND a.`enabled`=TRUE" +
 		" GROUP BY a.`id`"
@@ -285,13 +455,14 @@ func (api *MySqlAPI)GetPluginInfo(id string, version string)(info *PluginInfo, e
 		lastRelease sql.NullTime
 		ghLastSync sql.NullTime
 		downloads sql.NullInt64
+		localDownloads sql.NullInt64
 	)
 	info = new(PluginInfo)
 	if err = api.DB.QueryRowContext(ctx, queryCmd, id).
 		Scan(&info.Name, &info.Version, &authors, &info.Desc, &info.Desc_zhCN, &info.CreateAt, &lastRelease,
 		&info.Repo, &info.RepoBranch, &info.RepoSubdir, &info.Link,
 		&info.Labels.Information, &info.Labels.Tool, &info.Labels.Management, &info.Labels.Api,
-		&info.GithubSync, &info.GhRepoOwner, &info.GhRepoName, &ghLastSync, &downloads); err != nil {
+		&info.GithubSync, &info.GhRepoOwner, &info.GhRepoName, &ghLastSync, &downloads, &localDownloads); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -309,6 +480,9 @@ func (api *MySqlAPI)GetPluginInfo(id string, version string)(info *PluginInfo, e
 	if downloads.Valid {
 		info.Downloads = downloads.Int64
 	}
+	if localDownloads.Valid {
+		info.LocalDownloads = localDownloads.Int64
+	}
 	info.Authors = strings.Split(authors, ",")
 	info.Dependencies = make(DependMap, 3)
 	info.Requirements = make(RequireMap, 3)
@@ -360,13 +534,23 @@ func (api *MySqlAPI)GetPluginReadme(id string)(content Content, err error){
 		return
 	}
 	if !info.GithubSync {
-		filename := filepath.Join(PLUGIN_DIR, id, "README.MD")
-		var stat os.FileInfo
-		if stat, err = os.Stat(filename); err != nil {
+		key := path.Join(id, "README.MD")
+		var blob BlobInfo
+		if blob, err = api.PluginStore.Stat(key); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				err = ErrNotFound
+			}
 			return
 		}
-		content.ModTime = stat.ModTime().Format("2006-01-02 15:04:05.000")
-		content.Data = func()([]byte, error){ return os.ReadFile(filename) }
+		content.ModTime = blob.ModTime.UTC().Format(http.TimeFormat)
+		content.Data = func()(data []byte, err error){
+			var rc io.ReadSeekCloser
+			if rc, _, err = api.PluginStore.Open(key); err != nil {
+				return
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
 		return
 	}
 	// prefixs only exists when fetching readme from github
@@ -439,11 +623,16 @@ func (api *MySqlAPI)GetPluginReadme(id string)(content Content, err error){
 	return
 }
 
+// enabledReleasesCmd selects the enabled releases of the enabled plugins as `r`
+const enabledReleasesCmd = " FROM plugin_releases AS r JOIN plugins AS a ON a.`id`=r.`id`" +
+	" WHERE r.`enabled`=TRUE AND a.`enabled`=TRUE"
+
 func (api *MySqlAPI)GetPluginReleases(id string)(releases []*PluginRelease, err error){
-	const queryCmd = "SELECT `tag`,`enabled`,`stable`,`size`," +
-		"CONVERT_TZ(`uploaded`,@@session.time_zone,'+00:00') AS `utc_uploaded`," +
-		"`filename`,`downloads`,`github_url`" +
-		" FROM plugin_releases WHERE `id`=?"
+	const queryCmd = "SELECT r.`tag`,r.`name`,r.`enabled`,r.`stable`,r.`size`," +
+		"CONVERT_TZ(r.`uploaded`,@@session.time_zone,'+00:00') AS `utc_uploaded`," +
+		"r.`filename`,r.`downloads`," + releaseLocalDownloadsSubCmd + " AS `localDownloads`,r.`github_url`," +
+		"r.`sha256`,r.`sha1`,r.`problems`" +
+		enabledReleasesCmd + " AND r.`id`=?"
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
 	defer cancel()
@@ -457,13 +646,23 @@ func (api *MySqlAPI)GetPluginReleases(id string)(releases []*PluginRelease, err
 	for rows.Next() {
 		var (
 			release PluginRelease
+			localDownloads sql.NullInt64
 			ghUrl sql.NullString
+			sha256, sha1 sql.NullString
+			problems sql.NullString
 		)
-		if err = rows.Scan(&release.Tag, &release.Enabled, &release.Stable, &release.Size,
-			&release.Uploaded, &release.FileName, &release.Downloads, &ghUrl); err != nil {
+		if err = rows.Scan(&release.Tag, &release.Name, &release.Enabled, &release.Stable, &release.Size,
+			&release.Uploaded, &release.FileName, &release.Downloads, &localDownloads, &ghUrl,
+			&sha256, &sha1, &problems); err != nil {
 			return
 		}
+		release.Problems = parseProblems(problems)
+		release.Sha256, release.Sha1 = sha256.String, sha1.String
 		release.Id = id
+		if localDownloads.Valid {
+			release.LocalDownloads = (int)(localDownloads.Int64)
+			release.Downloads += release.LocalDownloads
+		}
 		if ghUrl.Valid {
 			release.GithubUrl = ghUrl.String
 		}
@@ -472,15 +671,35 @@ func (api *MySqlAPI)GetPluginReleases(id string)(releases []*PluginRelease, err
 	if err = rows.Err(); err != nil {
 		return
 	}
+	var assets map[string][]*ReleaseAsset
+	if assets, err = api.queryReleaseAssets(ctx, id, nil); err != nil {
+		return
+	}
+	for _, r := range releases {
+		setReleaseAssets(r, assets[r.Tag.String()])
+	}
 	sort.Slice(releases, func(i, j int)(bool){ return !releases[i].Tag.Less(releases[j].Tag) })
 	return
 }
 
+// GetPluginRelease returns the release if both the release and the plugin are enabled,
+// so the assets, the metadata and the files of the release are hidden along with them
 func (api *MySqlAPI)GetPluginRelease(id string, tag Version)(release *PluginRelease, err error){
-	const queryCmd = "SELECT `enabled`,`stable`,`size`," +
-		"CONVERT_TZ(`uploaded`,@@session.time_zone,'+00:00') AS `utc_uploaded`," +
-		"`filename`,`downloads`,`github_url`" +
-		" FROM plugin_releases WHERE `id`=? AND `tag`=?"
+	return api.queryRelease(id, tag, true)
+}
+
+// queryRelease returns the release, the disabled ones are included if enabledOnly is false
+func (api *MySqlAPI)queryRelease(id string, tag Version, enabledOnly bool)(release *PluginRelease, err error){
+	queryCmd := "SELECT r.`name`,r.`enabled`,r.`stable`,r.`size`," +
+		"CONVERT_TZ(r.`uploaded`,@@session.time_zone,'+00:00') AS `utc_uploaded`," +
+		"r.`filename`,r.`downloads`," + releaseLocalDownloadsSubCmd + " AS `localDownloads`,r.`github_url`," +
+		"r.`sha256`,r.`sha1`,r.`problems`"
+	if enabledOnly {
+		queryCmd += enabledReleasesCmd + " AND"
+	}else{
+		queryCmd += " FROM plugin_releases AS r WHERE"
+	}
+	queryCmd += " r.`id`=? AND r.`tag`=?"
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
 	defer cancel()
@@ -488,11 +707,14 @@ func (api *MySqlAPI)GetPluginRelease(id string, tag Version)(release *PluginRele
 	release = new(PluginRelease)
 	var (
 		downloads sql.NullInt64
+		localDownloads sql.NullInt64
 		ghUrl sql.NullString
+		sha256, sha1 sql.NullString
+		problems sql.NullString
 	)
 	if err = api.DB.QueryRowContext(ctx, queryCmd, id, tag).
-		Scan(&release.Enabled, &release.Stable, &release.Size, &release.Uploaded,
-			&release.FileName, &downloads, &ghUrl); err != nil {
+		Scan(&release.Name, &release.Enabled, &release.Stable, &release.Size, &release.Uploaded,
+			&release.FileName, &downloads, &localDownloads, &ghUrl, &sha256, &sha1, &problems); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -500,57 +722,764 @@ func (api *MySqlAPI)GetPluginRelease(id string, tag Version)(release *PluginRele
 	}
 	release.Id = id
 	release.Tag = tag
+	release.Sha256, release.Sha1 = sha256.String, sha1.String
+	release.Problems = parseProblems(problems)
 	if downloads.Valid {
 		release.Downloads = (int)(downloads.Int64)
 	}
+	if localDownloads.Valid {
+		release.LocalDownloads = (int)(localDownloads.Int64)
+		release.Downloads += release.LocalDownloads
+	}
 	if ghUrl.Valid {
 		release.GithubUrl = ghUrl.String
 	}
+	var assets map[string][]*ReleaseAsset
+	if assets, err = api.queryReleaseAssets(ctx, id, &tag); err != nil {
+		return
+	}
+	setReleaseAssets(release, assets[tag.String()])
 	return
 }
 
-func (api *MySqlAPI)GetPluginReleaseAsset(id string, tag Version, filename string)(rc io.ReadSeekCloser, modTime time.Time, err error){
-	filenam := filepath.Join(PLUGIN_DIR, id, "release", tag.String(), filepath.Clean(filename))
-	var fd *os.File
-	if fd, err = os.Open(filenam); err == nil {
-		if stat, err := fd.Stat(); err == nil {
-			modTime = stat.ModTime()
+// queryReleaseAssets returns the assets of the plugin keyed by the release tag, if tag is not nil only the release's assets are returned
+func (api *MySqlAPI)queryReleaseAssets(ctx context.Context, id string, tag *Version)(assets map[string][]*ReleaseAsset, err error){
+	queryCmd := "SELECT `tag`,`name`,`size`,`content_type`," +
+		"CONVERT_TZ(`uploaded`,@@session.time_zone,'+00:00') AS `utc_uploaded`," +
+		"`downloads`,`local_downloads`,`github_url`,`sha256`,`sha1`" +
+		" FROM plugin_release_assets WHERE `id`=?"
+	args := []any{id}
+	if tag != nil {
+		queryCmd += " AND `tag`=?"
+		args = append(args, *tag)
+	}
+
+	var rows *sql.Rows
+	if rows, err = api.DB.QueryContext(ctx, queryCmd, args...); err != nil {
+		return
+	}
+	defer rows.Close()
+	assets = make(map[string][]*ReleaseAsset)
+	for rows.Next() {
+		var (
+			tag Version
+			asset ReleaseAsset
+			ghUrl sql.NullString
+			sha256, sha1 sql.NullString
+		)
+		if err = rows.Scan(&tag, &asset.Name, &asset.Size, &asset.ContentType, &asset.Uploaded,
+			&asset.Downloads, &asset.LocalDownloads, &ghUrl, &sha256, &sha1); err != nil {
+			return
 		}
-		rc = fd
+		asset.Downloads += asset.LocalDownloads
+		asset.GithubUrl = ghUrl.String
+		asset.Sha256, asset.Sha1 = sha256.String, sha1.String
+		k := tag.String()
+		assets[k] = append(assets[k], &asset)
+	}
+	if err = rows.Err(); err != nil {
 		return
 	}
+	return
+}
+
+// setReleaseAssets sets the asset list of the release.
+// The main file is always the first one, and its stats and checksums are taken from the release,
+// so the releases that synced before the asset table exists are still complete
+func setReleaseAssets(release *PluginRelease, assets []*ReleaseAsset){
+	main := &ReleaseAsset{
+		Name: release.FileName,
+		Size: release.Size,
+		ContentType: AssetContentType(release.FileName),
+		Uploaded: release.Uploaded,
+		Downloads: release.Downloads,
+		LocalDownloads: release.LocalDownloads,
+		GithubUrl: release.GithubUrl,
+		Checksums: release.Checksums,
+	}
+	release.Assets = make([]*ReleaseAsset, 1, len(assets) + 1)
+	release.Assets[0] = main
+	for _, a := range assets {
+		if a.Name == main.Name {
+			if len(a.ContentType) > 0 {
+				main.ContentType = a.ContentType
+			}
+			continue
+		}
+		release.Assets = append(release.Assets, a)
+	}
+	sort.Slice(release.Assets[1:], func(i, j int)(bool){ return release.Assets[i + 1].Name < release.Assets[j + 1].Name })
+}
+
+func (api *MySqlAPI)updateReleaseChecksums(id string, tag Version, sums Checksums)(err error){
+	const updateCmd = "UPDATE plugin_releases SET `sha256`=?,`sha1`=? WHERE `id`=? AND `tag`=?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	if _, err = api.DB.ExecContext(ctx, updateCmd, sums.Sha256, sums.Sha1, id, tag); err != nil {
+		return
+	}
+	return
+}
+
+// updateAssetChecksums records the checksums of the asset,
+// the checksums of the main file are recorded in the release
+func (api *MySqlAPI)updateAssetChecksums(release *PluginRelease, asset *ReleaseAsset, sums Checksums)(err error){
+	const updateCmd = "UPDATE plugin_release_assets SET `sha256`=?,`sha1`=? WHERE `id`=? AND `tag`=? AND `name`=?"
+
+	asset.Checksums = sums
+	if asset.Name == release.FileName {
+		release.Checksums = sums
+		return api.updateReleaseChecksums(release.Id, release.Tag, sums)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	if _, err = api.DB.ExecContext(ctx, updateCmd, sums.Sha256, sums.Sha1, release.Id, release.Tag, asset.Name); err != nil {
+		return
+	}
+	return
+}
+
+// verifyAsset checks the asset file with the checksums in the database.
+// If the checksums are not recorded yet, they will be recorded.
+// The file will be seeked to the start after checked
+func (api *MySqlAPI)verifyAsset(release *PluginRelease, asset *ReleaseAsset, fd io.ReadSeeker)(err error){
+	var sums Checksums
+	if sums, err = VerifyChecksums(asset.Checksums, fd); err != nil {
+		return
+	}
+	if asset.Checksums.IsZero() {
+		if err := api.updateAssetChecksums(release, asset, sums); err != nil {
+			loger.Warnf("Cannot record checksums for %s(v%s):%s: %v", release.Id, release.Tag, asset.Name, err)
+		}
+	}
+	return
+}
+
+func (api *MySqlAPI)GetPluginReleaseAsset(id string, tag Version, filename string)(rc io.ReadSeekCloser, modTime time.Time, err error){
 	var release *PluginRelease
 	if release, err = api.GetPluginRelease(id, tag); err != nil {
 		return
 	}
+	asset := release.GetAsset(filename)
+	key := assetKey(id, tag, filename)
+	var info BlobInfo
 	if len(release.GithubUrl) == 0 {
+		// the assets of the plugins that not synced from github are stored in the plugin store, and they will never be evicted
+		if rc, info, err = api.PluginStore.Open(key); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				err = ErrNotFound
+			}
+			return
+		}
+		if asset != nil {
+			if err = api.verifyAsset(release, asset, rc); err != nil {
+				rc.Close()
+				return nil, modTime, err
+			}
+		}
+		return rc, info.ModTime, nil
+	}
+	if asset == nil || len(asset.GithubUrl) == 0 {
 		err = ErrNotFound
 		return
 	}
+	if rc, modTime, err = api.joinFetch(key); rc != nil || err != nil {
+		return
+	}
+	if rc, info, err = api.AssetCache.Open(key); err == nil {
+		if api.AssetCache.Verified(key) {
+			return rc, info.ModTime, nil
+		}
+		if err = api.verifyAsset(release, asset, rc); err == nil {
+			api.AssetCache.SetVerified(key)
+			return rc, info.ModTime, nil
+		}
+		rc.Close()
+		rc = nil
+		if err != ErrChecksumMismatch {
+			return
+		}
+		loger.Warnf("Cached asset %q does not match the checksums, removing it", key)
+		if err = api.AssetCache.Remove(key); err != nil {
+			return
+		}
+	}
+	return api.fetchAsset(release, asset, key)
+}
+
+// assetKey returns the blob key of the release asset, it's same in both the plugin store and the cache
+func assetKey(id string, tag Version, filename string)(string){
+	return path.Join(id, "release", tag.String(), filename)
+}
+
+// assetFetchTimeout is the max duration of downloading an asset from github
+const assetFetchTimeout = time.Minute * 30
+
+// joinFetch returns a reader of the asset if it's downloading, or nil if not
+func (api *MySqlAPI)joinFetch(key string)(rc io.ReadSeekCloser, modTime time.Time, err error){
+	api.fetchMux.Lock()
+	sf, ok := api.fetching[key]
+	var r *StreamReader
+	if ok {
+		r = sf.NewReader()
+	}
+	api.fetchMux.Unlock()
+	if !ok {
+		return
+	}
+	loger.Debugf("Joined the download of %q", key)
+	if err = sf.WaitStart(); err != nil {
+		r.Close()
+		return
+	}
+	return r, time.Now(), nil
+}
+
+// fetchAsset starts downloading the asset from github into the cache, or joins the download if it's already started.
+// The returned reader streams the data while it's downloading, it will block when reading the part that not downloaded yet
+func (api *MySqlAPI)fetchAsset(release *PluginRelease, asset *ReleaseAsset, key string)(rc io.ReadSeekCloser, modTime time.Time, err error){
+	api.fetchMux.Lock()
+	sf, ok := api.fetching[key]
+	if !ok {
+		sf = NewStreamFile()
+		api.fetching[key] = sf
+		go api.downloadAsset(release, asset, key, sf)
+	}
+	r := sf.NewReader()
+	api.fetchMux.Unlock()
+	if err = sf.WaitStart(); err != nil {
+		r.Close()
+		return
+	}
+	return r, time.Now(), nil
+}
+
+func (api *MySqlAPI)downloadAsset(release *PluginRelease, asset *ReleaseAsset, key string, sf *StreamFile){
+	var err error
+	finished := false
+	finish := func(){
+		if !finished {
+			finished = true
+			sf.Finish(err)
+		}
+	}
+	unregister := func(){
+		api.fetchMux.Lock()
+		// another download may be started after this one is unregistered
+		if api.fetching[key] == sf {
+			delete(api.fetching, key)
+		}
+		api.fetchMux.Unlock()
+	}
+	defer func(){
+		unregister()
+		finish()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), assetFetchTimeout)
+	defer cancel()
+
 	var resp *http.Response
-	loger.Debugf("Downloading %q", release.GithubUrl)
-	if resp, err = api.GithubCli.Get(release.GithubUrl); err != nil {
+	loger.Debugf("Downloading %q", asset.GithubUrl)
+	if resp, err = api.GithubCli.Download(ctx, asset.GithubUrl); err != nil {
 		return
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		err = &StatusCodeErr{resp.StatusCode}
+	size := resp.ContentLength
+	if size < 0 && asset.Size > 0 {
+		size = asset.Size
+	}
+	var w *CacheWriter
+	if w, err = api.AssetCache.Create(key, size); err != nil {
+		return
+	}
+	var rfd *os.File
+	if rfd, err = os.Open(w.Name()); err != nil {
+		w.Abort()
+		return
+	}
+	sf.Start(rfd, size)
+	// keeps rfd opened for the inspection after the stream is finished
+	own := sf.NewReader()
+	defer own.Close()
+	sumw := NewChecksumWriter()
+	// the StreamFile must be notified after the data is written to the file
+	var n int64
+	if n, err = io.Copy(io.MultiWriter(w, sumw, sf), resp.Body); err == nil && size >= 0 && n != size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		loger.Warnf("Cannot download %q: %v", asset.GithubUrl, err)
+		w.Abort()
 		return
 	}
-	var data []byte
-	if data, err = io.ReadAll(resp.Body); err != nil {
+	sums := sumw.Sums()
+	// the recorded checksums are cleared by the sync when the asset is re-uploaded,
+	// so a mismatch means the downloaded data is broken or tampered, the readers get the error instead of EOF
+	if !asset.Checksums.IsZero() && !asset.Checksums.Equal(sums) {
+		err = ErrChecksumMismatch
+		loger.Errorf("Downloaded %s(v%s):%s does not match the checksums, expect sha256 %s, got %s",
+			release.Id, release.Tag, asset.Name, asset.Sha256, sums.Sha256)
+		w.Abort()
 		return
 	}
-	if err := os.MkdirAll(filepath.Dir(filenam), 0755); err == nil {
-		if err = os.WriteFile(filenam, data, 0444); err != nil {
-			loger.Warnf("Cannot write to asset file %q: %v", filenam, err)
+	// the readers don't need to wait for the bookkeeping below
+	finish()
+	if err := w.Commit(true); err != nil {
+		if err == ErrCacheTooLarge {
+			loger.Warnf("Asset %q is larger than the cache budget, it will not be cached", key)
 		}else{
-			loger.Infof("Cached %s(v%s):%s at %q: %v", id, tag, filename, filenam, err)
+			loger.Warnf("Cannot cache asset %q: %v", key, err)
 		}
 	}else{
-		loger.Warnf("Cannot make asset dir %q: %v", filepath.Dir(filenam), err)
+		loger.Infof("Cached %s(v%s):%s", release.Id, release.Tag, asset.Name)
+	}
+	// the later requests can open the committed file now
+	unregister()
+
+	if asset.Checksums.IsZero() {
+		if err := api.updateAssetChecksums(release, asset, sums); err != nil {
+			loger.Warnf("Cannot record checksums for %s(v%s):%s: %v", release.Id, release.Tag, asset.Name, err)
+		}
+	}
+	if asset.Name != release.FileName {
+		return
+	}
+	if _, err := api.inspectAsset(release, rfd, n); err != nil && err != ErrNotMcdrPackage {
+		loger.Warnf("Cannot inspect %s(v%s):%s: %v", release.Id, release.Tag, asset.Name, err)
+	}
+}
+
+// parseProblems parses the JSON encoded problem list
+func parseProblems(s sql.NullString)(problems []string){
+	if s.Valid && len(s.String) > 0 {
+		json.Unmarshal(([]byte)(s.String), &problems)
 	}
-	rc = NopReadSeeker{bytes.NewReader(data)}
+	return
+}
+
+// inspectAsset reads the .mcdr package and records the metadata and the problems of the release.
+// If the asset is not a .mcdr package, an empty metadata will be recorded and ErrNotMcdrPackage will be returned
+func (api *MySqlAPI)inspectAsset(release *PluginRelease, r io.ReaderAt, size int64)(info *McdrInfo, err error){
+	const updateCmd = "UPDATE plugin_releases SET `mcdr_meta`=?,`problems`=? WHERE `id`=? AND `tag`=?"
+
+	var metaData, problemsData []byte
+	if info, err = ReadMcdr(r, size); err == nil {
+		info.Problems = append([]string{}, info.Check(release.Id, release.Tag)...)
+		if len(info.Problems) > 0 {
+			loger.Warnf("Release %s(v%s) has problems: %s", release.Id, release.Tag, strings.Join(info.Problems, "; "))
+		}
+		if metaData, err = json.Marshal(info); err != nil {
+			return
+		}
+		if problemsData, err = json.Marshal(info.Problems); err != nil {
+			return
+		}
+	}else if err != ErrNotMcdrPackage {
+		return
+	}
+	release.Problems = info.GetProblems()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	if _, e := api.DB.ExecContext(ctx, updateCmd, (string)(metaData), (string)(problemsData), release.Id, release.Tag); e != nil {
+		loger.Warnf("Cannot record the metadata of %s(v%s): %v", release.Id, release.Tag, e)
+	}
+	return
+}
+
+func (api *MySqlAPI)GetPluginReleaseMeta(id string, tag Version)(info *McdrInfo, err error){
+	const queryCmd = "SELECT `mcdr_meta` FROM plugin_releases WHERE `id`=? AND `tag`=?"
+
+	var release *PluginRelease
+	if release, err = api.GetPluginRelease(id, tag); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var metaData sql.NullString
+	if err = api.DB.QueryRowContext(ctx, queryCmd, id, tag).Scan(&metaData); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return
+	}
+	if metaData.Valid {
+		if len(metaData.String) == 0 {
+			return nil, ErrNotMcdrPackage
+		}
+		info = new(McdrInfo)
+		if err = json.Unmarshal(([]byte)(metaData.String), info); err != nil {
+			return
+		}
+		return
+	}
+	// the asset is not inspected yet
+	var (
+		r io.ReaderAt
+		size int64
+		closer io.Closer
+	)
+	if r, size, closer, err = api.openReleasePackage(release); err != nil {
+		return
+	}
+	defer closer.Close()
+	return api.inspectAsset(release, r, size)
+}
+
+// openReleasePackage opens the asset of the release for random reads, which should not be larger than McdrMaxSize.
+// The closer must be closed after used
+func (api *MySqlAPI)openReleasePackage(release *PluginRelease)(r io.ReaderAt, size int64, closer io.Closer, err error){
+	var rc io.ReadSeekCloser
+	if rc, _, err = api.GetPluginReleaseAsset(release.Id, release.Tag, release.FileName); err != nil {
+		return
+	}
+	if r, size, err = NewReaderAt(rc); err != nil {
+		rc.Close()
+		return
+	}
+	if size > McdrMaxSize {
+		rc.Close()
+		return nil, 0, nil, ErrMcdrTooLarge
+	}
+	return r, size, rc, nil
+}
+
+func (api *MySqlAPI)GetPluginReleaseFile(id string, tag Version, name string)(data []byte, file *McdrFile, err error){
+	var release *PluginRelease
+	if release, err = api.GetPluginRelease(id, tag); err != nil {
+		return
+	}
+	var (
+		r io.ReaderAt
+		size int64
+		closer io.Closer
+	)
+	if r, size, closer, err = api.openReleasePackage(release); err != nil {
+		return
+	}
+	defer closer.Close()
+	return ReadMcdrFile(r, size, name)
+}
+
+// PrefetchLatestAssets downloads the asset of the latest release of every plugin into the cache
+func (api *MySqlAPI)PrefetchLatestAssets(ctx context.Context)(err error){
+	var ids []string
+	if ids, err = api.GetPluginIdList(PluginListOpt{}); err != nil {
+		return
+	}
+	loger.Infof("Prefetching the latest assets of %d plugins", len(ids))
+	count := 0
+	for _, id := range ids {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		releases, err := api.GetPluginReleases(id)
+		if err != nil {
+			loger.Warnf("Cannot get releases of %s: %v", id, err)
+			continue
+		}
+		var latest *PluginRelease
+		for _, r := range releases {
+			if r.Enabled && len(r.GithubUrl) > 0 && len(r.Problems) == 0 {
+				latest = r
+				break
+			}
+		}
+		if latest == nil {
+			continue
+		}
+		rc, _, err := api.GetPluginReleaseAsset(id, latest.Tag, latest.FileName)
+		if err != nil {
+			loger.Warnf("Cannot prefetch %s(v%s):%s: %v", id, latest.Tag, latest.FileName, err)
+			continue
+		}
+		rc.Close()
+		count++
+	}
+	loger.Infof("Prefetched %d assets, cache size %d bytes", count, api.AssetCache.Size())
+	return nil
+}
+
+// PrefetchLoop prefetches the latest assets immediately and then every interval, until the context is done
+func (api *MySqlAPI)PrefetchLoop(ctx context.Context, interval time.Duration){
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := api.PrefetchLatestAssets(ctx); err != nil {
+			loger.Errorf("Cannot prefetch assets: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MigrateLegacyAssets moves the assets of the github synced plugins, which were cached in PLUGIN_DIR by the old versions,
+// into the asset cache, so they are counted in the budget and can be evicted.
+// It's safe to run on every start, nothing will be done after the files are moved
+func (api *MySqlAPI)MigrateLegacyAssets(ctx context.Context){
+	if err := api.migrateLegacyAssets(ctx); err != nil {
+		loger.Errorf("Cannot migrate the legacy cached assets: %v", err)
+	}
+}
+
+func (api *MySqlAPI)migrateLegacyAssets(ctx context.Context)(err error){
+	var blobs []BlobInfo
+	if blobs, err = api.PluginStore.List(""); err != nil {
+		return
+	}
+	synced := make(map[string]bool)
+	count := 0
+	for _, b := range blobs {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		// the keys of the assets are `{id}/release/{tag}/{filename}`
+		parts := strings.SplitN(b.Key, "/", 3)
+		if len(parts) != 3 || parts[1] != "release" {
+			continue
+		}
+		id := parts[0]
+		ghSync, ok := synced[id]
+		if !ok {
+			qctx, cancel := context.WithTimeout(ctx, time.Second * 5)
+			err := api.checkEditable(qctx, id)
+			cancel()
+			if err != nil && err != ErrGithubSynced && err != ErrNotFound {
+				return err
+			}
+			ghSync = err == ErrGithubSynced
+			synced[id] = ghSync
+		}
+		if !ghSync {
+			continue
+		}
+		if err := api.moveLegacyAsset(b.Key); err != nil {
+			loger.Warnf("Cannot move the legacy cached asset %q: %v", b.Key, err)
+			continue
+		}
+		count++
+	}
+	if count > 0 {
+		loger.Infof("Moved %d legacy cached assets from the plugin store to the asset cache", count)
+	}
+	return nil
+}
+
+func (api *MySqlAPI)moveLegacyAsset(key string)(err error){
+	rc, _, err := api.PluginStore.Open(key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// moved by another instance
+			return nil
+		}
+		return
+	}
+	err = api.AssetCache.Put(key, rc)
+	rc.Close()
+	// the asset will be downloaded again if it doesn't fit the cache
+	if err != nil && err != ErrCacheTooLarge {
+		return
+	}
+	return api.PluginStore.Remove(key)
+}
+
+type releaseChangelog struct {
+	Tag       Version
+	Name      string
+	Uploaded  time.Time
+	Changelog string
+}
+
+// queryChangelogs returns the changelogs of the releases which between from and to (inclusive),
+// sorted by decreasing version. Nil from or to means there is no limit
+func (api *MySqlAPI)queryChangelogs(id string, from, to *Version)(logs []*releaseChangelog, content Content, err error){
+	const queryCmd = "SELECT a.`repo`,a.`repo_branch`,b.`tag`,b.`name`," +
+		"CONVERT_TZ(b.`uploaded`,@@session.time_zone,'+00:00') AS `utc_uploaded`," +
+		"b.`changelog`" +
+		" FROM plugins AS a LEFT JOIN plugin_releases AS b" +
+		" ON a.`id`=b.`id` AND b.`enabled`=TRUE WHERE a.`id`=? AND a.`enabled`=TRUE"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var rows *sql.Rows
+	if rows, err = api.QueryContext(ctx, queryCmd, id); err != nil {
+		return
+	}
+	defer rows.Close()
+	found := false
+	var repo, branch string
+	for rows.Next() {
+		var (
+			tag, name, changelog sql.NullString
+			uploaded sql.NullTime
+		)
+		if err = rows.Scan(&repo, &branch, &tag, &name, &uploaded, &changelog); err != nil {
+			return
+		}
+		found = true
+		if !tag.Valid {
+			continue
+		}
+		log := &releaseChangelog{
+			Name: name.String,
+			Uploaded: uploaded.Time,
+			Changelog: changelog.String,
+		}
+		if log.Tag, err = VersionFromString(tag.String); err != nil {
+			return
+		}
+		if (from != nil && log.Tag.Less(*from)) || (to != nil && to.Less(log.Tag)) {
+			continue
+		}
+		logs = append(logs, log)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if !found {
+		err = ErrNotFound
+		return
+	}
+	sort.Slice(logs, func(i, j int)(bool){ return !logs[i].Tag.Less(logs[j].Tag) })
+	if len(repo) > 0 {
+		content.URLPrefix, _ = url.JoinPath(repo, "tree", branch)
+		content.DataURLPrefix, _ = url.JoinPath(repo, "raw", branch)
+	}
+	return
+}
+
+func (api *MySqlAPI)GetPluginReleaseChangelog(id string, tag Version)(content Content, err error){
+	var logs []*releaseChangelog
+	if logs, content, err = api.queryChangelogs(id, &tag, &tag); err != nil {
+		return
+	}
+	if len(logs) == 0 {
+		err = ErrNotFound
+		return
+	}
+	data := ([]byte)(logs[0].Changelog)
+	content.Data = func()([]byte, error){ return data, nil }
+	return
+}
+
+func (api *MySqlAPI)GetPluginChangelog(id string, from, to *Version)(content Content, err error){
+	var logs []*releaseChangelog
+	if logs, content, err = api.queryChangelogs(id, from, to); err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	for _, log := range logs {
+		tag := log.Tag.String()
+		buf.WriteString("## ")
+		buf.WriteString(tag)
+		if name := strings.TrimSpace(log.Name); len(name) > 0 && name != tag && name != "v" + tag {
+			buf.WriteString(" - ")
+			buf.WriteString(name)
+		}
+		buf.WriteString("\n\n")
+		if !log.Uploaded.IsZero() {
+			buf.WriteString("_" + log.Uploaded.Format("2006-01-02") + "_\n\n")
+		}
+		if changelog := strings.TrimSpace(log.Changelog); len(changelog) > 0 {
+			buf.WriteString(changelog)
+			buf.WriteString("\n\n")
+		}
+	}
+	data := buf.Bytes()
+	content.Data = func()([]byte, error){ return data, nil }
+	return
+}
+
+// RecordPluginDownload records a download of the main file or an extra asset of the release in the daily series.
+// Only the downloads of the main file are counted in the release stats,
+// the ones of the extra assets are also counted in the local downloads of the asset
+func (api *MySqlAPI)RecordPluginDownload(id string, tag Version, filename string)(err error){
+	const insertCmd = "INSERT INTO plugin_downloads (`id`,`tag`,`filename`,`day`,`count`)" +
+		" SELECT ?,?,?,UTC_DATE(),1 FROM plugin_releases AS r WHERE r.`id`=? AND r.`tag`=?" +
+		" AND (r.`filename`=? OR EXISTS (SELECT 1 FROM plugin_release_assets AS s" +
+		" WHERE s.`id`=r.`id` AND s.`tag`=r.`tag` AND s.`name`=?))" +
+		" ON DUPLICATE KEY UPDATE `count`=`count`+1"
+	const updateAssetCmd = "UPDATE plugin_release_assets SET `local_downloads`=`local_downloads`+1" +
+		" WHERE `id`=? AND `tag`=? AND `name`=?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	loger.Debugf("Exec sql cmd: %s\n  args: [%v %v %v]", insertCmd, id, tag, filename)
+	if _, err = api.DB.ExecContext(ctx, insertCmd, id, tag, filename, id, tag, filename, filename); err != nil {
+		return
+	}
+	if _, err = api.DB.ExecContext(ctx, updateAssetCmd, id, tag, filename); err != nil {
+		return
+	}
+	return
+}
+
+func (api *MySqlAPI)GetPluginDownloadStats(id string, opt DownloadStatsOpt)(stats []*DownloadStat, err error){
+	const queryExistsCmd = "SELECT 1 FROM plugins WHERE `id`=? AND `enabled`=TRUE"
+	const queryCmd = "SELECT `day`,SUM(`count`) AS `count`" +
+		" FROM plugin_downloads WHERE `id`=? AND `day`>=? AND `day`<=?"
+
+	if opt.Granularity == "" {
+		opt.Granularity = GranularityDay
+	}
+	if err = CheckGranularity(opt.Granularity); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var exists int
+	if err = api.DB.QueryRowContext(ctx, queryExistsCmd, id).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return
+	}
+
+	from := BucketStart(opt.From, opt.Granularity)
+	cmd := queryCmd
+	args := []any{id, from.Format("2006-01-02"), opt.To.UTC().Format("2006-01-02")}
+	if opt.Tag != nil {
+		cmd += " AND `tag`=?"
+		args = append(args, *opt.Tag)
+	}
+	if len(opt.Filename) > 0 {
+		cmd += " AND `filename`=?"
+		args = append(args, opt.Filename)
+	}
+	cmd += " GROUP BY `day` ORDER BY `day`"
+
+	var rows *sql.Rows
+	if rows, err = api.QueryContext(ctx, cmd, args...); err != nil {
+		return
+	}
+	defer rows.Close()
+	var daily []*DownloadStat
+	for rows.Next() {
+		var stat DownloadStat
+		if err = rows.Scan(&stat.Time, &stat.Count); err != nil {
+			return
+		}
+		daily = append(daily, &stat)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	stats = BucketDownloadStats(daily, from, opt.To, opt.Granularity)
 	return
 }
 
@@ -684,10 +1613,34 @@ func (opt pluginListOpt)appendOrderBy(cmd string, args []any)(string, []any){
 		if !rev {
 			cmd += " DESC"
 		}
+	case "trending", "popular":
+		cmd += " ORDER BY a.`score_" + sortBy + "`"
+		if !opt.Reversed {
+			cmd += " DESC"
+		}
 	}
 	return cmd, args
 }
 
+// sortColumn returns the expression of the sort column and the direction, which matches appendOrderBy.
+// aggregate is true if the column is computed by GROUP BY, so it can only be filtered by HAVING
+func (opt pluginListOpt)sortColumn()(col string, desc bool, aggregate bool){
+	switch sortBy := strings.ToLower(opt.SortBy); sortBy {
+	case "id", "name", "authors":
+		return "a.`" + sortBy + "`", opt.Reversed, false
+	case "createat":
+		return "a.`createAt`", opt.Reversed, false
+	case "lastrelease":
+		// the plugins without release are sorted as the oldest, like NULL is
+		return "IFNULL(a.`lastRelease`,CAST('1000-01-01' AS DATETIME))", !opt.Reversed, false
+	case "downloads":
+		return "`downloads`", !opt.Reversed, true
+	case "trending", "popular":
+		return "a.`score_" + sortBy + "`", !opt.Reversed, false
+	}
+	return "", opt.Reversed, false
+}
+
 func (opt pluginListOpt)appendLimit(cmd string, args []any)(string, []any){
 	if opt.Limit > 0 || opt.Offset > 0 {
 		if opt.Offset < 0 {
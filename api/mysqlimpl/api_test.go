@@ -0,0 +1,122 @@
+package mysqlimpl
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/kmcsr/PluginWebPoint/api"
+)
+
+// fakePluginPages serves the pages of n plugins sorted by id, and counts the rows it has read
+type fakePluginPages struct{
+	ids []string
+	pages []pluginListPage
+	read int
+}
+
+func newFakePluginPages(n int)(*fakePluginPages){
+	f := new(fakePluginPages)
+	for i := 0; i < n; i++ {
+		f.ids = append(f.ids, fmt.Sprintf("plugin_%03d", i))
+	}
+	return f
+}
+
+func (f *fakePluginPages)query(page pluginListPage)(rows []pluginListRow, err error){
+	f.pages = append(f.pages, page)
+	i := 0
+	if page.after != nil {
+		for i < len(f.ids) && f.ids[i] <= page.after.id {
+			i++
+		}
+	}
+	i += page.offset
+	for ; i < len(f.ids) && len(rows) < page.limit; i++ {
+		rows = append(rows, pluginListRow{
+			info: &PluginInfo{ Id: f.ids[i] },
+			key: pluginListKey{ id: f.ids[i] },
+		})
+	}
+	f.read += len(rows)
+	return
+}
+
+func TestStreamPluginPages(t *testing.T){
+	f := newFakePluginPages(95)
+	var got []string
+	err := streamPluginPages(PluginListOpt{}, 10, f.query, func(info *PluginInfo)(error){
+		if len(got) == 0 && f.read > 10 {
+			t.Errorf("%d rows are read before the first plugin is passed, expect at most one page", f.read)
+		}
+		if f.read - len(got) > 10 {
+			t.Errorf("%d rows are kept before plugin %d is passed", f.read - len(got), len(got))
+		}
+		got = append(got, info.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != len(f.ids) {
+		t.Fatalf("Got %d plugins, expect %d", len(got), len(f.ids))
+	}
+	for i, id := range got {
+		if id != f.ids[i] {
+			t.Fatalf("Plugin %d is %s, expect %s", i, id, f.ids[i])
+		}
+	}
+	if len(f.pages) != 10 {
+		t.Errorf("Read %d pages, expect 10", len(f.pages))
+	}
+	for i, p := range f.pages[1:] {
+		if p.after == nil || p.after.id != f.ids[(i + 1) * 10 - 1] {
+			t.Errorf("Page %d does not start after the last row of the previous page: %+v", i + 1, p.after)
+		}
+	}
+}
+
+func TestStreamPluginPagesLimit(t *testing.T){
+	f := newFakePluginPages(95)
+	var got []string
+	err := streamPluginPages(PluginListOpt{ Limit: 25, Offset: 3 }, 10, f.query, func(info *PluginInfo)(error){
+		got = append(got, info.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != 25 || got[0] != f.ids[3] || got[24] != f.ids[27] {
+		t.Errorf("Got %v, expect the plugins from 3 to 27", got)
+	}
+	for i, p := range f.pages {
+		want := 0
+		if i == 0 {
+			want = 3
+		}
+		if p.offset != want {
+			t.Errorf("Offset of page %d is %d, expect %d", i, p.offset, want)
+		}
+	}
+	if n := len(f.pages); n != 3 || f.pages[n - 1].limit != 5 {
+		t.Errorf("Unexpected pages %+v", f.pages)
+	}
+}
+
+func TestStreamPluginPagesStop(t *testing.T){
+	f := newFakePluginPages(95)
+	stop := errors.New("stop")
+	n := 0
+	err := streamPluginPages(PluginListOpt{}, 10, f.query, func(info *PluginInfo)(error){
+		if n++; n == 15 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("Got error %v, expect the error of fn", err)
+	}
+	if len(f.pages) != 2 {
+		t.Errorf("Read %d pages after fn stopped, expect 2", len(f.pages))
+	}
+}
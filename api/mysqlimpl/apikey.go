@@ -0,0 +1,144 @@
+
+package mysqlimpl
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	. "github.com/kmcsr/PluginWebPoint/api"
+)
+
+// apiKeyCacheTTL is how long a verified key is kept in memory, so the revoked keys and the changed quotas take effect after it
+const apiKeyCacheTTL = time.Minute
+
+type cachedAPIKey struct{
+	key *APIKey
+	hash string
+	expire time.Time
+}
+
+func (api *MySqlAPI)GetAPIKey(token string)(key *APIKey, err error){
+	const queryCmd = "SELECT `name`,`secret_hash`,`scopes`,`quota`," +
+		"CONVERT_TZ(`createAt`,@@session.time_zone,'+00:00') AS `utc_createAt`," +
+		"CONVERT_TZ(`lastUsed`,@@session.time_zone,'+00:00') AS `utc_lastUsed`" +
+		" FROM api_keys WHERE `id`=? AND `revoked`=FALSE"
+
+	var id, secret string
+	if id, secret, err = ParseAPIKey(token); err != nil {
+		return
+	}
+
+	api.keyMux.Lock()
+	cached, ok := api.keys[id]
+	api.keyMux.Unlock()
+	if ok && time.Now().Before(cached.expire) {
+		if !CheckAPISecret(cached.hash, secret) {
+			return nil, ErrUnauthorized
+		}
+		return cached.key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	key = &APIKey{ Id: id }
+	var (
+		hash string
+		scopes string
+		lastUsed sql.NullTime
+	)
+	if err = api.DB.QueryRowContext(ctx, queryCmd, id).Scan(&key.Name, &hash, &scopes, &key.Quota, &key.CreateAt, &lastUsed); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrUnauthorized
+		}
+		return nil, err
+	}
+	if !CheckAPISecret(hash, secret) {
+		return nil, ErrUnauthorized
+	}
+	if len(scopes) > 0 {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsed.Valid {
+		key.LastUsed = &lastUsed.Time
+	}
+	api.keyMux.Lock()
+	api.keys[id] = &cachedAPIKey{
+		key: key,
+		hash: hash,
+		expire: time.Now().Add(apiKeyCacheTTL),
+	}
+	api.keyMux.Unlock()
+	return
+}
+
+// RecordAPIKeyUsage counts a request of the key in memory, the counters are written by FlushAPIKeyUsage
+func (api *MySqlAPI)RecordAPIKeyUsage(id string){
+	api.keyMux.Lock()
+	defer api.keyMux.Unlock()
+	api.keyUsage[id]++
+}
+
+// FlushAPIKeyUsage adds the counted requests to the daily usage of the keys, and updates their last used time.
+// All the counters are written in one transaction, so they are either all written or all kept for the next flush
+func (api *MySqlAPI)FlushAPIKeyUsage(ctx context.Context)(err error){
+	api.keyMux.Lock()
+	usage := api.keyUsage
+	api.keyUsage = make(map[string]int64)
+	api.keyMux.Unlock()
+
+	if len(usage) == 0 {
+		return
+	}
+	if err = api.flushKeyUsage(ctx, usage); err != nil {
+		// nothing was committed, put the counters back so they can be written next time
+		api.keyMux.Lock()
+		for id, n := range usage {
+			api.keyUsage[id] += n
+		}
+		api.keyMux.Unlock()
+	}
+	return
+}
+
+func (api *MySqlAPI)flushKeyUsage(ctx context.Context, usage map[string]int64)(err error){
+	const insertCmd = "INSERT INTO api_key_usage (`id`,`day`,`requests`) VALUES (?,CURDATE(),?)" +
+		" ON DUPLICATE KEY UPDATE `requests`=`requests`+VALUES(`requests`)"
+	const updateCmd = "UPDATE api_keys SET `lastUsed`=NOW() WHERE `id`=?"
+
+	var tx *sql.Tx
+	if tx, err = api.DB.BeginTx(ctx, nil); err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	for id, n := range usage {
+		if _, err = tx.ExecContext(ctx, insertCmd, id, n); err != nil {
+			return
+		}
+		if _, err = tx.ExecContext(ctx, updateCmd, id); err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// UsageFlushLoop flushes the usage of the API keys every interval, until the context is done
+func (api *MySqlAPI)UsageFlushLoop(ctx context.Context, interval time.Duration){
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fctx, cancel := context.WithTimeout(ctx, time.Second * 10)
+		if err := api.FlushAPIKeyUsage(fctx); err != nil {
+			loger.Errorf("Cannot flush API key usage: %v", err)
+		}
+		cancel()
+	}
+}
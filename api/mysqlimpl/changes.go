@@ -0,0 +1,37 @@
+
+package mysqlimpl
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	. "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func (api *MySqlAPI)GetChanges(since int64, limit int)(changes []*CatalogueChange, err error){
+	const queryCmd = "SELECT `seq`,`kind`,`op`,`id`,`tag`," +
+		"CONVERT_TZ(`time`,@@session.time_zone,'+00:00') AS `utc_time`" +
+		" FROM catalogue_changes WHERE `seq`>? ORDER BY `seq` LIMIT ?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var rows *sql.Rows
+	if rows, err = api.DB.QueryContext(ctx, queryCmd, since, limit); err != nil {
+		return
+	}
+	defer rows.Close()
+	changes = make([]*CatalogueChange, 0)
+	for rows.Next() {
+		c := new(CatalogueChange)
+		if err = rows.Scan(&c.Seq, &c.Kind, &c.Op, &c.Plugin, &c.Tag, &c.Time); err != nil {
+			return
+		}
+		changes = append(changes, c)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,57 @@
+
+package mysqlimpl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	. "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func (api *MySqlAPI)GetCatalogueEvents(since int64, limit int)(events []*CatalogueEvent, err error){
+	const queryCmd = "SELECT `seq`,`type`,`id`,`tag`," +
+		"CONVERT_TZ(`time`,@@session.time_zone,'+00:00') AS `utc_time`,`data`" +
+		" FROM catalogue_events WHERE `seq`>? ORDER BY `seq` LIMIT ?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var rows *sql.Rows
+	if rows, err = api.DB.QueryContext(ctx, queryCmd, since, limit); err != nil {
+		return
+	}
+	defer rows.Close()
+	events = make([]*CatalogueEvent, 0)
+	for rows.Next() {
+		var (
+			ev = new(CatalogueEvent)
+			tag, data sql.NullString
+		)
+		if err = rows.Scan(&ev.Seq, &ev.Type, &ev.Plugin, &tag, &ev.Time, &data); err != nil {
+			return
+		}
+		ev.Tag = tag.String
+		if data.Valid {
+			ev.Data = (json.RawMessage)(data.String)
+		}
+		events = append(events, ev)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
+
+func (api *MySqlAPI)GetLastEventSeq()(seq int64, err error){
+	const queryCmd = "SELECT IFNULL(MAX(`seq`),0) FROM catalogue_events"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	if err = api.DB.QueryRowContext(ctx, queryCmd).Scan(&seq); err != nil {
+		return
+	}
+	return
+}
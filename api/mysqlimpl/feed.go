@@ -0,0 +1,98 @@
+
+package mysqlimpl
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	. "github.com/kmcsr/PluginWebPoint/api"
+)
+
+// GetReleaseFeed returns the latest releases sorted by decreasing upload time.
+// ErrNotFound will be returned if opt.Plugin is set but the plugin does not exist
+func (api *MySqlAPI)GetReleaseFeed(opt ReleaseFeedOpt)(items []*FeedRelease, err error){
+	const queryCmd = "SELECT a.`id`,a.`name`,a.`authors`,a.`desc`,a.`desc_zhCN`," +
+		"a.`label_information`,a.`label_tool`,a.`label_management`,a.`label_api`," +
+		"b.`tag`,b.`name`,b.`stable`,b.`size`," +
+		"CONVERT_TZ(b.`uploaded`,@@session.time_zone,'+00:00') AS `utc_uploaded`," +
+		"b.`filename`,b.`changelog`" +
+		" FROM plugins AS a JOIN plugin_releases AS b ON a.`id`=b.`id`" +
+		" WHERE a.`enabled`=TRUE AND b.`enabled`=TRUE"
+	const queryPluginCmd = "SELECT 1 FROM plugins WHERE `id`=? AND `enabled`=TRUE"
+
+	cmd := queryCmd
+	args := []any{}
+	if len(opt.Plugin) > 0 {
+		cmd += " AND a.`id`=?"
+		args = append(args, opt.Plugin)
+	}
+	if len(opt.Author) > 0 {
+		cmd += " AND FIND_IN_SET(?,a.`authors`)"
+		args = append(args, opt.Author)
+	}
+	if len(opt.Label) > 0 {
+		label := strings.ToLower(opt.Label)
+		if !IsPluginLabel(label) {
+			return nil, ErrNotFound
+		}
+		cmd += " AND a.`label_" + label + "`=TRUE"
+	}
+	limit := opt.Limit
+	if limit <= 0 {
+		limit = FeedDefaultLimit
+	}else if limit > FeedMaxLimit {
+		limit = FeedMaxLimit
+	}
+	cmd += " ORDER BY b.`uploaded` DESC LIMIT ?"
+	args = append(args, limit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var rows *sql.Rows
+	if rows, err = api.DB.QueryContext(ctx, cmd, args...); err != nil {
+		loger.Debugf("sql error: %v", err)
+		return
+	}
+	defer rows.Close()
+	items = make([]*FeedRelease, 0, limit)
+	for rows.Next() {
+		var (
+			info PluginInfo
+			release PluginRelease
+			authors string
+			changelog sql.NullString
+		)
+		if err = rows.Scan(&info.Id, &info.Name, &authors, &info.Desc, &info.Desc_zhCN,
+			&info.Labels.Information, &info.Labels.Tool, &info.Labels.Management, &info.Labels.Api,
+			&release.Tag, &release.Name, &release.Stable, &release.Size,
+			&release.Uploaded, &release.FileName, &changelog); err != nil {
+			return
+		}
+		info.Authors = strings.Split(authors, ",")
+		info.Desc = (string)(ReplaceEmoji(([]byte)(info.Desc)))
+		info.Desc_zhCN = (string)(ReplaceEmoji(([]byte)(info.Desc_zhCN)))
+		release.Id = info.Id
+		release.Enabled = true
+		items = append(items, &FeedRelease{
+			Plugin: &info,
+			Release: &release,
+			Changelog: changelog.String,
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if len(items) == 0 && len(opt.Plugin) > 0 {
+		var n int
+		if err = api.DB.QueryRowContext(ctx, queryPluginCmd, opt.Plugin).Scan(&n); err != nil {
+			if err == sql.ErrNoRows {
+				err = ErrNotFound
+			}
+			return
+		}
+	}
+	return
+}
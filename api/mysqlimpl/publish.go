@@ -0,0 +1,322 @@
+
+package mysqlimpl
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api/ghsync"
+)
+
+// checkEditable returns ErrNotFound if the plugin does not exist, or ErrGithubSynced if it's synced from github
+func (api *MySqlAPI)checkEditable(ctx context.Context, id string)(err error){
+	const queryCmd = "SELECT `github_sync` FROM plugins WHERE `id`=?"
+
+	var ghSync bool
+	if err = api.DB.QueryRowContext(ctx, queryCmd, id).Scan(&ghSync); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return
+	}
+	if ghSync {
+		return ErrGithubSynced
+	}
+	return
+}
+
+func isDuplicateErr(err error)(bool){
+	var e *mysql.MySQLError
+	return errors.As(err, &e) && e.Number == 1062
+}
+
+// touchPlugin bumps the `lastUpdate` of the plugin, it's used when only the releases of the plugin are changed
+func touchPlugin(ctx context.Context, tx *sql.Tx, id string)(err error){
+	const updateCmd = "UPDATE plugins SET `lastUpdate`=NOW() WHERE `id`=?"
+
+	_, err = tx.ExecContext(ctx, updateCmd, id)
+	return
+}
+
+// CreatePlugin creates a plugin that not synced from github.
+// The plugin will be enabled after the first release is published
+func (api *MySqlAPI)CreatePlugin(id string, meta *PluginMetaUpdate)(err error){
+	const insertCmd = "INSERT INTO plugins (`id`,`name`,`enabled`,`version`,`authors`,`createAt`,`github_sync`)" +
+		" VALUES (?,?,FALSE,'0.0.0','',NOW(),FALSE)"
+
+	name := id
+	if meta.Name != nil && len(*meta.Name) > 0 {
+		name = *meta.Name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var tx *sql.Tx
+	if tx, err = api.DB.BeginTx(ctx, nil); err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, insertCmd, id, name); err != nil {
+		if isDuplicateErr(err) {
+			err = ErrExists
+		}
+		return
+	}
+	if err = updatePluginMeta(ctx, tx, id, meta); err != nil {
+		return
+	}
+	if err = ghsync.RecordChanges(ctx, tx, id); err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+func (api *MySqlAPI)UpdatePluginMeta(id string, meta *PluginMetaUpdate)(err error){
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	if err = api.checkEditable(ctx, id); err != nil {
+		return
+	}
+
+	var tx *sql.Tx
+	if tx, err = api.DB.BeginTx(ctx, nil); err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	if err = updatePluginMeta(ctx, tx, id, meta); err != nil {
+		return
+	}
+	if err = ghsync.RecordChanges(ctx, tx, id); err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+// updatePluginMeta updates the fields that are not nil in the meta
+func updatePluginMeta(ctx context.Context, tx *sql.Tx, id string, meta *PluginMetaUpdate)(err error){
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any){
+		sets = append(sets, "`" + column + "`=?")
+		args = append(args, value)
+	}
+	if meta.Name != nil {
+		set("name", *meta.Name)
+	}
+	if meta.Authors != nil {
+		set("authors", strings.Join(meta.Authors, ","))
+	}
+	if meta.Desc != nil {
+		set("desc", *meta.Desc)
+	}
+	if meta.Desc_zhCN != nil {
+		set("desc_zhCN", *meta.Desc_zhCN)
+	}
+	if meta.Repo != nil {
+		set("repo", *meta.Repo)
+	}
+	if meta.RepoBranch != nil {
+		set("repo_branch", *meta.RepoBranch)
+	}
+	if meta.RepoSubdir != nil {
+		set("repo_subdir", *meta.RepoSubdir)
+	}
+	if meta.Link != nil {
+		set("link", *meta.Link)
+	}
+	if meta.Labels != nil {
+		set("label_information", meta.Labels.Information)
+		set("label_tool", meta.Labels.Tool)
+		set("label_management", meta.Labels.Management)
+		set("label_api", meta.Labels.Api)
+	}
+	if len(sets) == 0 {
+		return
+	}
+	args = append(args, id)
+	_, err = tx.ExecContext(ctx, "UPDATE plugins SET " + strings.Join(sets, ",") + " WHERE `id`=?", args...)
+	return
+}
+
+func (api *MySqlAPI)SetPluginReadme(id string, data []byte)(err error){
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	if err = api.checkEditable(ctx, id); err != nil {
+		return
+	}
+	return api.PluginStore.Put(path.Join(id, "README.MD"), bytes.NewReader(data), (int64)(len(data)))
+}
+
+// PublishRelease validates the .mcdr package and publishes it as a new release.
+// If the release is the latest one, the version, the dependencies and the requirements of the plugin will be updated
+func (api *MySqlAPI)PublishRelease(id string, tag Version, opt ReleasePublishOpt, data []byte)(release *PluginRelease, err error){
+	// visible is whether the plugin is enabled after the release is published
+	const queryVersionCmd = "SELECT `version`,`lastRelease` IS NULL," +
+		"`enabled` OR (`lastRelease` IS NULL AND `enabled_locked`=FALSE) AS `visible`" +
+		" FROM plugins WHERE `id`=? FOR UPDATE"
+	const insertReleaseCmd = "INSERT INTO plugin_releases (`id`,`tag`,`enabled`,`stable`,`size`,`uploaded`,`filename`,`downloads`," +
+		"`name`,`changelog`,`sha256`,`sha1`,`mcdr_meta`,`problems`)" +
+		" VALUES (?,?,TRUE,?,?,NOW(),?,0,?,?,?,?,?,?)"
+	const updatePluginCmd = "UPDATE plugins SET `version`=?," +
+		"`enabled`=IF(`lastRelease` IS NULL AND `enabled_locked`=FALSE,TRUE,`enabled`),`lastRelease`=NOW()" +
+		" WHERE `id`=?"
+	const removeDepenceCmd = "DELETE FROM plugin_dependencies WHERE `id`=?"
+	const insertDepenceCmd = "INSERT INTO plugin_dependencies (`id`,`target`,`tag`) VALUES (?,?,?)"
+	const removeRequireCmd = "DELETE FROM plugin_requirements WHERE `id`=?"
+	const insertRequireCmd = "INSERT INTO plugin_requirements (`id`,`target`,`tag`) VALUES (?,?,?)"
+
+	var info *McdrInfo
+	if info, err = ValidateReleasePackage(id, tag, data); err != nil {
+		return
+	}
+	if opt.FileName, err = ReleaseAssetName(id, tag, opt.FileName); err != nil {
+		return
+	}
+	var sums Checksums
+	if sums, err = CalcChecksums(bytes.NewReader(data)); err != nil {
+		return
+	}
+	var metaData, problemsData []byte
+	if metaData, err = json.Marshal(info); err != nil {
+		return
+	}
+	if problemsData, err = json.Marshal(info.Problems); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 30)
+	defer cancel()
+
+	if err = api.checkEditable(ctx, id); err != nil {
+		return
+	}
+	if err = api.releaseExists(ctx, id, tag); err == nil {
+		return nil, ErrExists
+	}else if err != ErrNotFound {
+		return
+	}
+
+	key := assetKey(id, tag, opt.FileName)
+	if _, err = api.PluginStore.Stat(key); err == nil {
+		return nil, ErrExists
+	}else if !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err = api.PluginStore.Put(key, bytes.NewReader(data), (int64)(len(data))); err != nil {
+		return
+	}
+	committed := false
+	defer func(){
+		if !committed {
+			if err := api.PluginStore.Remove(key); err != nil {
+				loger.Warnf("Cannot remove the asset %q of the failed publish: %v", key, err)
+			}
+		}
+	}()
+
+	var tx *sql.Tx
+	if tx, err = api.DB.BeginTx(ctx, nil); err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	var (
+		current Version
+		first, visible bool
+	)
+	if err = tx.QueryRowContext(ctx, queryVersionCmd, id).Scan(&current, &first, &visible); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, insertReleaseCmd, id, tag, opt.Stable, len(data), opt.FileName,
+		opt.Name, opt.Changelog, sums.Sha256, sums.Sha1, (string)(metaData), (string)(problemsData)); err != nil {
+		if isDuplicateErr(err) {
+			err = ErrExists
+		}
+		return
+	}
+	if !current.Less(tag) {
+		// an older release doesn't change the plugin itself
+		if err = touchPlugin(ctx, tx, id); err != nil {
+			return
+		}
+	}else{
+		if _, err = tx.ExecContext(ctx, updatePluginCmd, tag, id); err != nil {
+			return
+		}
+		if _, err = tx.ExecContext(ctx, removeDepenceCmd, id); err != nil {
+			return
+		}
+		for target, cond := range info.Meta.Dependencies {
+			if _, err = tx.ExecContext(ctx, insertDepenceCmd, id, target, cond); err != nil {
+				return
+			}
+		}
+		if _, err = tx.ExecContext(ctx, removeRequireCmd, id); err != nil {
+			return
+		}
+		for _, req := range info.Requirements {
+			target, cond := splitRequirement(req)
+			if _, err = tx.ExecContext(ctx, insertRequireCmd, id, target, cond); err != nil {
+				return
+			}
+		}
+	}
+	// the plugin becomes visible after its first release, so it's reported as a new plugin like the synced ones.
+	// Nothing is reported for the plugins disabled by the admins
+	if visible {
+		ev := &CatalogueEvent{ Type: EventReleaseAdded, Plugin: id, Tag: tag.String() }
+		if first {
+			ev = &CatalogueEvent{ Type: EventPluginAdded, Plugin: id }
+		}
+		if err = ghsync.RecordEvent(tx, ev); err != nil {
+			return
+		}
+	}
+	if err = ghsync.RecordChanges(ctx, tx, id); err != nil {
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	committed = true
+	loger.Infof("Published %s(v%s):%s", id, tag, opt.FileName)
+	// the plugin may be disabled by the admins
+	return api.queryRelease(id, tag, false)
+}
+
+// splitRequirement splits the python requirement into the package name and the version condition
+func splitRequirement(req string)(target string, cond string){
+	i := strings.IndexAny(req, "<>=!~;[ ")
+	if i < 0 {
+		return req, ""
+	}
+	return req[:i], strings.ReplaceAll(req[i:], " ", "")
+}
+
+func (api *MySqlAPI)SetReleaseStable(id string, tag Version, stable bool)(err error){
+	const updateCmd = "UPDATE plugin_releases SET `stable`=? WHERE `id`=? AND `tag`=?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	if err = api.checkEditable(ctx, id); err != nil {
+		return
+	}
+	return api.updateRelease(ctx, id, tag, updateCmd, stable, id, tag)
+}
@@ -0,0 +1,47 @@
+
+package mysqlimpl
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	. "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func (api *MySqlAPI)GetSitemapPlugins()(plugins []*SitemapPlugin, err error){
+	const queryCmd = "SELECT `id`,`authors`," +
+		"CONVERT_TZ(`lastUpdate`,@@session.time_zone,'+00:00') AS `utc_lastUpdate`," +
+		"CONVERT_TZ(`lastRelease`,@@session.time_zone,'+00:00') AS `utc_lastRelease`" +
+		" FROM plugins WHERE `enabled`=TRUE ORDER BY `id`"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var rows *sql.Rows
+	if rows, err = api.DB.QueryContext(ctx, queryCmd); err != nil {
+		return
+	}
+	defer rows.Close()
+	plugins = make([]*SitemapPlugin, 0)
+	for rows.Next() {
+		var (
+			p = new(SitemapPlugin)
+			authors string
+			lastRelease sql.NullTime
+		)
+		if err = rows.Scan(&p.Id, &authors, &p.LastUpdate, &lastRelease); err != nil {
+			return
+		}
+		p.Authors = strings.Split(authors, ",")
+		if lastRelease.Valid {
+			p.LastRelease = &lastRelease.Time
+		}
+		plugins = append(plugins, p)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,137 @@
+
+package mysqlimpl
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	. "github.com/kmcsr/PluginWebPoint/api"
+)
+
+// CreateWebhook registers the webhook for the owner key, the id, the secret and the create time of the hook will be set
+func (api *MySqlAPI)CreateWebhook(owner string, hook *Webhook)(err error){
+	const insertCmd = "INSERT INTO webhooks (`owner`,`url`,`secret`,`events`) VALUES (?,?,?,?)"
+
+	if hook.Secret, err = GenerateWebhookSecret(); err != nil {
+		return
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	hook.CreateAt = time.Now().UTC().Truncate(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var res sql.Result
+	if res, err = api.DB.ExecContext(ctx, insertCmd, owner, hook.Url, hook.Secret, strings.Join(hook.Events, ",")); err != nil {
+		return
+	}
+	if hook.Id, err = res.LastInsertId(); err != nil {
+		return
+	}
+	loger.Infof("Webhook %d is created by key %s: %s", hook.Id, owner, hook.Url)
+	return
+}
+
+func (api *MySqlAPI)GetWebhooks(owner string)(hooks []*Webhook, err error){
+	const queryCmd = "SELECT `id`,`url`,`events`," +
+		"CONVERT_TZ(`createAt`,@@session.time_zone,'+00:00') AS `utc_createAt`" +
+		" FROM webhooks WHERE `owner`=? ORDER BY `id`"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var rows *sql.Rows
+	if rows, err = api.DB.QueryContext(ctx, queryCmd, owner); err != nil {
+		return
+	}
+	defer rows.Close()
+	hooks = make([]*Webhook, 0)
+	for rows.Next() {
+		var (
+			hook = new(Webhook)
+			events string
+		)
+		if err = rows.Scan(&hook.Id, &hook.Url, &events, &hook.CreateAt); err != nil {
+			return
+		}
+		hook.Events = []string{}
+		if len(events) > 0 {
+			hook.Events = strings.Split(events, ",")
+		}
+		hooks = append(hooks, hook)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
+
+func (api *MySqlAPI)DeleteWebhook(owner string, id int64)(err error){
+	const deleteCmd = "DELETE FROM webhooks WHERE `id`=? AND `owner`=?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var res sql.Result
+	if res, err = api.DB.ExecContext(ctx, deleteCmd, id, owner); err != nil {
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	loger.Infof("Webhook %d is deleted by key %s", id, owner)
+	return
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the webhook, sorted by decreasing id
+func (api *MySqlAPI)GetWebhookDeliveries(owner string, id int64, limit int)(deliveries []*WebhookDelivery, err error){
+	const queryOwnerCmd = "SELECT 1 FROM webhooks WHERE `id`=? AND `owner`=?"
+	const queryCmd = "SELECT d.`id`,d.`event`,e.`type`,e.`id`,d.`status`,d.`attempts`,d.`last_code`,d.`last_error`," +
+		"CONVERT_TZ(d.`next_attempt`,@@session.time_zone,'+00:00') AS `utc_next_attempt`," +
+		"CONVERT_TZ(d.`updated`,@@session.time_zone,'+00:00') AS `utc_updated`" +
+		" FROM webhook_deliveries AS d JOIN catalogue_events AS e ON d.`event`=e.`seq`" +
+		" WHERE d.`webhook`=? ORDER BY d.`id` DESC LIMIT ?"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var n int
+	if err = api.DB.QueryRowContext(ctx, queryOwnerCmd, id, owner).Scan(&n); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return
+	}
+
+	var rows *sql.Rows
+	if rows, err = api.DB.QueryContext(ctx, queryCmd, id, limit); err != nil {
+		return
+	}
+	defer rows.Close()
+	deliveries = make([]*WebhookDelivery, 0)
+	for rows.Next() {
+		var (
+			d = new(WebhookDelivery)
+			code sql.NullInt32
+			msg sql.NullString
+			next sql.NullTime
+		)
+		if err = rows.Scan(&d.Id, &d.Event, &d.EventType, &d.Plugin, &d.Status, &d.Attempts,
+			&code, &msg, &next, &d.Updated); err != nil {
+			return
+		}
+		d.LastCode, d.LastError = (int)(code.Int32), msg.String
+		if next.Valid {
+			d.NextAttempt = &next.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return
+}
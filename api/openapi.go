@@ -0,0 +1,346 @@
+
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIParam describes a query or header parameter of an operation, the path parameters are parsed from the route path
+type OpenAPIParam struct{
+	Name     string
+	In       string // `query` or `header`
+	Type     string // `string`, `integer` or `boolean`, default is `string`
+	Desc     string
+	Required bool
+	Enum     []string
+}
+
+// OpenAPIOperation describes a route of the API
+type OpenAPIOperation struct{
+	Method  string
+	Path    string // the iris route path, e.g. `/plugin/{id:string pid()}/info`
+	Summary string
+	Tag     string
+	Params  []OpenAPIParam
+	// Body is a value of the json request body, which schema is generated by reflection
+	Body     any
+	// BodyType is the content type of the request body if it's not json
+	BodyType string
+	// Result is a value of the `data` field in the ok response, nil means the data is null
+	Result     any
+	// ResultType is the content type of the response if it's not the json envelope
+	ResultType string
+	// Formats are the formats other than FormatJSON which can be selected by `Accept` or the `format` parameter
+	Formats    []string
+	Status     int // the status code of the success response, default is 200
+	Errors     []int
+	Auth       string // the scope of the API key required by the route
+}
+
+var irisParamRe = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(?::([a-z0-9]+)[^}]*)?\}`)
+
+// OpenAPIPath converts the iris route path to the OpenAPI path, and returns the path parameters
+func OpenAPIPath(path string)(string, []OpenAPIParam){
+	var params []OpenAPIParam
+	path = irisParamRe.ReplaceAllStringFunc(path, func(s string)(string){
+		m := irisParamRe.FindStringSubmatch(s)
+		typ := "string"
+		switch m[2] {
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+			typ = "integer"
+		case "bool":
+			typ = "boolean"
+		}
+		params = append(params, OpenAPIParam{ Name: m[1], In: "path", Type: typ, Required: true })
+		return "{" + m[1] + "}"
+	})
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path, params
+}
+
+// schemaBuilder generates the json schemas of the go types, the named structs are placed in the components
+type schemaBuilder struct{
+	schemas map[string]any
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+func (b *schemaBuilder)schemaOf(t reflect.Type)(map[string]any){
+	if t == nil {
+		return map[string]any{}
+	}
+	if t == timeType {
+		return map[string]any{ "type": "string", "format": "date-time" }
+	}
+	// the custom marshaled types (e.g. Version) are encoded as strings
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return map[string]any{ "type": "string" }
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]any{ "type": "boolean" }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{ "type": "integer" }
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{ "type": "integer", "format": "int64" }
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{ "type": "number" }
+	case reflect.String:
+		return map[string]any{ "type": "string" }
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{ "type": "string", "format": "byte" }
+		}
+		return map[string]any{ "type": "array", "items": b.schemaOf(t.Elem()) }
+	case reflect.Map:
+		return map[string]any{ "type": "object", "additionalProperties": b.schemaOf(t.Elem()) }
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return b.structSchema(t)
+		}
+		if _, ok := b.schemas[t.Name()]; !ok {
+			b.schemas[t.Name()] = nil // placeholder for the recursive types
+			b.schemas[t.Name()] = b.structSchema(t)
+		}
+		return map[string]any{ "$ref": "#/components/schemas/" + t.Name() }
+	}
+	return map[string]any{}
+}
+
+func (b *schemaBuilder)structSchema(t reflect.Type)(map[string]any){
+	props := make(map[string]any)
+	required := []string{}
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type){
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && len(name) == 0 && f.Type.Kind() == reflect.Struct {
+				addFields(f.Type)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if len(name) == 0 {
+				name = f.Name
+			}
+			props[name] = b.schemaOf(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+	s := map[string]any{
+		"type": "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func (p *OpenAPIParam)schema()(map[string]any){
+	typ := p.Type
+	if len(typ) == 0 {
+		typ = "string"
+	}
+	s := map[string]any{ "type": typ }
+	if len(p.Enum) > 0 {
+		s["enum"] = p.Enum
+	}
+	return s
+}
+
+// NewOpenAPI generates the OpenAPI 3 document of the operations, server is the url prefix of the API
+func NewOpenAPI(title string, version string, server string, ops []*OpenAPIOperation)(doc map[string]any){
+	b := &schemaBuilder{ schemas: make(map[string]any) }
+	b.schemas["OkResp"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status": map[string]any{ "type": "string", "enum": []string{"ok"} },
+			"data": map[string]any{},
+		},
+		"required": []string{"data", "status"},
+	}
+	b.schemas["ErrResp"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status": map[string]any{ "type": "string", "enum": []string{"error"} },
+			"error": map[string]any{ "type": "string", "description": "The name of the error" },
+			"message": map[string]any{ "type": "string" },
+			"extra": map[string]any{ "type": "string" },
+		},
+		"required": []string{"error", "message", "status"},
+	}
+	errResp := map[string]any{
+		"application/json": map[string]any{
+			"schema": map[string]any{ "$ref": "#/components/schemas/ErrResp" },
+		},
+	}
+
+	paths := make(map[string]any)
+	for _, op := range ops {
+		path, params := OpenAPIPath(op.Path)
+		params = append(params, op.Params...)
+		if len(op.Formats) > 0 {
+			params = append(params, OpenAPIParam{
+				Name: "format", In: "query",
+				Enum: append([]string{FormatJSON}, op.Formats...),
+				Desc: "The response format, it takes precedence over the Accept header",
+			})
+		}
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		o := map[string]any{
+			"operationId": operationId(op.Method, path),
+			"summary": op.Summary,
+		}
+		if len(op.Tag) > 0 {
+			o["tags"] = []string{op.Tag}
+		}
+		if len(params) > 0 {
+			list := make([]any, len(params))
+			for i, p := range params {
+				param := map[string]any{
+					"name": p.Name,
+					"in": p.In,
+					"schema": p.schema(),
+				}
+				if p.Required {
+					param["required"] = true
+				}
+				if len(p.Desc) > 0 {
+					param["description"] = p.Desc
+				}
+				list[i] = param
+			}
+			o["parameters"] = list
+		}
+		if op.Body != nil {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{ "schema": b.schemaOf(reflect.TypeOf(op.Body)) },
+				},
+			}
+		}else if len(op.BodyType) > 0 {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					op.BodyType: map[string]any{ "schema": map[string]any{ "type": "string", "format": "binary" } },
+				},
+			}
+		}
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var content map[string]any
+		if len(op.ResultType) > 0 {
+			content = make(map[string]any)
+			for _, typ := range strings.Split(op.ResultType, ",") {
+				content[strings.TrimSpace(typ)] = map[string]any{}
+			}
+		}else{
+			data := map[string]any{ "nullable": true }
+			if op.Result != nil {
+				data = b.schemaOf(reflect.TypeOf(op.Result))
+			}
+			content = map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{
+						"allOf": []any{
+							map[string]any{ "$ref": "#/components/schemas/OkResp" },
+							map[string]any{ "properties": map[string]any{ "data": data } },
+						},
+					},
+				},
+			}
+		}
+		for _, f := range op.Formats {
+			typ, _, _ := mime.ParseMediaType(FormatContentTypes[f])
+			content[typ] = map[string]any{}
+		}
+		responses := map[string]any{
+			strconv.Itoa(status): map[string]any{
+				"description": http.StatusText(status),
+				"content": content,
+			},
+		}
+		for _, code := range op.Errors {
+			responses[strconv.Itoa(code)] = map[string]any{
+				"description": http.StatusText(code),
+				"content": errResp,
+			}
+		}
+		o["responses"] = responses
+		if len(op.Auth) > 0 {
+			o["security"] = []any{
+				map[string]any{ "bearer": []string{} },
+				map[string]any{ "apiKey": []string{} },
+			}
+			o["description"] = "Requires an API key with the `" + op.Auth + "` scope"
+		}
+		item[strings.ToLower(op.Method)] = o
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title": title,
+			"version": version,
+		},
+		"servers": []any{
+			map[string]any{ "url": server },
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{ "type": "http", "scheme": "bearer" },
+				"apiKey": map[string]any{ "type": "apiKey", "in": "header", "name": "X-API-Key" },
+			},
+		},
+	}
+}
+
+var operationIdRe = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// operationId generates the id from the method and the path, e.g. `GET /plugin/{id}/info` to `getPluginIdInfo`
+func operationId(method string, path string)(string){
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, w := range operationIdRe.Split(path, -1) {
+		if len(w) > 0 {
+			sb.WriteString(strings.ToUpper(w[:1]))
+			sb.WriteString(w[1:])
+		}
+	}
+	return sb.String()
+}
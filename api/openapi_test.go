@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestOpenAPIPath(t *testing.T){
+	cases := []struct{
+		src    string
+		path   string
+		params []string
+	}{
+		{"/", "/", nil},
+		{"/plugins/", "/plugins", nil},
+		{"/plugin/{id:string pid()}/info", "/plugin/{id}/info", []string{"id:string"}},
+		{"/plugin/{id:string pid()}/release/{tag:string version()}/asset/{filename:file}",
+			"/plugin/{id}/release/{tag}/asset/{filename}", []string{"id:string", "tag:string", "filename:string"}},
+		{"/webhooks/{hook:int64}/deliveries", "/webhooks/{hook}/deliveries", []string{"hook:integer"}},
+		{"/files/{path}", "/files/{path}", []string{"path:string"}},
+	}
+	for _, c := range cases {
+		path, params := api.OpenAPIPath(c.src)
+		if path != c.path {
+			t.Errorf("Path of %q is %q, expect %q", c.src, path, c.path)
+		}
+		if len(params) != len(c.params) {
+			t.Errorf("Got %d params of %q, expect %d", len(params), c.src, len(c.params))
+			continue
+		}
+		for i, p := range params {
+			if got := p.Name + ":" + p.Type; got != c.params[i] || p.In != "path" || !p.Required {
+				t.Errorf("Param %d of %q is %s in %s, expect required %s in path", i, c.src, got, p.In, c.params[i])
+			}
+		}
+	}
+}
+
+func TestNewOpenAPI(t *testing.T){
+	doc := api.NewOpenAPI("Test", "1", "https://example.com/v1", []*api.OpenAPIOperation{
+		{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/info", Result: &api.PluginInfo{}, Errors: []int{404} },
+		{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/releases", Result: []*api.PluginRelease{} },
+		{ Method: http.MethodPut, Path: "/admin/plugin/{id:string pid()}/enabled", Auth: api.ScopeAdmin },
+	})
+	// round trip through json to check the document as the clients see it
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Cannot marshal the document: %v", err)
+	}
+	var res struct{
+		Paths map[string]map[string]struct{
+			OperationId string             `json:"operationId"`
+			Responses   map[string]any     `json:"responses"`
+			Security    []map[string][]any `json:"security"`
+		} `json:"paths"`
+		Components struct{
+			Schemas map[string]struct{
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatalf("Cannot unmarshal the document: %v", err)
+	}
+
+	info, ok := res.Paths["/plugin/{id}/info"]["get"]
+	if !ok {
+		t.Fatalf("Operation GET /plugin/{id}/info not found")
+	}
+	if info.OperationId != "getPluginIdInfo" {
+		t.Errorf("OperationId is %q, expect %q", info.OperationId, "getPluginIdInfo")
+	}
+	if _, ok := info.Responses["404"]; !ok {
+		t.Errorf("Response 404 of GET /plugin/{id}/info not found")
+	}
+	if admin := res.Paths["/admin/plugin/{id}/enabled"]["put"]; len(admin.Security) == 0 {
+		t.Errorf("Security of PUT /admin/plugin/{id}/enabled not found")
+	}
+
+	for _, name := range []string{"OkResp", "ErrResp", "PluginInfo", "PluginRelease", "PluginLabels"} {
+		if _, ok := res.Components.Schemas[name]; !ok {
+			t.Errorf("Schema %s not found", name)
+		}
+	}
+	plugin := res.Components.Schemas["PluginInfo"]
+	if typ := plugin.Properties["version"]["type"]; typ != "string" {
+		t.Errorf("Type of PluginInfo.version is %v, expect string", typ)
+	}
+	if typ := plugin.Properties["createAt"]["format"]; typ != "date-time" {
+		t.Errorf("Format of PluginInfo.createAt is %v, expect date-time", typ)
+	}
+	for _, name := range plugin.Required {
+		if name == "desc" {
+			t.Errorf("Optional field PluginInfo.desc is required")
+		}
+	}
+	// the embedded Checksums is flattened
+	if _, ok := res.Components.Schemas["PluginRelease"].Properties["sha256"]; !ok {
+		t.Errorf("Property PluginRelease.sha256 not found")
+	}
+}
@@ -0,0 +1,56 @@
+
+package api
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	TrendingHalfLife = time.Hour * 24 * 3
+	PopularHalfLife  = time.Hour * 24 * 30
+	// ScoreWindow is how long the download history will be used to calculate the scores
+	ScoreWindow = time.Hour * 24 * 120
+)
+
+// DownloadSample is a snapshot of the cumulative download count
+type DownloadSample struct{
+	Time      time.Time
+	Downloads int64
+}
+
+// SampleIncrements converts cumulative download samples of a release into download increments.
+// The first sample is used as the baseline, unless the release is uploaded inside the window,
+// in that case the downloads are counted from zero.
+// The samples must be sorted by time
+func SampleIncrements(uploaded time.Time, windowStart time.Time, samples []DownloadSample)(incs []*DownloadStat){
+	if len(samples) == 0 {
+		return
+	}
+	last := samples[0]
+	if !uploaded.Before(windowStart) && !uploaded.After(last.Time) {
+		if last.Downloads > 0 {
+			incs = append(incs, &DownloadStat{ Time: last.Time, Count: last.Downloads })
+		}
+	}
+	for _, s := range samples[1:] {
+		if d := s.Downloads - last.Downloads; d > 0 {
+			incs = append(incs, &DownloadStat{ Time: s.Time, Count: d })
+		}
+		last = s
+	}
+	return
+}
+
+// DecayedScore sums the download increments with exponential time decay,
+// an increment is worth half after each halfLife passed
+func DecayedScore(incs []*DownloadStat, now time.Time, halfLife time.Duration)(score float64){
+	for _, inc := range incs {
+		age := now.Sub(inc.Time)
+		if age < 0 {
+			age = 0
+		}
+		score += (float64)(inc.Count) * math.Exp2(-(float64)(age) / (float64)(halfLife))
+	}
+	return
+}
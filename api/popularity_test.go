@@ -0,0 +1,45 @@
+
+package api_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestSampleIncrements(t *testing.T){
+	windowStart := date("2023-05-01")
+	samples := []api.DownloadSample{
+		{ Time: date("2023-05-02"), Downloads: 10 },
+		{ Time: date("2023-05-03"), Downloads: 15 },
+		{ Time: date("2023-05-04"), Downloads: 15 },
+		{ Time: date("2023-05-05"), Downloads: 12 }, // asset re-uploaded
+		{ Time: date("2023-05-06"), Downloads: 20 },
+	}
+	incs := api.SampleIncrements(date("2023-01-01"), windowStart, samples)
+	if len(incs) != 2 || incs[0].Count != 5 || incs[1].Count != 8 {
+		t.Errorf("Unexpect increments for old release: %v", incs)
+	}
+	incs = api.SampleIncrements(date("2023-05-01"), windowStart, samples[:2])
+	if len(incs) != 2 || incs[0].Count != 10 || incs[1].Count != 5 {
+		t.Errorf("Unexpect increments for new release: %v", incs)
+	}
+}
+
+func TestDecayedScore(t *testing.T){
+	now := date("2023-05-10")
+	incs := []*api.DownloadStat{
+		{ Time: now, Count: 8 },
+		{ Time: now.Add(-time.Hour * 24 * 3), Count: 8 },
+		{ Time: now.Add(-time.Hour * 24 * 6), Count: 8 },
+	}
+	score := api.DecayedScore(incs, now, time.Hour * 24 * 3)
+	if math.Abs(score - 14) > 1e-9 {
+		t.Errorf("Unexpect score %v, expect 14", score)
+	}
+	if score := api.DecayedScore(nil, now, time.Hour); score != 0 {
+		t.Errorf("Unexpect score %v for empty increments, expect 0", score)
+	}
+}
@@ -0,0 +1,78 @@
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var (
+	ErrExists = errors.New("ErrExists")
+	// ErrGithubSynced is returned when editing a plugin that synced from github
+	ErrGithubSynced = errors.New("ErrGithubSynced")
+	// ErrNotGithubSynced is returned when resyncing a plugin that not synced from github
+	ErrNotGithubSynced = errors.New("ErrNotGithubSynced")
+)
+
+// PluginMetaUpdate is the editable metadata of a plugin, the nil fields will not be changed
+type PluginMetaUpdate struct{
+	Name       *string       `json:"name,omitempty"`
+	Authors    []string      `json:"authors,omitempty"`
+	Desc       *string       `json:"desc,omitempty"`
+	Desc_zhCN  *string       `json:"desc_zhCN,omitempty"`
+	Repo       *string       `json:"repo,omitempty"`
+	RepoBranch *string       `json:"repoBranch,omitempty"`
+	RepoSubdir *string       `json:"repoSubdir,omitempty"`
+	Link       *string       `json:"link,omitempty"`
+	Labels     *PluginLabels `json:"labels,omitempty"`
+}
+
+type ReleasePublishOpt struct{
+	Name      string `json:"name"`
+	Changelog string `json:"changelog"`
+	Stable    bool   `json:"stable"`
+	FileName  string `json:"filename"`
+}
+
+// PackageProblemsErr is returned when the uploaded .mcdr package does not match the release
+type PackageProblemsErr struct{
+	Problems []string
+}
+
+func (e *PackageProblemsErr)Error()(string){
+	return "Invalid package: " + strings.Join(e.Problems, "; ")
+}
+
+// ValidateReleasePackage checks the uploaded .mcdr package with the plugin id and the release tag
+func ValidateReleasePackage(id string, tag Version, data []byte)(info *McdrInfo, err error){
+	if len(data) > McdrMaxSize {
+		return nil, ErrMcdrTooLarge
+	}
+	if info, err = ReadMcdr(bytes.NewReader(data), (int64)(len(data))); err != nil {
+		return
+	}
+	info.Problems = append([]string{}, info.Check(id, tag)...)
+	if len(info.Problems) > 0 {
+		return nil, &PackageProblemsErr{ Problems: info.Problems }
+	}
+	return
+}
+
+// ReleaseAssetName returns the filename of the uploaded asset.
+// The directories are removed from the name, and `{id}-v{tag}.mcdr` is used if the name is empty
+func ReleaseAssetName(id string, tag Version, name string)(string, error){
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || len(name) == 0 {
+		name = fmt.Sprintf("%s-v%s.mcdr", id, tag)
+	}
+	if !strings.HasSuffix(name, ".mcdr") {
+		return "", fmt.Errorf("Asset name %q should end with .mcdr", name)
+	}
+	if len(name) > 64 {
+		return "", fmt.Errorf("Asset name %q is longer than 64 characters", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,172 @@
+
+package api
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAnonRateLimit is the requests per window allowed for the callers without API key, keyed by the IP
+	DefaultAnonRateLimit = 60
+	// DefaultKeyRateLimit is the requests per window allowed for the API keys which quota is zero
+	DefaultKeyRateLimit = 600
+	RateLimitWindow = time.Minute
+)
+
+type rateCounter struct{
+	start time.Time
+	count int
+}
+
+// RateLimiter counts the requests of each caller in fixed windows.
+// The counters are kept in memory, so the limits apply to each process separately
+type RateLimiter struct{
+	window time.Duration
+
+	mux sync.Mutex
+	counters map[string]*rateCounter
+}
+
+func NewRateLimiter(window time.Duration)(*RateLimiter){
+	return &RateLimiter{
+		window: window,
+		counters: make(map[string]*rateCounter),
+	}
+}
+
+// Allow records a request of the caller, and reports whether the caller is still under the limit.
+// The remaining count and the reset time of the current window are also returned
+func (l *RateLimiter)Allow(caller string, limit int, now time.Time)(ok bool, remaining int, reset time.Time){
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	c, exists := l.counters[caller]
+	if !exists || !now.Before(c.start.Add(l.window)) {
+		c = &rateCounter{ start: now.Truncate(l.window) }
+		l.counters[caller] = c
+	}
+	reset = c.start.Add(l.window)
+	if c.count >= limit {
+		return false, 0, reset
+	}
+	c.count++
+	return true, limit - c.count, reset
+}
+
+// Exceeded reports whether the caller has reached the limit in the current window, without recording a request
+func (l *RateLimiter)Exceeded(caller string, limit int, now time.Time)(bool){
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	c, exists := l.counters[caller]
+	return exists && now.Before(c.start.Add(l.window)) && c.count >= limit
+}
+
+// Cleanup removes the counters which window is ended
+func (l *RateLimiter)Cleanup(now time.Time){
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for k, c := range l.counters {
+		if !now.Before(c.start.Add(l.window)) {
+			delete(l.counters, k)
+		}
+	}
+}
+
+// CleanupLoop cleans up the counters every window, until the done channel is closed
+func (l *RateLimiter)CleanupLoop(done <-chan struct{}){
+	ticker := time.NewTicker(l.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			l.Cleanup(now)
+		}
+	}
+}
+
+// RateLimitFromEnv reads the limit from the env, or returns def if it's not set
+func RateLimitFromEnv(name string, def int)(int){
+	s := os.Getenv(name)
+	if len(s) == 0 {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		loger.Fatalf("Env %s should be a positive integer, got %q", name, s)
+	}
+	return n
+}
+
+// DefaultTrustedProxies are the networks of the reverse proxies in the usual deployments,
+// which are the loopback and the private networks
+const DefaultTrustedProxies = "127.0.0.0/8,::1/128,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,fc00::/7"
+
+// ParseTrustedProxies parses the comma separated IPs and CIDRs
+func ParseTrustedProxies(s string)(nets []*net.IPNet, err error){
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) == 0 {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid IP %q", item)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{ IP: ip, Mask: net.CIDRMask(bits, bits) })
+			continue
+		}
+		var n *net.IPNet
+		if _, n, err = net.ParseCIDR(item); err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return
+}
+
+// TrustedProxiesFromEnv reads the trusted proxies from env `TRUSTED_PROXIES`, or uses DefaultTrustedProxies if it's not set
+func TrustedProxiesFromEnv()(nets []*net.IPNet){
+	s, ok := os.LookupEnv("TRUSTED_PROXIES")
+	if !ok {
+		s = DefaultTrustedProxies
+	}
+	nets, err := ParseTrustedProxies(s)
+	if err != nil {
+		loger.Fatalf("Cannot parse env TRUSTED_PROXIES: %v", err)
+	}
+	return
+}
+
+// ClientIP returns the IP in the `X-Real-IP` header if the request is from a trusted proxy,
+// or the IP of the remote address otherwise, so the clients cannot spoof their IPs
+func ClientIP(remoteAddr string, realIP string, trusted []*net.IPNet)(string){
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if realIP = strings.TrimSpace(realIP); len(realIP) == 0 {
+		return host
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				return realIP
+			}
+		}
+	}
+	return host
+}
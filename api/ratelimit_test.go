@@ -0,0 +1,98 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestRateLimiter(t *testing.T){
+	l := api.NewRateLimiter(time.Minute)
+	now := time.Date(2023, 1, 1, 12, 0, 10, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		ok, remaining, reset := l.Allow("a", 3, now)
+		if !ok {
+			t.Fatalf("Request %d should be allowed", i)
+		}
+		if remaining != 2 - i {
+			t.Errorf("Request %d: remaining is %d, expect %d", i, remaining, 2 - i)
+		}
+		if want := time.Date(2023, 1, 1, 12, 1, 0, 0, time.UTC); !reset.Equal(want) {
+			t.Errorf("Reset time is %v, expect %v", reset, want)
+		}
+	}
+	if ok, _, _ := l.Allow("a", 3, now); ok {
+		t.Errorf("The 4th request should be rejected")
+	}
+	if ok, _, _ := l.Allow("b", 3, now); !ok {
+		t.Errorf("The callers should be counted separately")
+	}
+	if ok, remaining, _ := l.Allow("a", 3, now.Add(time.Minute)); !ok || remaining != 2 {
+		t.Errorf("The counter should be reset in the next window, got (%v, %d)", ok, remaining)
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T){
+	l := api.NewRateLimiter(time.Minute)
+	now := time.Date(2023, 1, 1, 12, 0, 10, 0, time.UTC)
+	l.Allow("a", 1, now)
+	l.Cleanup(now)
+	if ok, _, _ := l.Allow("a", 1, now); ok {
+		t.Errorf("The counter in the current window should not be removed")
+	}
+	l.Cleanup(now.Add(time.Minute))
+	if ok, _, _ := l.Allow("a", 1, now); !ok {
+		t.Errorf("The counter should be removed after the window ended")
+	}
+}
+
+func TestRateLimiterExceeded(t *testing.T){
+	l := api.NewRateLimiter(time.Minute)
+	now := time.Date(2023, 1, 1, 12, 0, 10, 0, time.UTC)
+	if l.Exceeded("a", 2, now) {
+		t.Errorf("The new caller should not exceed the limit")
+	}
+	l.Allow("a", 2, now)
+	if l.Exceeded("a", 2, now) {
+		t.Errorf("The caller should not exceed the limit after 1 request")
+	}
+	l.Allow("a", 2, now)
+	if !l.Exceeded("a", 2, now) || !l.Exceeded("a", 2, now) {
+		t.Errorf("The caller should exceed the limit after 2 requests")
+	}
+	if l.Exceeded("a", 2, now.Add(time.Minute)) {
+		t.Errorf("The limit should be reset in the next window")
+	}
+}
+
+func TestClientIP(t *testing.T){
+	trusted, err := api.ParseTrustedProxies("127.0.0.1, 10.0.0.0/8, fc00::/7")
+	if err != nil {
+		t.Fatalf("Cannot parse the trusted proxies: %v", err)
+	}
+	for _, c := range []struct{
+		remoteAddr, realIP string
+		expect string
+	}{
+		{ "127.0.0.1:4321", "203.0.113.5", "203.0.113.5" },
+		{ "10.1.2.3:80", "203.0.113.5", "203.0.113.5" },
+		{ "[fd00::1]:80", "203.0.113.5", "203.0.113.5" },
+		{ "127.0.0.1:4321", "", "127.0.0.1" },
+		{ "198.51.100.7:4321", "203.0.113.5", "198.51.100.7" },
+		{ "127.0.0.2:4321", "203.0.113.5", "127.0.0.2" },
+		{ "[2001:db8::1]:80", "203.0.113.5", "2001:db8::1" },
+	} {
+		if ip := api.ClientIP(c.remoteAddr, c.realIP, trusted); ip != c.expect {
+			t.Errorf("ClientIP(%q, %q) = %q, expect %q", c.remoteAddr, c.realIP, ip, c.expect)
+		}
+	}
+	for _, s := range []string{"localhost", "10.0.0.0/33"} {
+		if _, err := api.ParseTrustedProxies(s); err == nil {
+			t.Errorf("Expect an error when parsing %q", s)
+		}
+	}
+	if nets, err := api.ParseTrustedProxies(api.DefaultTrustedProxies); err != nil || len(nets) != 6 {
+		t.Errorf("Cannot parse the default trusted proxies: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+
+package api
+
+import (
+	"io"
+	"sync"
+)
+
+// SeekReaderAt implements io.ReaderAt with an io.ReadSeeker, so a file can be read randomly without loading it into memory.
+// The reads are serialized since they share the offset of the underlying reader
+type SeekReaderAt struct{
+	mux sync.Mutex
+	r   io.ReadSeeker
+}
+
+var _ io.ReaderAt = (*SeekReaderAt)(nil)
+
+// NewReaderAt returns r itself if it implements io.ReaderAt, or wraps it with a SeekReaderAt.
+// The size of the reader is returned as well
+func NewReaderAt(r io.ReadSeeker)(ra io.ReaderAt, size int64, err error){
+	if size, err = r.Seek(0, io.SeekEnd); err != nil {
+		return
+	}
+	if ra, ok := r.(io.ReaderAt); ok {
+		return ra, size, nil
+	}
+	return &SeekReaderAt{ r: r }, size, nil
+}
+
+func (s *SeekReaderAt)ReadAt(buf []byte, off int64)(n int, err error){
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, err = s.r.Seek(off, io.SeekStart); err != nil {
+		return
+	}
+	if n, err = io.ReadFull(s.r, buf); err == io.ErrUnexpectedEOF {
+		// reached the end of the file
+		err = io.EOF
+	}
+	return
+}
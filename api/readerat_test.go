@@ -0,0 +1,46 @@
+package api_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+// onlySeeker hides the ReadAt method of the reader
+type onlySeeker struct{
+	io.ReadSeeker
+}
+
+func TestNewReaderAt(t *testing.T){
+	const data = "0123456789"
+	sr := strings.NewReader(data)
+	if ra, size, err := api.NewReaderAt(sr); err != nil || ra != io.ReaderAt(sr) || size != 10 {
+		t.Errorf("Expect the reader itself with size 10, got %T, %d: %v", ra, size, err)
+	}
+	ra, size, err := api.NewReaderAt(onlySeeker{ strings.NewReader(data) })
+	if err != nil || size != 10 {
+		t.Fatalf("Unexpected size %d: %v", size, err)
+	}
+	if _, ok := ra.(*api.SeekReaderAt); !ok {
+		t.Fatalf("Expect a SeekReaderAt, got %T", ra)
+	}
+	for _, c := range []struct{
+		off int64
+		n int
+		expect string
+		err error
+	}{
+		{ 5, 3, "567", nil },
+		{ 0, 2, "01", nil },
+		{ 8, 4, "89", io.EOF },
+		{ 10, 1, "", io.EOF },
+	} {
+		buf := make([]byte, c.n)
+		n, err := ra.ReadAt(buf, c.off)
+		if string(buf[:n]) != c.expect || err != c.err {
+			t.Errorf("ReadAt(%d, %d) = %q, %v; expect %q, %v", c.off, c.n, buf[:n], err, c.expect, c.err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+
+package api
+
+import (
+	"encoding/xml"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SitemapPageSize is the max count of the urls in a sitemap, the sitemap index is used if there are more
+const SitemapPageSize = 10000
+
+// SitemapLangs are the languages of the frontend, which is selected by the url param `lang`
+var SitemapLangs = []string{"en_us", "zh_cn"}
+
+// SitemapPlugin is the information of a plugin for building the sitemap
+type SitemapPlugin struct{
+	Id          string
+	Authors     []string
+	LastUpdate  time.Time
+	LastRelease *time.Time
+}
+
+type SitemapURL struct{
+	Loc     string
+	LastMod time.Time
+}
+
+// BuildSitemap returns the urls of the plugin list, the plugin pages, the release pages and the author pages
+func BuildSitemap(site string, plugins []*SitemapPlugin)(urls []*SitemapURL){
+	var latest time.Time
+	authors := make(map[string]time.Time)
+	pages := make([]*SitemapURL, 0, len(plugins) * 2)
+	for _, p := range plugins {
+		if p.LastUpdate.After(latest) {
+			latest = p.LastUpdate
+		}
+		page := site + "/plugin/" + url.PathEscape(p.Id) + "/"
+		pages = append(pages, &SitemapURL{ Loc: page, LastMod: p.LastUpdate })
+		if p.LastRelease != nil {
+			pages = append(pages, &SitemapURL{ Loc: page + "?i=releases", LastMod: *p.LastRelease })
+		}
+		for _, a := range p.Authors {
+			if len(a) == 0 {
+				continue
+			}
+			if t, ok := authors[a]; !ok || p.LastUpdate.After(t) {
+				authors[a] = p.LastUpdate
+			}
+		}
+	}
+	names := make([]string, 0, len(authors))
+	for a := range authors {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+
+	urls = make([]*SitemapURL, 0, len(pages) + len(names) + 1)
+	urls = append(urls, &SitemapURL{ Loc: site + "/plugins", LastMod: latest })
+	urls = append(urls, pages...)
+	for _, a := range names {
+		urls = append(urls, &SitemapURL{ Loc: site + "/author/" + url.PathEscape(a), LastMod: authors[a] })
+	}
+	return
+}
+
+// SitemapPages returns the count of the sitemaps for the urls
+func SitemapPages(n int)(int){
+	return (n + SitemapPageSize - 1) / SitemapPageSize
+}
+
+// SitemapPage returns the urls in the page, the page starts from 1
+func SitemapPage(urls []*SitemapURL, page int)([]*SitemapURL){
+	start := (page - 1) * SitemapPageSize
+	if page <= 0 || start >= len(urls) {
+		return nil
+	}
+	end := start + SitemapPageSize
+	if end > len(urls) {
+		end = len(urls)
+	}
+	return urls[start:end]
+}
+
+// LatestSitemapMod returns the latest modify time of the urls
+func LatestSitemapMod(urls []*SitemapURL)(latest time.Time){
+	for _, u := range urls {
+		if u.LastMod.After(latest) {
+			latest = u.LastMod
+		}
+	}
+	return
+}
+
+// langURL appends the url param `lang` to the url
+func langURL(loc string, lang string)(string){
+	if strings.Contains(loc, "?") {
+		return loc + "&lang=" + lang
+	}
+	return loc + "?lang=" + lang
+}
+
+// hreflang converts the language to the format of BCP 47, e.g. `zh_cn` to `zh-CN`
+func hreflang(lang string)(string){
+	if i := strings.IndexByte(lang, '_'); i >= 0 {
+		return lang[:i] + "-" + strings.ToUpper(lang[i + 1:])
+	}
+	return lang
+}
+
+func sitemapTime(t time.Time)(string){
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type sitemapLink struct{
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+type sitemapURL struct{
+	Loc     string        `xml:"loc"`
+	LastMod string        `xml:"lastmod,omitempty"`
+	Links   []sitemapLink `xml:"xhtml:link"`
+}
+
+type sitemapURLSet struct{
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	XhtmlNS string       `xml:"xmlns:xhtml,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// MarshalSitemap renders the urls as a sitemap, with the alternate urls of each language
+func MarshalSitemap(urls []*SitemapURL)([]byte, error){
+	set := sitemapURLSet{
+		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		XhtmlNS: "http://www.w3.org/1999/xhtml",
+		URLs: make([]sitemapURL, len(urls)),
+	}
+	for i, u := range urls {
+		links := make([]sitemapLink, 0, len(SitemapLangs) + 1)
+		for _, lang := range SitemapLangs {
+			links = append(links, sitemapLink{ Rel: "alternate", Hreflang: hreflang(lang), Href: langURL(u.Loc, lang) })
+		}
+		links = append(links, sitemapLink{ Rel: "alternate", Hreflang: "x-default", Href: u.Loc })
+		set.URLs[i] = sitemapURL{
+			Loc: u.Loc,
+			LastMod: sitemapTime(u.LastMod),
+			Links: links,
+		}
+	}
+	return marshalXML(set)
+}
+
+type sitemapRef struct{
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct{
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+// MarshalSitemapIndex renders the sitemap index, locs are the urls of the sitemaps and mods are their modify times
+func MarshalSitemapIndex(locs []string, mods []time.Time)([]byte, error){
+	index := sitemapIndex{
+		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		Sitemaps: make([]sitemapRef, len(locs)),
+	}
+	for i, loc := range locs {
+		index.Sitemaps[i] = sitemapRef{ Loc: loc, LastMod: sitemapTime(mods[i]) }
+	}
+	return marshalXML(index)
+}
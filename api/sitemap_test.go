@@ -0,0 +1,107 @@
+package api_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestBuildSitemap(t *testing.T){
+	t1 := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	t2 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	release := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	urls := api.BuildSitemap("https://example.com", []*api.SitemapPlugin{
+		{ Id: "bar", Authors: []string{"bob", "alice"}, LastUpdate: t1, LastRelease: &release },
+		{ Id: "foo", Authors: []string{"alice"}, LastUpdate: t2 },
+	})
+	want := []struct{
+		loc string
+		mod time.Time
+	}{
+		{"https://example.com/plugins", t2},
+		{"https://example.com/plugin/bar/", t1},
+		{"https://example.com/plugin/bar/?i=releases", release},
+		{"https://example.com/plugin/foo/", t2},
+		{"https://example.com/author/alice", t2},
+		{"https://example.com/author/bob", t1},
+	}
+	if len(urls) != len(want) {
+		t.Fatalf("Got %d urls, expect %d", len(urls), len(want))
+	}
+	for i, w := range want {
+		if urls[i].Loc != w.loc || !urls[i].LastMod.Equal(w.mod) {
+			t.Errorf("Url %d is %s %v, expect %s %v", i, urls[i].Loc, urls[i].LastMod, w.loc, w.mod)
+		}
+	}
+	if latest := api.LatestSitemapMod(urls); !latest.Equal(t2) {
+		t.Errorf("Latest modify time is %v, expect %v", latest, t2)
+	}
+}
+
+func TestSitemapPage(t *testing.T){
+	urls := make([]*api.SitemapURL, api.SitemapPageSize + 1)
+	if n := api.SitemapPages(len(urls)); n != 2 {
+		t.Errorf("Page count is %d, expect 2", n)
+	}
+	if n := api.SitemapPages(api.SitemapPageSize); n != 1 {
+		t.Errorf("Page count is %d, expect 1", n)
+	}
+	if n := len(api.SitemapPage(urls, 1)); n != api.SitemapPageSize {
+		t.Errorf("First page has %d urls", n)
+	}
+	if n := len(api.SitemapPage(urls, 2)); n != 1 {
+		t.Errorf("Second page has %d urls", n)
+	}
+	for _, page := range []int{0, -1, 3} {
+		if p := api.SitemapPage(urls, page); p != nil {
+			t.Errorf("Page %d should not exist", page)
+		}
+	}
+}
+
+func TestMarshalSitemap(t *testing.T){
+	data, err := api.MarshalSitemap([]*api.SitemapURL{
+		{ Loc: "https://example.com/plugin/foo/?i=releases", LastMod: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC) },
+		{ Loc: "https://example.com/plugins" },
+	})
+	if err != nil {
+		t.Fatalf("Cannot marshal sitemap: %v", err)
+	}
+	s := (string)(data)
+	for _, want := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">`,
+		`<loc>https://example.com/plugin/foo/?i=releases</loc>`,
+		`<lastmod>2023-05-01T12:00:00Z</lastmod>`,
+		`<xhtml:link rel="alternate" hreflang="en-US" href="https://example.com/plugin/foo/?i=releases&amp;lang=en_us"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="zh-CN" href="https://example.com/plugins?lang=zh_cn"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="x-default" href="https://example.com/plugins"></xhtml:link>`,
+	}{
+		if !strings.Contains(s, want) {
+			t.Errorf("Sitemap does not contain %s:\n%s", want, s)
+		}
+	}
+	if strings.Count(s, "<lastmod>") != 1 {
+		t.Errorf("The empty lastmod should be omitted:\n%s", s)
+	}
+}
+
+func TestMarshalSitemapIndex(t *testing.T){
+	data, err := api.MarshalSitemapIndex(
+		[]string{"https://example.com/v1/sitemap.xml?page=1", "https://example.com/v1/sitemap.xml?page=2"},
+		[]time.Time{time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC), {}})
+	if err != nil {
+		t.Fatalf("Cannot marshal sitemap index: %v", err)
+	}
+	s := (string)(data)
+	for _, want := range []string{
+		`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+		`<loc>https://example.com/v1/sitemap.xml?page=2</loc>`,
+		`<lastmod>2023-05-01T12:00:00Z</lastmod>`,
+	}{
+		if !strings.Contains(s, want) {
+			t.Errorf("Sitemap index does not contain %s:\n%s", want, s)
+		}
+	}
+}
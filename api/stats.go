@@ -0,0 +1,88 @@
+
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+type DownloadStatsOpt struct{
+	Tag         *Version
+	Filename    string // the main file or an asset of the releases, all files are counted if it's empty
+	From        time.Time // inclusive, truncated to day
+	To          time.Time // inclusive, truncated to day
+	Granularity string
+}
+
+type DownloadStat struct{
+	Time  time.Time `json:"time"`
+	Count int64     `json:"count"`
+}
+
+func truncateDay(t time.Time)(time.Time){
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// BucketStart returns the start time of the bucket which contains t
+// Weeks are start at Monday
+func BucketStart(t time.Time, granularity string)(time.Time){
+	t = truncateDay(t)
+	switch granularity {
+	case GranularityWeek:
+		wd := (int)(t.Weekday() + 6) % 7
+		return t.AddDate(0, 0, -wd)
+	case GranularityMonth:
+		return t.AddDate(0, 0, 1 - t.Day())
+	}
+	return t
+}
+
+func nextBucket(t time.Time, granularity string)(time.Time){
+	switch granularity {
+	case GranularityWeek:
+		return t.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+func CheckGranularity(granularity string)(err error){
+	switch granularity {
+	case GranularityDay, GranularityWeek, GranularityMonth:
+		return nil
+	}
+	return fmt.Errorf("Unknown granularity %q, expect one of %q, %q or %q",
+		granularity, GranularityDay, GranularityWeek, GranularityMonth)
+}
+
+// BucketDownloadStats groups daily download counts into buckets by the granularity,
+// empty buckets between from and to will be filled with zero count.
+// The daily stats must be sorted by time
+func BucketDownloadStats(daily []*DownloadStat, from, to time.Time, granularity string)(stats []*DownloadStat){
+	from, to = BucketStart(from, granularity), truncateDay(to)
+	for t := from; !t.After(to); t = nextBucket(t, granularity) {
+		stats = append(stats, &DownloadStat{ Time: t })
+	}
+	i := 0
+	for _, d := range daily {
+		t := BucketStart(d.Time, granularity)
+		for i < len(stats) && stats[i].Time.Before(t) {
+			i++
+		}
+		if i >= len(stats) {
+			break
+		}
+		if stats[i].Time.Equal(t) {
+			stats[i].Count += d.Count
+		}
+	}
+	return
+}
@@ -0,0 +1,55 @@
+
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func date(s string)(time.Time){
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestBucketDownloadStats(t *testing.T){
+	daily := []*api.DownloadStat{
+		{ Time: date("2023-05-01"), Count: 3 }, // Monday
+		{ Time: date("2023-05-02"), Count: 2 },
+		{ Time: date("2023-05-08"), Count: 1 },
+		{ Time: date("2023-06-02"), Count: 4 },
+	}
+	type T struct {
+		G string
+		From, To string
+		Times []string
+		Counts []int64
+	}
+	data := []T{
+		{ api.GranularityDay, "2023-05-01", "2023-05-03",
+			[]string{"2023-05-01", "2023-05-02", "2023-05-03"}, []int64{3, 2, 0} },
+		{ api.GranularityWeek, "2023-05-03", "2023-05-14",
+			[]string{"2023-05-01", "2023-05-08"}, []int64{5, 1} },
+		{ api.GranularityMonth, "2023-05-10", "2023-06-10",
+			[]string{"2023-05-01", "2023-06-01"}, []int64{6, 4} },
+		{ api.GranularityDay, "2023-05-02", "2023-05-02",
+			[]string{"2023-05-02"}, []int64{2} },
+	}
+	for _, d := range data {
+		stats := api.BucketDownloadStats(daily, date(d.From), date(d.To), d.G)
+		if len(stats) != len(d.Times) {
+			t.Errorf("Unexpect bucket count %d for %s [%s, %s], expect %d", len(stats), d.G, d.From, d.To, len(d.Times))
+			continue
+		}
+		for i, s := range stats {
+			if !s.Time.Equal(date(d.Times[i])) || s.Count != d.Counts[i] {
+				t.Errorf("Unexpect bucket %d (%s, %d) for %s [%s, %s], expect (%s, %d)", i,
+					s.Time.Format("2006-01-02"), s.Count, d.G, d.From, d.To, d.Times[i], d.Counts[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+
+package api
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+var ErrStreamClosed = errors.New("ErrStreamClosed")
+
+// StreamFile is a file that is being written by a single writer, e.g. a downloading asset.
+// Multiple readers can read and seek in it concurrently,
+// reading the part that is not written yet will block until the data arrives
+type StreamFile struct{
+	mux  sync.Mutex
+	cond *sync.Cond
+
+	fd      *os.File // the read handle of the file, nil before started
+	size    int64    // the expected size, negative if unknown
+	written int64
+	started bool
+	done    bool
+	err     error
+	refs    int
+}
+
+var _ io.Writer = (*StreamFile)(nil)
+
+// NewStreamFile creates a StreamFile that is not started yet.
+// The writer holds a reference until Finish is called
+func NewStreamFile()(f *StreamFile){
+	f = &StreamFile{
+		size: -1,
+		refs: 1,
+	}
+	f.cond = sync.NewCond(&f.mux)
+	return
+}
+
+// Start sets the read handle and the expected size (negative if unknown) of the file
+func (f *StreamFile)Start(fd *os.File, size int64){
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.fd = fd
+	f.size = size
+	f.started = true
+	f.cond.Broadcast()
+}
+
+// WaitStart blocks until the stream is started or finished,
+// it returns the error if the stream is failed before started
+func (f *StreamFile)WaitStart()(err error){
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for !f.started && !f.done {
+		f.cond.Wait()
+	}
+	if !f.started {
+		return f.err
+	}
+	return nil
+}
+
+// Write notifies the readers that len(buf) bytes are written to the file.
+// The data must be written to the file before calling it
+func (f *StreamFile)Write(buf []byte)(n int, err error){
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.written += (int64)(len(buf))
+	f.cond.Broadcast()
+	return len(buf), nil
+}
+
+// Finish marks the stream as done and releases the writer's reference.
+// If err is nil but the written size does not match the expected size, io.ErrUnexpectedEOF will be set
+func (f *StreamFile)Finish(err error){
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if err == nil && f.started && f.size >= 0 && f.written != f.size {
+		err = io.ErrUnexpectedEOF
+	}
+	if !f.started && err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	f.err = err
+	if err == nil {
+		f.size = f.written
+	}
+	f.done = true
+	f.cond.Broadcast()
+	f.releaseLocked()
+}
+
+func (f *StreamFile)releaseLocked(){
+	f.refs--
+	if f.refs == 0 && f.fd != nil {
+		f.fd.Close()
+		f.fd = nil
+	}
+}
+
+// NewReader returns a reader from the start of the file.
+// It must be called before Finish or while another reader is opened, the reader should be closed after used
+func (f *StreamFile)NewReader()(r *StreamReader){
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.refs++
+	return &StreamReader{ f: f }
+}
+
+type StreamReader struct{
+	f      *StreamFile
+	pos    int64
+	closed bool
+}
+
+var _ io.ReadSeekCloser = (*StreamReader)(nil)
+
+func (r *StreamReader)Read(buf []byte)(n int, err error){
+	if r.closed {
+		return 0, ErrStreamClosed
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	f := r.f
+	f.mux.Lock()
+	for !f.done && (!f.started || r.pos >= f.written) {
+		f.cond.Wait()
+	}
+	avail := f.written - r.pos
+	fd, ferr := f.fd, f.err
+	f.mux.Unlock()
+	if avail <= 0 {
+		if ferr != nil {
+			return 0, ferr
+		}
+		return 0, io.EOF
+	}
+	if (int64)(len(buf)) > avail {
+		buf = buf[:avail]
+	}
+	n, err = fd.ReadAt(buf, r.pos)
+	r.pos += (int64)(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+// Seek sets the offset of the reader.
+// Seeking relative to the end will block until the size is known
+func (r *StreamReader)Seek(offset int64, whence int)(int64, error){
+	if r.closed {
+		return 0, ErrStreamClosed
+	}
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		f := r.f
+		f.mux.Lock()
+		for !f.done && f.size < 0 {
+			f.cond.Wait()
+		}
+		size, err := f.size, f.err
+		f.mux.Unlock()
+		if size < 0 {
+			return 0, err
+		}
+		pos = size + offset
+	default:
+		return 0, errors.New("StreamReader.Seek: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("StreamReader.Seek: negative position")
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *StreamReader)Close()(error){
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.f.mux.Lock()
+	defer r.f.mux.Unlock()
+	r.f.releaseLocked()
+	return nil
+}
@@ -0,0 +1,95 @@
+package api_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func startStreamFile(t *testing.T, size int64)(sf *api.StreamFile, wfd *os.File){
+	name := filepath.Join(t.TempDir(), "stream")
+	wfd, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("Cannot create file: %v", err)
+	}
+	t.Cleanup(func(){ wfd.Close() })
+	rfd, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Cannot open file: %v", err)
+	}
+	sf = api.NewStreamFile()
+	sf.Start(rfd, size)
+	return
+}
+
+func TestStreamFile(t *testing.T){
+	const data = "0123456789abcdefghij"
+	sf, wfd := startStreamFile(t, (int64)(len(data)))
+	r1 := sf.NewReader()
+	r2 := sf.NewReader()
+	defer r1.Close()
+	defer r2.Close()
+
+	go func(){
+		for i := 0; i < len(data); i += 5 {
+			time.Sleep(time.Millisecond * 5)
+			io.MultiWriter(wfd, sf).Write(([]byte)(data[i:i + 5]))
+		}
+		sf.Finish(nil)
+	}()
+
+	// the size is known before the data is written
+	if n, err := r2.Seek(0, io.SeekEnd); err != nil || n != (int64)(len(data)) {
+		t.Fatalf("Seek end returned (%d, %v)", n, err)
+	}
+	if _, err := r2.Seek(12, io.SeekStart); err != nil {
+		t.Fatalf("Cannot seek: %v", err)
+	}
+	buf, err := io.ReadAll(r2)
+	if err != nil {
+		t.Fatalf("Cannot read from offset: %v", err)
+	}
+	if string(buf) != data[12:] {
+		t.Errorf("Read %q from offset, expect %q", buf, data[12:])
+	}
+	if buf, err = io.ReadAll(r1); err != nil {
+		t.Fatalf("Cannot read: %v", err)
+	}
+	if string(buf) != data {
+		t.Errorf("Read %q, expect %q", buf, data)
+	}
+}
+
+func TestStreamFileFailed(t *testing.T){
+	sf, wfd := startStreamFile(t, 10)
+	r := sf.NewReader()
+	defer r.Close()
+	io.MultiWriter(wfd, sf).Write(([]byte)("01234"))
+	sf.Finish(nil)
+	buf, err := io.ReadAll(r)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expect ErrUnexpectedEOF, got %v", err)
+	}
+	if string(buf) != "01234" {
+		t.Errorf("Read %q, expect %q", buf, "01234")
+	}
+}
+
+func TestStreamFileNotStarted(t *testing.T){
+	sf := api.NewStreamFile()
+	r := sf.NewReader()
+	defer r.Close()
+	e := errors.New("test error")
+	go sf.Finish(e)
+	if err := sf.WaitStart(); err != e {
+		t.Errorf("Expect %v, got %v", e, err)
+	}
+	if _, err := io.ReadAll(r); err != e {
+		t.Errorf("Expect %v, got %v", e, err)
+	}
+}
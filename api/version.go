@@ -300,13 +300,13 @@ func (v VersionCond)Value()(driver.Value, error){
 func (vc VersionCond)IsMatch(v Version)(bool){
 	switch vc.Cond {
 	case LE:
-		return !v.Less(vc.Ver)
-	case GE:
 		return !vc.Ver.Less(v)
+	case GE:
+		return !v.Less(vc.Ver)
 	case LT:
-		return vc.Ver.Less(v)
-	case GT:
 		return v.Less(vc.Ver)
+	case GT:
+		return vc.Ver.Less(v)
 	case EQ:
 		return vc.Ver.Equal(v)
 	case EX:
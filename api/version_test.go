@@ -85,3 +85,42 @@ func TestVersionToString(t *testing.T){
 		}
 	}
 }
+
+func TestVersionCondListIsMatch(t *testing.T){
+	type T struct {
+		C string
+		V string
+		M bool
+	}
+	data := []T{
+		{ ">=1.0.0", "1.0.0", true },
+		{ ">=1.0.0", "1.2.0", true },
+		{ ">=1.0.0", "0.9.0", false },
+		{ ">1.0.0", "1.0.0", false },
+		{ ">1.0.0", "1.0.1", true },
+		{ "<=1.0.0", "1.0.0", true },
+		{ "<=1.0.0", "1.0.1", false },
+		{ "<2.0.0", "1.9.9", true },
+		{ "<2.0.0", "2.0.0", false },
+		{ "=1.2.x", "1.2.5", true },
+		{ "^1.2.0", "1.9.0", true },
+		{ "^1.2.0", "2.0.0", false },
+		{ "~1.2.0", "1.2.3", true },
+		{ "~1.2.0", "1.3.0", false },
+		{ ">=1.0.0 <2.0.0", "1.5.0", true },
+		{ ">=1.0.0 <2.0.0", "2.0.0", false },
+	}
+	for _, d := range data {
+		c, err := api.VersionCondListFromString(d.C)
+		if err != nil {
+			t.Fatalf("Cannot parse condition %q: %v", d.C, err)
+		}
+		v, err := api.VersionFromString(d.V)
+		if err != nil {
+			t.Fatalf("Cannot parse version %q: %v", d.V, err)
+		}
+		if m := c.IsMatch(v); m != d.M {
+			t.Errorf("Condition %q matching %q is %v, expect %v", d.C, d.V, m, d.M)
+		}
+	}
+}
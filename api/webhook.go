@@ -0,0 +1,222 @@
+
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The catalogue events detected by ghupdater
+const (
+	EventPluginAdded     = "plugin_added"
+	EventPluginRemoved   = "plugin_removed"
+	EventMetadataChanged = "metadata_changed"
+	EventReleaseAdded    = "release_added"
+	EventReleaseStable   = "release_stable"
+)
+
+var CatalogueEventTypes = []string{
+	EventPluginAdded,
+	EventPluginRemoved,
+	EventMetadataChanged,
+	EventReleaseAdded,
+	EventReleaseStable,
+}
+
+func IsCatalogueEventType(typ string)(bool){
+	for _, t := range CatalogueEventTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+type CatalogueEvent struct{
+	Seq    int64     `json:"id"`
+	Type   string    `json:"type"`
+	Plugin string    `json:"plugin"`
+	Tag    string    `json:"tag,omitempty"`
+	Time   time.Time `json:"time"`
+	Data   any       `json:"data,omitempty"`
+}
+
+// ScopeWebhook allows to register webhooks for the catalogue events
+const ScopeWebhook = "webhook"
+
+type Webhook struct{
+	Id       int64     `json:"id"`
+	Url      string    `json:"url"`
+	Events   []string  `json:"events"` // empty means all events
+	Secret   string    `json:"secret,omitempty"` // only returned when the webhook is created
+	CreateAt time.Time `json:"createAt"`
+}
+
+const (
+	DeliveryPending = "pending"
+	DeliveryOk      = "ok"
+	DeliveryFailed  = "failed"
+)
+
+type WebhookDelivery struct{
+	Id          int64      `json:"id"`
+	Event       int64      `json:"event"`
+	EventType   string     `json:"eventType"`
+	Plugin      string     `json:"plugin"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastCode    int        `json:"lastCode,omitempty"` // the status code of the last attempt
+	LastError   string     `json:"lastError,omitempty"`
+	NextAttempt *time.Time `json:"nextAttempt,omitempty"`
+	Updated     time.Time  `json:"updated"`
+}
+
+const (
+	WebhookMaxAttempts = 8
+	WebhookTimeout = time.Second * 10
+	WebhookSignatureHeader = "X-PWP-Signature"
+)
+
+// CheckWebhook checks the url and the event types of the webhook
+func CheckWebhook(hook *Webhook)(err error){
+	u, err := url.Parse(hook.Url)
+	if err != nil {
+		return
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("Webhook url %q should be an absolute http or https url", hook.Url)
+	}
+	if len(hook.Url) > 512 {
+		return fmt.Errorf("Webhook url is longer than 512 characters")
+	}
+	// the resolved addresses are checked again when the events are delivered, since DNS records can change
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("Webhook url %q should not point to a non-public address", hook.Url)
+	}
+	if ip := net.ParseIP(host); ip != nil && !IsPublicIP(ip) {
+		return fmt.Errorf("Webhook url %q should not point to a non-public address", hook.Url)
+	}
+	for _, e := range hook.Events {
+		if !IsCatalogueEventType(e) {
+			return fmt.Errorf("Unknown event type %q", e)
+		}
+	}
+	return
+}
+
+var ErrNonPublicAddress = errors.New("Connecting to a non-public address is not allowed")
+
+// nonPublicNets are the reserved networks which are not covered by the methods of net.IP
+var nonPublicNets = func()(nets []*net.IPNet){
+	for _, s := range []string{
+		"0.0.0.0/8",
+		"100.64.0.0/10",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"240.0.0.0/4",
+		"64:ff9b::/96",
+		"64:ff9b:1::/48",
+		"2001:db8::/32",
+	}{
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return
+}()
+
+// IsPublicIP reports whether the ip is a global unicast address,
+// which is not loopback, private, link-local, shared (CGNAT) or otherwise reserved
+func IsPublicIP(ip net.IP)(bool){
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// WebhookDialControl is used as the Control of the dialer of the webhook client,
+// it refuses the connections to the non-public addresses after the host is resolved
+func WebhookDialControl(network, address string, _ syscall.RawConn)(error){
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, address)
+	}
+	return nil
+}
+
+// NewWebhookClient returns the client used to deliver the events, which only connects to the public addresses.
+// The proxies from the env are not used, otherwise the proxy would connect to the address without the check
+func NewWebhookClient()(*http.Client){
+	dialer := &net.Dialer{
+		Timeout: WebhookTimeout,
+		Control: WebhookDialControl,
+	}
+	return &http.Client{
+		Timeout: WebhookTimeout,
+		Transport: &http.Transport{
+			DialContext: dialer.DialContext,
+			TLSHandshakeTimeout: WebhookTimeout,
+			MaxIdleConns: 16,
+			IdleConnTimeout: time.Minute,
+		},
+	}
+}
+
+// GenerateWebhookSecret generates the secret used to sign the payloads of a webhook
+func GenerateWebhookSecret()(string, error){
+	return randomHex(32)
+}
+
+// SignWebhookPayload returns the value of the signature header, which is the hex encoded HMAC-SHA256 of the payload
+func SignWebhookPayload(secret string, payload []byte)(string){
+	mac := hmac.New(sha256.New, ([]byte)(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckWebhookSignature is used by the receivers to verify the signature header
+func CheckWebhookSignature(secret string, payload []byte, signature string)(bool){
+	return hmac.Equal(([]byte)(SignWebhookPayload(secret, payload)), ([]byte)(signature))
+}
+
+// WebhookBackoff returns the delay before the next attempt after the failed attempts
+func WebhookBackoff(attempts int)(time.Duration){
+	if attempts < 1 {
+		return 0
+	}
+	if attempts > 10 {
+		attempts = 10
+	}
+	d := time.Second * 10 << (attempts - 1)
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
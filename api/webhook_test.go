@@ -0,0 +1,131 @@
+package api_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	api "github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestWebhookSignature(t *testing.T){
+	payload := ([]byte)(`{"id":1,"type":"release_added"}`)
+	sig := api.SignWebhookPayload("secret", payload)
+	// echo -n '{"id":1,"type":"release_added"}' | openssl dgst -sha256 -hmac secret
+	const want = "sha256=e8294ef58cef6555dd11fdcf4ff54e6d7984be585059aab72d5392006d1fb85a"
+	if sig != want {
+		t.Fatalf("Signature is %q, expect %q", sig, want)
+	}
+	if !api.CheckWebhookSignature("secret", payload, sig) {
+		t.Errorf("The signature does not match")
+	}
+	if api.CheckWebhookSignature("secret2", payload, sig) {
+		t.Errorf("The signature matches a wrong secret")
+	}
+	if api.CheckWebhookSignature("secret", append(payload, ' '), sig) {
+		t.Errorf("The signature matches a modified payload")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T){
+	for _, c := range []struct{
+		attempts int
+		want time.Duration
+	}{
+		{0, 0},
+		{1, time.Second * 10},
+		{2, time.Second * 20},
+		{5, time.Second * 160},
+		{9, time.Second * 2560},
+		{10, time.Hour},
+		{100, time.Hour},
+	}{
+		if d := api.WebhookBackoff(c.attempts); d != c.want {
+			t.Errorf("Backoff of %d attempts is %v, expect %v", c.attempts, d, c.want)
+		}
+	}
+}
+
+func TestCheckWebhook(t *testing.T){
+	for _, hook := range []*api.Webhook{
+		{ Url: "http://93.184.216.34:8080/hook" },
+		{ Url: "https://example.com/hook", Events: []string{api.EventReleaseAdded, api.EventPluginRemoved} },
+	}{
+		if err := api.CheckWebhook(hook); err != nil {
+			t.Errorf("Unexpected error for %v: %v", hook, err)
+		}
+	}
+	for _, hook := range []*api.Webhook{
+		{ Url: "" },
+		{ Url: "/hook" },
+		{ Url: "ftp://example.com/hook" },
+		{ Url: "http://127.0.0.1:8080/hook" },
+		{ Url: "http://localhost/hook" },
+		{ Url: "http://10.1.2.3/hook" },
+		{ Url: "http://169.254.169.254/latest/meta-data" },
+		{ Url: "http://[::1]/hook" },
+		{ Url: "https://example.com/hook", Events: []string{"release_deleted"} },
+	}{
+		if err := api.CheckWebhook(hook); err == nil {
+			t.Errorf("Expect error for %v", hook)
+		}
+	}
+}
+
+func TestIsPublicIP(t *testing.T){
+	for _, c := range []struct{
+		ip string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+	}{
+		if ok := api.IsPublicIP(net.ParseIP(c.ip)); ok != c.want {
+			t.Errorf("IsPublicIP(%s) is %v, expect %v", c.ip, ok, c.want)
+		}
+	}
+}
+
+func TestWebhookClientRejectsNonPublic(t *testing.T){
+	var hit int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request){
+		atomic.AddInt32(&hit, 1)
+	}))
+	defer srv.Close()
+
+	// the hostname is resolved to the loopback, which cannot be caught when the webhook is registered
+	u := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	for _, target := range []string{srv.URL, u} {
+		res, err := api.NewWebhookClient().Post(target, "application/json", strings.NewReader("{}"))
+		if err == nil {
+			res.Body.Close()
+			t.Errorf("Expect error when delivering to %s", target)
+			continue
+		}
+		if !errors.Is(err, api.ErrNonPublicAddress) {
+			t.Errorf("Unexpected error when delivering to %s: %v", target, err)
+		}
+	}
+	if n := atomic.LoadInt32(&hit); n != 0 {
+		t.Errorf("The server got %d requests, expect none", n)
+	}
+}
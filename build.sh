@@ -93,6 +93,8 @@ if [[ "$WEB_ONLY" != true ]]; then
 	GOARCH=amd64 GOOS=linux build_app || exit $?
 	echo '==> Building ghupdater'
 	GOARCH=amd64 GOOS=linux CGO_ENABLED=0 go build -o ./output/ghupdater ./cmds/ghupdater || exit $?
+	echo '==> Building grpc'
+	GOARCH=amd64 GOOS=linux CGO_ENABLED=0 go build -o ./output/grpc ./cmds/grpc || exit $?
 	GOARCH=amd64 GOOS=linux build_handle dev || exit $?
 	GOARCH=amd64 GOOS=linux build_handle v1 || exit $?
 	echo '==> Done'
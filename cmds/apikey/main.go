@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/kmcsr/go-logger"
+	"github.com/kmcsr/go-logger/logrus"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+var loger logger.Logger = initLogger()
+
+func initLogger()(loger logger.Logger){
+	loger = logrus.Logger
+	if os.Getenv("DEBUG") == "true" {
+		loger.SetLevel(logger.TraceLevel)
+	}else{
+		loger.SetLevel(logger.InfoLevel)
+	}
+	return
+}
+
+func initDB()(DB *sql.DB){
+	username := os.Getenv("DB_USER")
+	passwd := os.Getenv("DB_PASSWD")
+	address := os.Getenv("DB_ADDR")
+	database := os.Getenv("DB_NAME")
+
+	loger.Debugf("Connecting to db %s@%s/%s", username, address, database)
+
+	var err error
+	if DB, err = sql.Open("mysql", fmt.Sprintf("%s:%s@%s/%s?parseTime=true", username, passwd, address, database)); err != nil {
+		loger.Fatalf("Cannot connect to database: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 3)
+	defer cancel()
+	if err = DB.PingContext(ctx); err != nil {
+		loger.Fatalf("Cannot ping to database: %v", err)
+	}
+	return
+}
+
+const usage = `Usage:
+  apikey create -name <name> [-scopes <scope>[,<scope>...]] [-quota <n>]
+  apikey list
+  apikey quota <id> <n>
+  apikey revoke <id>
+
+The database is configured by env DB_USER, DB_PASSWD, DB_ADDR and DB_NAME
+`
+
+func main(){
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = createKey(os.Args[2:])
+	case "list":
+		err = listKeys()
+	case "quota":
+		if len(os.Args) != 4 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		var quota int
+		if quota, err = strconv.Atoi(os.Args[3]); err != nil || quota < 0 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		err = setQuota(os.Args[2], quota)
+	case "revoke":
+		if len(os.Args) != 3 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		err = revokeKey(os.Args[2])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		loger.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+func createKey(args []string)(err error){
+	const insertCmd = "INSERT INTO api_keys (`id`,`name`,`secret_hash`,`scopes`,`quota`) VALUES (?,?,?,?,?)"
+
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	name := fs.String("name", "", "The name of the key, e.g. the owner or the usage")
+	scopes := fs.String("scopes", "", "The comma separated scopes granted to the key, `publish`, `webhook` or `admin`. Read-only key if empty")
+	quota := fs.Int("quota", 0, "The requests allowed per minute, 0 means the default limit of the API server")
+	fs.Parse(args)
+	if len(*name) == 0 {
+		return fmt.Errorf("-name is required")
+	}
+	if *quota < 0 {
+		return fmt.Errorf("-quota cannot be negative")
+	}
+
+	id, secret, token, err := api.GenerateAPIKey()
+	if err != nil {
+		return
+	}
+
+	DB := initDB()
+	defer DB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+	if _, err = DB.ExecContext(ctx, insertCmd, id, *name, api.HashAPISecret(secret), strings.ReplaceAll(*scopes, " ", ""), *quota); err != nil {
+		return
+	}
+	fmt.Printf("Created API key %s for %q\n", id, *name)
+	fmt.Println("The token is shown only once, please keep it safe:")
+	fmt.Println(token)
+	return
+}
+
+func listKeys()(err error){
+	const queryCmd = "SELECT a.`id`,a.`name`,a.`scopes`,a.`quota`,a.`createAt`,a.`lastUsed`,a.`revoked`," +
+		"IFNULL(SUM(IF(u.`day`=CURDATE(),u.`requests`,0)),0),IFNULL(SUM(u.`requests`),0)" +
+		" FROM api_keys AS a LEFT JOIN api_key_usage AS u ON a.`id`=u.`id`" +
+		" GROUP BY a.`id` ORDER BY a.`createAt`"
+
+	DB := initDB()
+	defer DB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var rows *sql.Rows
+	if rows, err = DB.QueryContext(ctx, queryCmd); err != nil {
+		return
+	}
+	defer rows.Close()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tQUOTA\tTODAY\tTOTAL\tCREATED\tLAST USED\tREVOKED")
+	for rows.Next() {
+		var (
+			id, name, scopes string
+			quota int
+			today, total int64
+			createAt time.Time
+			lastUsed sql.NullTime
+			revoked bool
+		)
+		if err = rows.Scan(&id, &name, &scopes, &quota, &createAt, &lastUsed, &revoked, &today, &total); err != nil {
+			return
+		}
+		used := "never"
+		if lastUsed.Valid {
+			used = lastUsed.Time.Format("2006-01-02 15:04:05")
+		}
+		quotaStr := "default"
+		if quota > 0 {
+			quotaStr = strconv.Itoa(quota) + "/min"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%v\n", id, name, scopes, quotaStr, today, total,
+			createAt.Format("2006-01-02 15:04:05"), used, revoked)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return w.Flush()
+}
+
+func setQuota(id string, quota int)(err error){
+	const updateCmd = "UPDATE api_keys SET `quota`=? WHERE `id`=?"
+
+	DB := initDB()
+	defer DB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var res sql.Result
+	if res, err = DB.ExecContext(ctx, updateCmd, quota, id); err != nil {
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("API key %s does not exist or the quota is not changed", id)
+	}
+	fmt.Printf("Set the quota of API key %s to %d, it takes effect within a minute\n", id, quota)
+	return
+}
+
+func revokeKey(id string)(err error){
+	const updateCmd = "UPDATE api_keys SET `revoked`=TRUE WHERE `id`=?"
+
+	DB := initDB()
+	defer DB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	defer cancel()
+
+	var res sql.Result
+	if res, err = DB.ExecContext(ctx, updateCmd, id); err != nil {
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("API key %s does not exist or is already revoked", id)
+	}
+	fmt.Printf("Revoked API key %s\n", id)
+	return
+}
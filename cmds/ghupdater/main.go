@@ -6,27 +6,21 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
-	"regexp"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/kmcsr/go-logger"
 	"github.com/kmcsr/go-logger/logrus"
 	"github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api/ghsync"
 )
 
-var packageNameRe = regexp.MustCompile(`^[a-zA-Z_.-]+[a-zA-Z0-9_.-]*`)
-
 var loger logger.Logger = initLogger()
 
 func initLogger()(loger logger.Logger){
@@ -39,6 +33,7 @@ func initLogger()(loger logger.Logger){
 			panic(err)
 		}
 	}
+	ghsync.SetLogger(loger)
 	return
 }
 
@@ -81,262 +76,99 @@ func getDBConn(ctx context.Context)(conn *sql.Conn, err error){
 	return
 }
 
-var (
-	target string = "https://github.com/MCDReforged/PluginCatalogue"
-	targetRaw string = "https://raw.githubusercontent.com/MCDReforged/PluginCatalogue/" // meta/{{plugin_id}}/meta.json
-)
-
-type Author struct{
-	Name string `json:"name"`
-	Link string `json:"link"`
-}
-
-type Labels []string
-
-func (l Labels)HasInformation()(bool){
-	for _, a := range l {
-		if a == "information" {
-			return true
-		}
-	}
-	return false
-}
-
-func (l Labels)HasTool()(bool){
-	for _, a := range l {
-		if a == "tool" {
-			return true
-		}
-	}
-	return false
-}
-
-func (l Labels)HasManagement()(bool){
-	for _, a := range l {
-		if a == "management" {
-			return true
-		}
-	}
-	return false
-}
-
-func (l Labels)HasAPI()(bool){
-	for _, a := range l {
-		if a == "api" {
-			return true
-		}
-	}
-	return false
-}
-
-type PluginInfo struct{
-	Disable bool `json:"disable"`
-	Id string `json:"id"`
-	Authors []Author `json:"authors"`
-	Repo string `json:"repository"`
-	Branch string `json:"branch"`
-	RelatedPath string `json:"related_path"`
-	Labels Labels `json:"labels"`
-}
-
-type DependMap = map[string]api.VersionCondList
-type Requirements = []string
-
-type PluginMeta struct{
-	Id string `json:"id"`
-	Name string `json:"name"`
-	Version string `json:"version"`
-	Repo string `json:"repository"`
-	Branch string `json:"branch"`
-	RelatedPath string `json:"related_path"`
-	Authors []string `json:"authors"`
-	Deps DependMap `json:"dependencies"`
-	Reqs Requirements `json:"requirements"`
-	Desc any `json:"description"`
-}
-
-type Asset struct{
-	Name string `json:"name"`
-	Size int64 `json:"size"`
-	DownloadCount int64 `json:"download_count"`
-	Url string `json:"url"`
-	CreateAt time.Time `json:"created_at"`
-	BrowserDownloadUrl string `json:"browser_download_url"`
-}
 
-type Release struct{
-	Name string `json:"name"`
-	TagName string `json:"tag_name"`
-	CreateAt time.Time `json:"created_at"`
-	Assets []Asset `json:"assets"`
-	Description string `json:"description"`
-	Prerelease bool `json:"prerelease"`
-	ParsedVersion string `json:"parsed_version"`
-	Meta any `json:"meta"`
-}
-
-const CurrenReleaseSchemaVersion = 7
-type PluginRelease struct{
-	SchemaVersion int `json:"schema_version"`
-	Id string `json:"id"`
-	LatestVersion string `json:"latest_version"`
-	Releases []Release `json:"releases"`
-}
+// updateScores calculates the time-decayed popularity scores with the download snapshots and
+// the downloads which served by ourselves, and removes the snapshots that out of the window
+func updateScores(id string, now time.Time)(err error){
+	const querySnapshotsCmd = "SELECT a.`tag`,a.`time`,a.`downloads`,b.`uploaded`" +
+		" FROM plugin_release_snapshots AS a JOIN plugin_releases AS b" +
+		" ON a.`id`=b.`id` AND a.`tag`=b.`tag`" +
+		" WHERE a.`id`=? AND a.`time`>=?" +
+		" ORDER BY a.`tag`,a.`time`"
+	// only the main files are counted, the same as the snapshots
+	const queryLocalCmd = "SELECT c.`day`,SUM(c.`count`)" +
+		" FROM plugin_downloads AS c JOIN plugin_releases AS r" +
+		" ON r.`id`=c.`id` AND r.`tag`=c.`tag` AND r.`filename`=c.`filename`" +
+		" WHERE c.`id`=? AND c.`day`>=?" +
+		" GROUP BY c.`day`"
+	const updateCmd = "UPDATE plugins SET `score_trending`=?,`score_popular`=? WHERE `id`=?"
+	const pruneCmd = "DELETE FROM plugin_release_snapshots WHERE `id`=? AND `time`<?"
 
-func GetPluginMetaJson(id string)(meta PluginMeta, err error){
-	p, err := url.JoinPath(targetRaw, "meta", id, "meta.json")
-	if err != nil {
-		return
-	}
-	loger.Infof("Getting %q", p)
-	resp, err := http.DefaultClient.Get(p)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return
-	}
-	if err = json.Unmarshal(body, &meta); err != nil {
-		return
-	}
-	return
-}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 10)
+	defer cancel()
 
-func GetPluginReleaseJson(id string)(meta PluginRelease, err error){
-	p, err := url.JoinPath(targetRaw, "meta", id, "release.json")
-	if err != nil {
-		return
-	}
-	loger.Infof("Getting %q", p)
-	resp, err := http.DefaultClient.Get(p)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	conn, err := getDBConn(ctx)
 	if err != nil {
 		return
 	}
-	if err = json.Unmarshal(body, &meta); err != nil {
-		return
-	}
-	if meta.SchemaVersion != CurrenReleaseSchemaVersion {
-		err = fmt.Errorf("Unexpect schema version %d, expect %d", meta.SchemaVersion, CurrenReleaseSchemaVersion)
-		return
-	}
-	return
-}
-
-func ExecTx(tx *sql.Tx, cmd string, args ...any)(res sql.Result, err error){
-	loger.Debugf("Exec sql cmd: %s\n  args: %v", cmd, args)
-	for {
-		if res, err = tx.Exec(cmd, args...); err != nil {
-			if e, ok := err.(*mysql.MySQLError); ok {
-				switch e.Number {
-				case 1213:
-					continue
-				}
-			}
-		}
-		return
-	}
-}
+	defer conn.Close()
 
-func updateSql(info PluginInfo, meta PluginMeta, releases PluginRelease)(err error){
-	const queryGhSyncCmd = "SELECT `github_sync`" +
-		" FROM plugins WHERE `id`=?"
-	const insertCmd = "INSERT INTO plugins (`id`,`name`,`enabled`,`version`,`authors`,`desc`,`desc_zhCN`," +
-		"`repo`,`repo_branch`,`repo_subdir`,`link`," +
-		"`label_information`,`label_tool`,`label_management`,`label_api`," +
-		"`createAt`,`lastRelease`,`github_sync`,`ghRepoOwner`,`ghRepoName`,`last_sync`)" +
-		" VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,TRUE,?,?,?)"
-	const updateCmd = "UPDATE plugins SET " +
-			"`name`=?," +
-			"`enabled`=?," +
-			"`version`=?," +
-			"`authors`=?," +
-			"`desc`=?," +
-			"`desc_zhCN`=?," +
-			"`repo`=?," +
-			"`repo_branch`=?," +
-			"`repo_subdir`=?," +
-			"`link`=?," +
-			"`label_information`=?," +
-			"`label_tool`=?," +
-			"`label_management`=?," +
-			"`label_api`=?," +
-			"`lastRelease`=?," +
-			"`ghRepoOwner`=?," +
-			"`ghRepoName`=?," +
-			"`last_sync`=?" +
-			" WHERE `id`=?"
-	const removeDepenceCmd = "DELETE FROM plugin_dependencies WHERE `id`=?"
-	const insertDepenceCmd = "INSERT INTO plugin_dependencies (`id`,`target`,`tag`)" +
-		" VALUES (?,?,?)"
-	const removeRequireCmd = "DELETE FROM plugin_requirements WHERE `id`=?"
-	const insertRequireCmd = "INSERT INTO plugin_requirements (`id`,`target`,`tag`)" +
-		" VALUES (?,?,?)"
-	const insertReleaseCmd = "REPLACE INTO plugin_releases (`id`,`tag`,`enabled`,`stable`,`size`,`uploaded`,`filename`,`downloads`," +
-		"`github_url`)" +
-		" VALUES (?,?,TRUE,?,?,?,?,?,?)"
-
-	nowt := time.Now()
-	now := nowt.Format("2006-01-02 15:04:05")
-
-	sort.Strings(meta.Authors)
-	var (
-		desc string
-		desc_zhCN string
-	)
-	if d, ok := meta.Desc.(string); ok {
-		desc = d
-	}else if m, ok := meta.Desc.(map[string]any); ok {
-		if d, ok := m["en_us"].(string); ok {
-			desc = d
-		}
-		if d, ok := m["zh_cn"].(string); ok {
-			desc_zhCN = d
-		}
-	}
-	if !strings.HasPrefix(info.Repo, "https://github.com/") {
-		err = fmt.Errorf("Unexpect repo link (missing gh prefix): %q", info.Repo)
-		return
-	}
-	var lastRelease sql.NullTime
+	windowStart := now.Add(-api.ScoreWindow)
+	var incs []*api.DownloadStat
 	{
-		for _, r := range releases.Releases {
-			t := r.CreateAt
-			if !lastRelease.Valid || t.After(lastRelease.Time) {
-				lastRelease.Valid = true
-				lastRelease.Time = t
+		var rows *sql.Rows
+		if rows, err = conn.QueryContext(ctx, querySnapshotsCmd, id, windowStart); err != nil {
+			return
+		}
+		defer rows.Close()
+		var (
+			lastTag string
+			uploaded time.Time
+			samples []api.DownloadSample
+		)
+		for rows.Next() {
+			var (
+				tag string
+				sample api.DownloadSample
+				upload time.Time
+			)
+			if err = rows.Scan(&tag, &sample.Time, &sample.Downloads, &upload); err != nil {
+				return
+			}
+			if tag != lastTag {
+				incs = append(incs, api.SampleIncrements(uploaded, windowStart, samples)...)
+				lastTag, uploaded, samples = tag, upload, samples[:0]
 			}
+			samples = append(samples, sample)
+		}
+		if err = rows.Err(); err != nil {
+			return
 		}
+		incs = append(incs, api.SampleIncrements(uploaded, windowStart, samples)...)
 	}
-	var ghRepoOwner, ghRepoName string
 	{
-		b := info.Repo[len("https://github.com/"):]
-		if b[len(b) - 1] == '/' {
-			b = b[:len(b) - 1]
-		}
-		paths := strings.Split(b, "/")
-		if len(paths) <= 1 {
-			err = fmt.Errorf("Unexpect repo link (missing repo name): %q, expect 'https://github.com/{owner}/{name}'", info.Repo)
+		var rows *sql.Rows
+		if rows, err = conn.QueryContext(ctx, queryLocalCmd, id, windowStart.Format("2006-01-02")); err != nil {
 			return
 		}
-		if len(paths) > 2 {
-			err = fmt.Errorf("Unexpect repo link (extra path): %q, expect 'https://github.com/{owner}/{name}'", info.Repo)
+		defer rows.Close()
+		for rows.Next() {
+			var inc api.DownloadStat
+			if err = rows.Scan(&inc.Time, &inc.Count); err != nil {
+				return
+			}
+			incs = append(incs, &inc)
+		}
+		if err = rows.Err(); err != nil {
 			return
 		}
-		ghRepoOwner, ghRepoName = paths[0], paths[1]
 	}
-	link, err := url.JoinPath(info.Repo, "tree", info.Branch, info.RelatedPath)
-	if err != nil {
+	trending := api.DecayedScore(incs, now, api.TrendingHalfLife)
+	popular := api.DecayedScore(incs, now, api.PopularHalfLife)
+	loger.Debugf("[%s] Scores: trending=%.2f popular=%.2f", id, trending, popular)
+	if _, err = conn.ExecContext(ctx, updateCmd, trending, popular, id); err != nil {
 		return
 	}
+	if _, err = conn.ExecContext(ctx, pruneCmd, id, windowStart); err != nil {
+		return
+	}
+	return
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 10)
+func getAllPlugins()(plugins []string, err error){
+	const queryCmd = "SELECT `id` FROM plugins"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
 	defer cancel()
 
 	conn, err := getDBConn(ctx)
@@ -345,77 +177,19 @@ func updateSql(info PluginInfo, meta PluginMeta, releases PluginRelease)(err err
 	}
 	defer conn.Close()
 
-	var flag sql.NullBool
-	if err = conn.QueryRowContext(ctx, queryGhSyncCmd, info.Id).Scan(&flag); err != nil && err != sql.ErrNoRows {
-		return
-	}
-	if flag.Valid && !flag.Bool {
-		loger.Debugf("Plugin %s is not synced from github", info.Id)
-		return
-	}
-
-	tx, err := conn.BeginTx(ctx, nil)
-	if err != nil {
+	var rows *sql.Rows
+	if rows, err = conn.QueryContext(ctx, queryCmd); err != nil {
 		return
 	}
-	defer tx.Rollback()
-
-	if flag.Valid {
-		loger.Infof("[%s] Updating metadata", info.Id)
-		if _, err = ExecTx(tx, updateCmd, meta.Name, !info.Disable, meta.Version,
-			strings.Join(meta.Authors, ","), desc, desc_zhCN,
-			info.Repo, info.Branch, info.RelatedPath, link,
-			info.Labels.HasInformation(), info.Labels.HasTool(), info.Labels.HasManagement(), info.Labels.HasAPI(),
-			lastRelease, ghRepoOwner, ghRepoName, now, info.Id); err != nil {
-			return
-		}
-		if _, err = ExecTx(tx, removeDepenceCmd, info.Id); err != nil {
-			return
-		}
-		if _, err = ExecTx(tx, removeRequireCmd, info.Id); err != nil {
-			return
-		}
-	}else{
-		loger.Infof("[%s] Insert into database", info.Id)
-		if _, err = ExecTx(tx, insertCmd, info.Id, meta.Name, !info.Disable, meta.Version,
-			strings.Join(meta.Authors, ","), desc, desc_zhCN,
-			info.Repo, info.Branch, info.RelatedPath, link,
-			info.Labels.HasInformation(), info.Labels.HasTool(), info.Labels.HasManagement(), info.Labels.HasAPI(),
-			now, lastRelease, ghRepoOwner, ghRepoName, now); err != nil {
-			return
-		}
-	}
-	for id, cond := range meta.Deps {
-		if _, err = ExecTx(tx, insertDepenceCmd, info.Id, id, cond); err != nil {
-			return
-		}
-	}
-	for _, req := range meta.Reqs {
-		loger.Debugf("Parsing requirement %q", req)
-		id := packageNameRe.FindString(req)
-		if len(id) == 0 {
-			loger.Warnf("Cannot parse python package requirement: %q", req)
-			continue
-		}
-		cond := strings.ReplaceAll(req[len(id):], " ", "")
-		if _, err = ExecTx(tx, insertRequireCmd, info.Id, id, cond); err != nil {
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
 			return
 		}
+		plugins = append(plugins, id)
 	}
-	for _, release := range releases.Releases {
-		for _, asset := range release.Assets {
-			if strings.HasSuffix(asset.Name, ".mcdr") {
-				loger.Debugf("inserting asset: %v", asset)
-				if _, err = ExecTx(tx, insertReleaseCmd, info.Id, release.ParsedVersion, release.Prerelease,
-					asset.Size, asset.CreateAt, asset.Name, asset.DownloadCount, asset.BrowserDownloadUrl); err != nil {
-					// loger.Errorf("Error when insert release into sql")
-					return
-				}
-				break
-			}
-		}
-	}
-	if err = tx.Commit(); err != nil {
+	if err = rows.Err(); err != nil {
 		return
 	}
 	return
@@ -470,7 +244,16 @@ func deletePlugin(plugin string)(err error){
 	}
 	defer tx.Rollback()
 
-	if _, err = ExecTx(tx, deleteCmd, plugin); err != nil {
+	if _, err = ghsync.ExecTx(tx, deleteCmd, plugin); err != nil {
+		return
+	}
+	if err = ghsync.RecordEvent(tx, &api.CatalogueEvent{
+		Type: api.EventPluginRemoved,
+		Plugin: plugin,
+	}); err != nil {
+		return
+	}
+	if err = ghsync.RecordChanges(ctx, tx, plugin); err != nil {
 		return
 	}
 
@@ -480,6 +263,17 @@ func deletePlugin(plugin string)(err error){
 	return
 }
 
+// webhookMaxWait is how long to wait for retrying the failed webhook deliveries in one run
+const webhookMaxWait = time.Minute * 10
+
+func deliverWebhooks(){
+	ctx, cancel := context.WithTimeout(context.Background(), webhookMaxWait + time.Minute)
+	defer cancel()
+	if err := ghsync.DeliverWebhooks(ctx, DB, webhookMaxWait); err != nil {
+		loger.Errorf("Cannot deliver webhooks: %v", err)
+	}
+}
+
 func main(){
 	dir, err := os.MkdirTemp("", "gh_sync")
 	if err != nil {
@@ -488,13 +282,13 @@ func main(){
 	defer os.RemoveAll(dir)
 	loger.Infof("Temp dir: %s", dir)
 
-	cmd := exec.Command("git", "-C", dir, "clone", target)
+	cmd := exec.Command("git", "-C", dir, "clone", ghsync.Target)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err = cmd.Run(); err != nil {
 		loger.Fatalf("Cannot execute git clone: %v", err)
 	}
-	pluginsPath := filepath.Join(dir, path.Base(target), "plugins")
+	pluginsPath := filepath.Join(dir, path.Base(ghsync.Target), "plugins")
 	pluginsFs, err := os.ReadDir(pluginsPath)
 	if err != nil {
 		loger.Panic(err)
@@ -533,7 +327,7 @@ func main(){
 				loger.Errorf("Cannot read %q: %v", infop, err)
 				continue
 			}
-			var info PluginInfo
+			var info ghsync.PluginInfo
 			if err = json.Unmarshal(infob, &info); err != nil {
 				loger.Errorf("Cannot parse %q: %v", infop, err)
 				continue
@@ -543,23 +337,43 @@ func main(){
 				// continue
 			}
 			wg.Add(1)
-			go func(info PluginInfo){
+			go func(info ghsync.PluginInfo){
 				defer wg.Done()
-				meta, err := GetPluginMetaJson(info.Id)
+				meta, err := ghsync.GetPluginMetaJson(info.Id)
 				if err != nil {
 					loger.Errorf("[%s] Cannot get meta json: %v", info.Id, err)
 					return
 				}
-				releases, err := GetPluginReleaseJson(info.Id)
+				releases, err := ghsync.GetPluginReleaseJson(info.Id)
 				if err != nil {
 					loger.Errorf("[%s] Release json error: %v", info.Id, err)
 					return
 				}
-				if err = updateSql(info, meta, releases); err != nil {
+				if err = ghsync.SyncPlugin(DB, info, meta, releases); err != nil {
 					loger.Errorf("[%s] Cannot sync to database: %v", info.Id, err)
 				}
 			}(info)
 		}
 	}
 	wg.Wait()
+
+	// scores are updated for all plugins, include the plugins which are not synced from github
+	allPlugins, err := getAllPlugins()
+	if err != nil {
+		loger.Panic(err)
+	}
+	now := time.Now()
+	for _, p := range allPlugins {
+		wg.Add(1)
+		go func(p string){
+			defer wg.Done()
+			if err := updateScores(p, now); err != nil {
+				loger.Errorf("[%s] Cannot update scores: %v", p, err)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	// the events are recorded by the syncs above
+	deliverWebhooks()
 }
@@ -0,0 +1,81 @@
+
+package main
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kmcsr/go-logger"
+	"github.com/kmcsr/go-logger/logrus"
+
+	"github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api/grpcapi"
+	"github.com/kmcsr/PluginWebPoint/api/mysqlimpl"
+)
+
+var loger logger.Logger = initLogger()
+
+func initLogger()(loger logger.Logger){
+	loger = logrus.Logger
+	if os.Getenv("DEBUG") == "true" {
+		loger.SetLevel(logger.TraceLevel)
+	}else{
+		loger.SetLevel(logger.InfoLevel)
+		out, err := logger.OutputToFile(loger, "/var/log/pwp/grpc/latest.log", os.Stdout)
+		if err != nil {
+			panic(err)
+		}
+		api.SetLoggerOutput(out)
+		mysqlimpl.SetLoggerOutput1(out)
+	}
+	grpcapi.SetLogger(loger)
+	return
+}
+
+const defaultAddress = "127.0.0.1:3090"
+
+func main(){
+	address := defaultAddress
+	if len(os.Args) >= 2 {
+		address = os.Args[1]
+	}
+
+	username := os.Getenv("DB_USER")
+	passwd := os.Getenv("DB_PASSWD")
+	dbaddress := os.Getenv("DB_ADDR")
+	database := os.Getenv("DB_NAME")
+
+	apiIns := mysqlimpl.NewMySqlAPI(username, passwd, dbaddress, database, nil)
+
+	// the gRPC clients use HTTP/2 without TLS in the internal network
+	server := grpcapi.NewGRPCServer(apiIns)
+
+	done := make(chan struct{}, 0)
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		loger.Fatalf("Error when server listening: %v", err)
+	}
+
+	go func(){
+		defer close(done)
+		loger.Infof("Server listening at %s", listener.Addr().String())
+		if err := server.Serve(listener); err != nil {
+			loger.Fatal(err)
+		}
+	}()
+
+	select {
+	case <-sigch:
+		// the streaming downloads are cut off if they cannot finish in time
+		timer := time.AfterFunc(time.Second * 5, server.Stop)
+		server.GracefulStop()
+		timer.Stop()
+	case <-done:
+	}
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/kmcsr/go-logger"
 	"github.com/kmcsr/go-logger/logrus"
+	"github.com/kmcsr/PluginWebPoint/api"
 )
 
 var loger logger.Logger = initLogger()
@@ -103,20 +104,45 @@ func ProxyOf(prefix string, target string)(http.HandlerFunc){
 			rw.Header()[k] = v
 		}
 		rw.WriteHeader(res.StatusCode)
+		if strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+			copyFlush(rw, res.Body)
+			return
+		}
 		io.Copy(rw, res.Body)
 	}
 }
 
+// copyFlush flushes every chunk of the event stream, so it won't be held by the compressor
+func copyFlush(rw http.ResponseWriter, r io.Reader){
+	flusher, _ := rw.(http.Flusher)
+	buf := make([]byte, 32 * 1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := rw.Write(buf[:n]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 func main(){
 	for prefix, target := range config.APIs {
 		prefix = "/" + prefix + "/"
 		loger.Infof("Proxy %q to %q", prefix, target)
-		http.HandleFunc(prefix, ProxyOf(prefix, target))
+		http.Handle(prefix, api.CompressHandler(ProxyOf(prefix, target)))
 	}
 
-	webProxy := ProxyOf("", config.Web)
-	http.HandleFunc("/assets/", webProxy)
-	http.HandleFunc("/", webProxy)
+	// the responses that are already encoded by the upstreams are passed through
+	webProxy := api.CompressHandler(ProxyOf("", config.Web))
+	http.Handle("/assets/", webProxy)
+	http.Handle("/", webProxy)
 
 	server := &http.Server{
 		Addr: net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
@@ -41,5 +41,6 @@ for platform in "${BUILD_PLATFORMS[@]}"; do
 	build web $platform || exit $?
 	build ghupdater $platform || exit $?
 	build v1 $platform || exit $?
+	build grpc $platform || exit $?
 	build reverse_proxy $platform || exit $?
 done
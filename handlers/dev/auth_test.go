@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+type authTestAPI struct{
+	api.API
+	lookups *int32
+}
+
+func (a authTestAPI)GetAPIKey(token string)(*api.APIKey, error){
+	atomic.AddInt32(a.lookups, 1)
+	return nil, api.ErrUnauthorized
+}
+
+func TestInvalidKeyLimit(t *testing.T){
+	var lookups int32
+	apiIns0, anonRateLimit0, authFailures0 := apiIns, anonRateLimit, authFailures
+	apiIns, anonRateLimit, authFailures = authTestAPI{ lookups: &lookups }, 1000, api.NewRateLimiter(api.RateLimitWindow)
+	defer func(){
+		apiIns, anonRateLimit, authFailures = apiIns0, anonRateLimit0, authFailures0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	do := func(remoteAddr string, header, value string)(int){
+		req := httptest.NewRequest(http.MethodDelete, "/admin/plugin/hello/cache", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(header, value)
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, req)
+		return rw.Code
+	}
+	// both headers of the key are counted by the same limit
+	for i := 0; i < maxAuthFailures + 5; i++ {
+		header, value := "Authorization", "Bearer guess"
+		if i % 2 == 1 {
+			header, value = "X-API-Key", "guess"
+		}
+		code := do("198.51.100.7:1234", header, value)
+		if i < maxAuthFailures && code != http.StatusUnauthorized {
+			t.Errorf("Request %d: expect 401, got %d", i, code)
+		}else if i >= maxAuthFailures && code != http.StatusTooManyRequests {
+			t.Errorf("Request %d: expect 429, got %d", i, code)
+		}
+	}
+	if lookups != maxAuthFailures {
+		t.Errorf("The key is looked up %d times, expect %d", lookups, maxAuthFailures)
+	}
+	if code := do("198.51.100.8:1234", "Authorization", "Bearer guess"); code != http.StatusUnauthorized {
+		t.Errorf("The other IPs should not be limited, got %d", code)
+	}
+}
+
+func TestAnonRateLimit(t *testing.T){
+	anonRateLimit0, rateLimiter0 := anonRateLimit, rateLimiter
+	anonRateLimit, rateLimiter = 2, api.NewRateLimiter(api.RateLimitWindow)
+	defer func(){
+		anonRateLimit, rateLimiter = anonRateLimit0, rateLimiter0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	for i, expect := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rw.Code != expect {
+			t.Errorf("Request %d: expect %d, got %d", i, expect, rw.Code)
+		}
+	}
+}
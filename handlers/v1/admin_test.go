@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+type adminTestAPI struct{
+	cacheTestAPI
+	switched chan bool
+}
+
+func (adminTestAPI)GetAPIKey(token string)(*api.APIKey, error){
+	if token != "admin-token" {
+		return nil, api.ErrUnauthorized
+	}
+	return &api.APIKey{ Id: "admin", Scopes: []string{api.ScopeAdmin} }, nil
+}
+
+func (adminTestAPI)RecordAPIKeyUsage(id string){}
+
+func (a adminTestAPI)SetPluginEnabled(id string, enabled bool)(error){
+	a.switched <- enabled
+	return nil
+}
+
+func (a adminTestAPI)OverrideReleaseStable(id string, tag api.Version, stable bool)(error){
+	a.switched <- stable
+	return nil
+}
+
+func TestAdminSwitchBody(t *testing.T){
+	switched := make(chan bool, 1)
+	apiIns0, keyRateLimit0 := apiIns, keyRateLimit
+	apiIns, keyRateLimit = adminTestAPI{ switched: switched }, 1000
+	defer func(){
+		apiIns, keyRateLimit = apiIns0, keyRateLimit0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	for _, c := range []struct{
+		path, body string
+		code int
+		msg string
+	}{
+		{ "/admin/plugin/hello/enabled", `{"enabled":false}`, http.StatusOK, "" },
+		{ "/admin/plugin/hello/enabled", `{"stable":true}`, http.StatusBadRequest, `missing field \"enabled\"` },
+		{ "/admin/plugin/hello/enabled", `{"enabled":`, http.StatusBadRequest, "" },
+		{ "/admin/plugin/hello/release/1.0.0/stable", `{"stable":true}`, http.StatusOK, "" },
+		{ "/admin/plugin/hello/release/1.0.0/stable", `{}`, http.StatusBadRequest, `missing field \"stable\"` },
+	} {
+		req := httptest.NewRequest(http.MethodPut, c.path, strings.NewReader(c.body))
+		req.Header.Set("Authorization", "Bearer admin-token")
+		req.Header.Set("Content-Type", "application/json")
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, req)
+		if rw.Code != c.code {
+			t.Errorf("Unexpected status of %s %s: %d, expect %d: %s", c.path, c.body, rw.Code, c.code, rw.Body.String())
+			continue
+		}
+		if !strings.Contains(rw.Body.String(), c.msg) {
+			t.Errorf("Unexpected body of %s %s: %s", c.path, c.body, rw.Body.String())
+		}
+		select {
+		case <-switched:
+			if c.code != http.StatusOK {
+				t.Errorf("The switch should not be changed by %s %s", c.path, c.body)
+			}
+		default:
+			if c.code == http.StatusOK {
+				t.Errorf("The switch is not changed by %s %s", c.path, c.body)
+			}
+		}
+	}
+}
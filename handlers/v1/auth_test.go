@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+type authTestAPI struct{
+	cacheTestAPI
+	lookups *int32
+}
+
+func (a authTestAPI)GetAPIKey(token string)(*api.APIKey, error){
+	atomic.AddInt32(a.lookups, 1)
+	return nil, api.ErrUnauthorized
+}
+
+func TestInvalidKeyLimit(t *testing.T){
+	var lookups int32
+	apiIns0, anonRateLimit0, authFailures0 := apiIns, anonRateLimit, authFailures
+	apiIns, anonRateLimit, authFailures = authTestAPI{ lookups: &lookups }, 1000, api.NewRateLimiter(api.RateLimitWindow)
+	defer func(){
+		apiIns, anonRateLimit, authFailures = apiIns0, anonRateLimit0, authFailures0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	do := func(remoteAddr string, realIP string)(int){
+		req := httptest.NewRequest(http.MethodGet, "/plugins", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Real-IP", realIP)
+		req.Header.Set("Authorization", "Bearer guess")
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, req)
+		return rw.Code
+	}
+	for i := 0; i < maxAuthFailures + 5; i++ {
+		// the spoofed IPs from an untrusted remote are ignored
+		code := do("198.51.100.7:1234", "203.0.113." + string(rune('0' + i % 10)))
+		if i < maxAuthFailures && code != http.StatusUnauthorized {
+			t.Errorf("Request %d: expect 401, got %d", i, code)
+		}else if i >= maxAuthFailures && code != http.StatusTooManyRequests {
+			t.Errorf("Request %d: expect 429, got %d", i, code)
+		}
+	}
+	if lookups != maxAuthFailures {
+		t.Errorf("The key is looked up %d times, expect %d", lookups, maxAuthFailures)
+	}
+	if code := do("198.51.100.8:1234", ""); code != http.StatusUnauthorized {
+		t.Errorf("The other IPs should not be limited, got %d", code)
+	}
+}
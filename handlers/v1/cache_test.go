@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+var cacheTestModTime = time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+type cacheTestAPI struct{
+	api.API
+}
+
+func (cacheTestAPI)GetLastUpdateTime()(time.Time, error){
+	return cacheTestModTime, nil
+}
+
+func (cacheTestAPI)GetPluginLastUpdateTime(id string)(time.Time, error){
+	return cacheTestModTime, nil
+}
+
+func (cacheTestAPI)GetPluginList(opt api.PluginListOpt)([]*api.PluginInfo, error){
+	return []*api.PluginInfo{{ Id: "hello" }}, nil
+}
+
+func (cacheTestAPI)GetPluginInfo(id string, version string)(*api.PluginInfo, error){
+	if id != "hello" {
+		return nil, api.ErrNotFound
+	}
+	return &api.PluginInfo{ Id: "hello" }, nil
+}
+
+func (cacheTestAPI)GetPluginReadme(id string)(api.Content, error){
+	return api.Content{
+		Data: func()([]byte, error){ return ([]byte)("# Hello"), nil },
+		ModTime: cacheTestModTime.Format(http.TimeFormat),
+	}, nil
+}
+
+func TestConditionalRequests(t *testing.T){
+	apiIns0, anonRateLimit0 := apiIns, anonRateLimit
+	apiIns, anonRateLimit = cacheTestAPI{}, 1000
+	defer func(){
+		apiIns, anonRateLimit = apiIns0, anonRateLimit0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	do := func(path string, header map[string]string)(*httptest.ResponseRecorder){
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, req)
+		return rw
+	}
+
+	res := do("/plugins", nil)
+	etag := res.Header().Get("ETag")
+	if res.Code != http.StatusOK || len(etag) == 0 || res.Header().Get("Cache-Control") != api.CacheList {
+		t.Fatalf("Unexpected response of the list: %d, etag=%q, cache=%q", res.Code, etag, res.Header().Get("Cache-Control"))
+	}
+	later := cacheTestModTime.Add(time.Hour).Format(http.TimeFormat)
+	for _, c := range []struct{
+		path   string
+		header map[string]string
+		code   int
+	}{
+		{ "/plugins", map[string]string{"If-None-Match": etag}, http.StatusNotModified },
+		{ "/plugins", map[string]string{"If-None-Match": `W/"x", ` + etag}, http.StatusNotModified },
+		// If-Modified-Since is ignored when If-None-Match exists
+		{ "/plugins", map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": later}, http.StatusOK },
+		{ "/plugins", map[string]string{"If-Modified-Since": later}, http.StatusNotModified },
+		{ "/plugin/hello/info", map[string]string{"If-None-Match": `"x"`}, http.StatusOK },
+		{ "/plugin/hello/readme", map[string]string{"If-Modified-Since": later}, http.StatusNotModified },
+		{ "/plugin/hello/readme", map[string]string{"If-Modified-Since": cacheTestModTime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK },
+	} {
+		res := do(c.path, c.header)
+		if res.Code != c.code {
+			t.Errorf("Unexpected status of %s with %v: %d, expect %d", c.path, c.header, res.Code, c.code)
+		}
+	}
+
+	res = do("/plugin/hello/readme?render=true", nil)
+	if res.Header().Get("Cache-Control") != api.CachePlugin || res.Header().Get("Last-Modified") != cacheTestModTime.Format(http.TimeFormat) {
+		t.Errorf("Unexpected headers of the README: %v", res.Header())
+	}
+	if etag := res.Header().Get("ETag"); do("/plugin/hello/readme?render=true", map[string]string{"If-None-Match": etag}).Code != http.StatusNotModified {
+		t.Errorf("The README is not matched with its ETag %s", etag)
+	}
+
+	if res = do("/plugin/missing/info", nil); res.Code != http.StatusNotFound || res.Header().Get("Cache-Control") != api.CacheNoStore {
+		t.Errorf("The error response should not be stored: %d, %q", res.Code, res.Header().Get("Cache-Control"))
+	}
+	anonRateLimit = 0
+	if res = do("/plugins", nil); res.Code != http.StatusTooManyRequests || res.Header().Get("Cache-Control") != api.CacheNoStore {
+		t.Errorf("The rate limited response should not be stored: %d, %q", res.Code, res.Header().Get("Cache-Control"))
+	}
+}
+
+type compressTestAPI struct{
+	cacheTestAPI
+}
+
+func (compressTestAPI)GetPluginList(opt api.PluginListOpt)(list []*api.PluginInfo, _ error){
+	for i := 0; i < 50; i++ {
+		list = append(list, &api.PluginInfo{ Id: fmt.Sprintf("plugin_%d", i), Desc: "A plugin for the compression test" })
+	}
+	return
+}
+
+func TestCompressedResponses(t *testing.T){
+	apiIns0, anonRateLimit0 := apiIns, anonRateLimit
+	apiIns, anonRateLimit = compressTestAPI{}, 1000
+	defer func(){
+		apiIns, anonRateLimit = apiIns0, anonRateLimit0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	do := func(path string, header map[string]string)(*httptest.ResponseRecorder){
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, req)
+		return rw
+	}
+
+	plain := do("/plugins", nil)
+	res := do("/plugins", map[string]string{"Accept-Encoding": "gzip, br"})
+	if res.Code != http.StatusOK || res.Header().Get("Content-Encoding") != "br" ||
+		!strings.Contains(strings.Join(res.Header().Values("Vary"), ","), "Accept-Encoding") {
+		t.Fatalf("The list is not compressed: %d, %v", res.Code, res.Header())
+	}
+	if res.Body.Len() >= plain.Body.Len() {
+		t.Errorf("The compressed list is not smaller: %d >= %d", res.Body.Len(), plain.Body.Len())
+	}
+	etag := res.Header().Get("ETag")
+	if etag != "W/" + plain.Header().Get("ETag") {
+		t.Errorf("The compressed list should have the weak etag, got %q", etag)
+	}
+	if res = do("/plugins", map[string]string{"Accept-Encoding": "br", "If-None-Match": etag}); res.Code != http.StatusNotModified {
+		t.Errorf("The compressed list is not matched with its etag: %d", res.Code)
+	}
+	if res = do("/plugin/hello/info", map[string]string{"Accept-Encoding": "br"}); len(res.Header().Get("Content-Encoding")) != 0 {
+		t.Errorf("The small response should not be compressed: %v", res.Header())
+	}
+}
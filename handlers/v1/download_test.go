@@ -0,0 +1,208 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+type nopSeekCloser struct{
+	io.ReadSeeker
+}
+
+func (nopSeekCloser)Close()(error){ return nil }
+
+type downloadTestAPI struct{
+	cacheTestAPI
+	recorded chan string
+	statsOpt chan api.DownloadStatsOpt
+}
+
+func (downloadTestAPI)GetPluginReleaseAsset(id string, tag api.Version, filename string)(io.ReadSeekCloser, time.Time, error){
+	return nopSeekCloser{ strings.NewReader("0123456789") }, cacheTestModTime, nil
+}
+
+func (downloadTestAPI)GetPluginRelease(id string, tag api.Version)(*api.PluginRelease, error){
+	return &api.PluginRelease{
+		Id: id, Tag: tag, FileName: "hello.mcdr",
+		Problems: []string{"The plugin id is \"hi\"", "Version;\nmismatch"},
+	}, nil
+}
+
+func (d downloadTestAPI)RecordPluginDownload(id string, tag api.Version, filename string)(error){
+	d.recorded <- filename
+	return nil
+}
+
+func (d downloadTestAPI)GetPluginDownloadStats(id string, opt api.DownloadStatsOpt)([]*api.DownloadStat, error){
+	d.statsOpt <- opt
+	return nil, nil
+}
+
+func TestRecordDownload(t *testing.T){
+	recorded := make(chan string, 8)
+	apiIns0, anonRateLimit0 := apiIns, anonRateLimit
+	apiIns, anonRateLimit = downloadTestAPI{ recorded: recorded }, 1000
+	defer func(){
+		apiIns, anonRateLimit = apiIns0, anonRateLimit0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	const path = "/plugin/hello/release/1.0.0/asset/hello.mcdr"
+	for _, c := range []struct{
+		method string
+		header map[string]string
+		code int
+		counted bool
+	}{
+		{ http.MethodGet, nil, http.StatusOK, true },
+		{ http.MethodGet, map[string]string{"If-Modified-Since": cacheTestModTime.Add(time.Hour).Format(http.TimeFormat)}, http.StatusNotModified, false },
+		{ http.MethodGet, map[string]string{"Range": "bytes=0-4"}, http.StatusPartialContent, true },
+		{ http.MethodGet, map[string]string{"Range": "bytes=5-"}, http.StatusPartialContent, false },
+	} {
+		req := httptest.NewRequest(c.method, path, nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, req)
+		if rw.Code != c.code {
+			t.Errorf("Unexpected status of %s %v: %d, expect %d", c.method, c.header, rw.Code, c.code)
+		}
+		select {
+		case <-recorded:
+			if !c.counted {
+				t.Errorf("The download of %s %v should not be counted", c.method, c.header)
+			}
+		case <-time.After(time.Millisecond * 100):
+			if c.counted {
+				t.Errorf("The download of %s %v is not counted", c.method, c.header)
+			}
+		}
+	}
+
+	// the extra assets are recorded with their own names
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/plugin/hello/release/1.0.0/asset/hello.zip", nil))
+	select {
+	case filename := <-recorded:
+		if filename != "hello.zip" {
+			t.Errorf("Unexpected recorded file %q of the asset", filename)
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Errorf("The download of the asset is not counted")
+	}
+}
+
+func TestDownloadStatsFilename(t *testing.T){
+	statsOpt := make(chan api.DownloadStatsOpt, 1)
+	apiIns0, anonRateLimit0 := apiIns, anonRateLimit
+	apiIns, anonRateLimit = downloadTestAPI{ statsOpt: statsOpt }, 1000
+	defer func(){
+		apiIns, anonRateLimit = apiIns0, anonRateLimit0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	rw := httptest.NewRecorder()
+	app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/plugin/hello/stats/downloads?tag=1.0.0&filename=hello.zip", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", rw.Code)
+	}
+	if opt := <-statsOpt; opt.Tag == nil || opt.Tag.String() != "1.0.0" || opt.Filename != "hello.zip" {
+		t.Errorf("Unexpected stats option: %v, %q", opt.Tag, opt.Filename)
+	}
+}
+
+func TestAssetProblemsHeader(t *testing.T){
+	apiIns0, anonRateLimit0 := apiIns, anonRateLimit
+	apiIns, anonRateLimit = downloadTestAPI{ recorded: make(chan string, 8) }, 1000
+	defer func(){
+		apiIns, anonRateLimit = apiIns0, anonRateLimit0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	for filename, expect := range map[string]string{
+		"hello.mcdr": `The plugin id is "hi"; Version, mismatch`,
+		"hello.zip": "",
+	} {
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/plugin/hello/release/1.0.0/asset/" + filename, nil))
+		if rw.Code != http.StatusOK {
+			t.Errorf("Unexpected status of %s: %d", filename, rw.Code)
+		}
+		if problems := rw.Header().Get("X-PWP-Problems"); problems != expect {
+			t.Errorf("Unexpected problems of %s: %q, expect %q", filename, problems, expect)
+		}
+	}
+}
+
+func (downloadTestAPI)GetPluginReleaseFile(id string, tag api.Version, name string)([]byte, *api.McdrFile, error){
+	data := map[string]string{
+		"hello/index.html": "<script>alert(1)</script>",
+		"hello/logo.bin": "\x89PNG\x00",
+	}[name]
+	if len(data) == 0 {
+		return nil, nil, api.ErrNotFound
+	}
+	return ([]byte)(data), &api.McdrFile{ Name: name, Size: (int64)(len(data)), ModTime: cacheTestModTime }, nil
+}
+
+func TestUntrustedContentHeaders(t *testing.T){
+	apiIns0, anonRateLimit0 := apiIns, anonRateLimit
+	apiIns, anonRateLimit = downloadTestAPI{ recorded: make(chan string, 8) }, 1000
+	defer func(){
+		apiIns, anonRateLimit = apiIns0, anonRateLimit0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	for _, c := range []struct{
+		path string
+		contentType string
+		attachment bool
+	}{
+		{ "/plugin/hello/release/1.0.0/asset/hello.mcdr", "application/zip", true },
+		{ "/plugin/hello/release/1.0.0/asset/page.svg", "application/octet-stream", true },
+		{ "/plugin/hello/release/1.0.0/asset/notes.txt", "text/plain", false },
+		{ "/plugin/hello/release/1.0.0/files/hello/index.html", "text/plain", false },
+		{ "/plugin/hello/release/1.0.0/files/hello/index.html?render=true", "text/html", false },
+		{ "/plugin/hello/release/1.0.0/files/hello/logo.bin", "application/octet-stream", true },
+	} {
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, c.path, nil))
+		header := rw.Header()
+		if rw.Code != http.StatusOK || !strings.HasPrefix(header.Get("Content-Type"), c.contentType) {
+			t.Errorf("Unexpected response of %s: %d, %q", c.path, rw.Code, header.Get("Content-Type"))
+			continue
+		}
+		if header.Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("nosniff is not set for %s", c.path)
+		}
+		if attachment := strings.HasPrefix(header.Get("Content-Disposition"), "attachment"); attachment != c.attachment {
+			t.Errorf("Unexpected Content-Disposition of %s: %q", c.path, header.Get("Content-Disposition"))
+		}
+		if c.contentType == "text/html" && !strings.Contains(header.Get("Content-Security-Policy"), "sandbox") {
+			t.Errorf("The highlighted file is not sandboxed: %q", header.Get("Content-Security-Policy"))
+		}
+	}
+}
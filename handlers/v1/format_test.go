@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+type formatTestAPI struct{
+	cacheTestAPI
+	streamErr error
+	// failAfter is the number of plugins sent before streamErr is returned
+	failAfter int
+}
+
+func (f formatTestAPI)StreamPluginList(opt api.PluginListOpt, fn func(info *api.PluginInfo)(error))(error){
+	for i, id := range []string{"a", "b", "c"} {
+		if f.streamErr != nil && i == f.failAfter {
+			return f.streamErr
+		}
+		if err := fn(&api.PluginInfo{ Id: id }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (formatTestAPI)GetPluginIdList(opt api.PluginListOpt)([]string, error){
+	return []string{"a", "b"}, nil
+}
+
+func TestResponseFormats(t *testing.T){
+	apiIns0, anonRateLimit0 := apiIns, anonRateLimit
+	apiIns, anonRateLimit = formatTestAPI{}, 1000
+	defer func(){
+		apiIns, anonRateLimit = apiIns0, anonRateLimit0
+	}()
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+	do := func(path string, accept string)(*httptest.ResponseRecorder){
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(accept) > 0 {
+			req.Header.Set("Accept", accept)
+		}
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, req)
+		return rw
+	}
+
+	for _, c := range []struct{
+		path, accept string
+		contentType string
+		body string
+	}{
+		{ "/plugins?format=ndjson", "", "application/x-ndjson", `{"id":"a",` },
+		{ "/plugins", "text/csv", "text/csv", "id,name,version,authors," },
+		{ "/plugins?format=yaml", "text/csv", "application/yaml", "- id: hello\n" },
+		{ "/plugins/ids", "application/x-ndjson", "application/x-ndjson", "\"a\"\n\"b\"\n" },
+		{ "/plugin/hello/info", "application/yaml", "application/yaml", "id: hello\n" },
+		{ "/plugin/hello/info", "*/*", "application/json", `{"status":"ok",` },
+	} {
+		res := do(c.path, c.accept)
+		if res.Code != http.StatusOK || !strings.HasPrefix(res.Header().Get("Content-Type"), c.contentType) {
+			t.Errorf("Unexpected response of %s with %q: %d, %q", c.path, c.accept, res.Code, res.Header().Get("Content-Type"))
+			continue
+		}
+		if !strings.HasPrefix(res.Body.String(), c.body) {
+			t.Errorf("Unexpected body of %s with %q: %q", c.path, c.accept, res.Body.String())
+		}
+		if vary := res.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Accept" {
+			t.Errorf("Unexpected Vary of %s: %q", c.path, vary)
+		}
+	}
+
+	res := do("/plugins?format=csv", "")
+	if lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n"); len(lines) != 4 {
+		t.Errorf("Expect the header and 3 records of the streamed CSV, got %q", res.Body.String())
+	}
+	if len(res.Header().Get("ETag")) != 0 {
+		t.Errorf("The streamed list should not have an ETag")
+	}
+	if res = do("/plugins?format=yaml", ""); len(res.Header().Get("ETag")) == 0 || res.Header().Get("ETag") == do("/plugins", "").Header().Get("ETag") {
+		t.Errorf("The formats should have the different ETags: %q", res.Header().Get("ETag"))
+	}
+	if res = do("/plugins?format=xml", ""); res.Code != http.StatusBadRequest {
+		t.Errorf("Expect 400 for the unknown format, got %d", res.Code)
+	}
+
+	apiIns = formatTestAPI{ streamErr: errors.New("database is down") }
+	if res = do("/plugins?format=ndjson", ""); res.Code != http.StatusInternalServerError {
+		t.Errorf("Expect 500 if the stream is failed before the first plugin, got %d", res.Code)
+	}
+
+	apiIns = formatTestAPI{ streamErr: errors.New("database is down"), failAfter: 2 }
+	res = do("/plugins?format=ndjson", "")
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	if res.Code != http.StatusOK || len(lines) != 3 || lines[2] != `{"status":"error","error":"ApiErr","message":"database is down"}` {
+		t.Errorf("Expect 2 plugins and the error line of the failed NDJSON stream, got %d, %q", res.Code, res.Body.String())
+	}
+	res = do("/plugins?format=csv", "")
+	lines = strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	if res.Code != http.StatusOK || len(lines) != 4 || lines[3] != "#error,ApiErr,database is down" {
+		t.Errorf("Expect the header, 2 records and the error trailer of the failed CSV stream, got %d, %q", res.Code, res.Body.String())
+	}
+}
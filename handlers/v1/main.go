@@ -2,13 +2,19 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -19,6 +25,7 @@ import (
 	"github.com/kmcsr/go-logger"
 	lgolog "github.com/kmcsr/go-logger/golog"
 	"github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api/graphql"
 	"github.com/kmcsr/PluginWebPoint/api/mysqlimpl"
 )
 
@@ -50,8 +57,31 @@ func NewErrResp(name string, err error)(*ErrResp){
 }
 
 var sitePrefix string = "https://mcdr.waerba.com"
+var apiPrefix string = sitePrefix + "/v1"
 var apiIns api.API = nil
 
+var (
+	rateLimiter = api.NewRateLimiter(api.RateLimitWindow)
+	anonRateLimit int
+	keyRateLimit int
+	// authFailures counts the invalid API keys of each IP, the lookups are refused after maxAuthFailures
+	authFailures = api.NewRateLimiter(api.RateLimitWindow)
+	// the proxies which `X-Real-IP` header is trusted
+	trustedProxies []*net.IPNet
+)
+
+// maxAuthFailures is the invalid API keys allowed per window of each IP
+const maxAuthFailures = 10
+
+const (
+	eventPollInterval = time.Second * 5
+	eventPingInterval = time.Second * 15
+	// the stream is closed before the write timeout of the server, the clients will reconnect with the last event id
+	eventStreamDuration = time.Second * 50
+)
+
+var eventHub = api.NewEventHub(0)
+
 func main(){
 	address := ""
 	if len(os.Args) >= 2 {
@@ -63,7 +93,26 @@ func main(){
 	dbaddress := os.Getenv("DB_ADDR")
 	database := os.Getenv("DB_NAME")
 
-	apiIns = mysqlimpl.NewMySqlAPI(username, passwd, dbaddress, database, nil)
+	mapi := mysqlimpl.NewMySqlAPI(username, passwd, dbaddress, database, nil)
+	apiIns = mapi
+	go func(){
+		mapi.MigrateLegacyAssets(context.Background())
+		if os.Getenv("ASSET_PREFETCH") == "true" {
+			mapi.PrefetchLoop(context.Background(), time.Hour)
+		}
+	}()
+	go mapi.UsageFlushLoop(context.Background(), time.Minute)
+
+	if prefix := os.Getenv("SITE_PREFIX"); len(prefix) > 0 {
+		sitePrefix = strings.TrimSuffix(prefix, "/")
+		apiPrefix = sitePrefix + "/v1"
+	}
+
+	anonRateLimit = api.RateLimitFromEnv("RATE_LIMIT_ANON", api.DefaultAnonRateLimit)
+	keyRateLimit = api.RateLimitFromEnv("RATE_LIMIT_KEY", api.DefaultKeyRateLimit)
+	trustedProxies = api.TrustedProxiesFromEnv()
+	go rateLimiter.CleanupLoop(nil)
+	go authFailures.CleanupLoop(nil)
 
 	app := iris.New()
 	app.SetName("[V1-API]")
@@ -81,52 +130,9 @@ func main(){
 	}
 	app.Logger().SetTimeFormat("2006-01-02 15:04:05.000:")
 	app.Logger().Debugf("V1 API Debug mode on")
-	app.Macros().Get("string").RegisterFunc("pid", api.PluginIdRe.MatchString)
-	app.Macros().Get("string").RegisterFunc("version", api.VersionRe.MatchString)
-
-	app.Use(recover.New(), loggerMiddleware)
-	app.OnErrorCode(iris.StatusNotFound, func(ctx iris.Context){
-		if !ctx.IsStopped() {
-			ctx.StopWithJSON(iris.StatusNotFound, ErrResp{
-				Status: "error",
-				Name: "EntryPointNotExist",
-				Msg: "The entry point you are accessing is not exists",
-				Extra: ctx.Path(),
-			})
-			return
-		}
-	})
-
-	app.Use(func(ctx iris.Context){
-		ctx.Header(irisContext.CacheControlHeaderKey, "no-cache")
-		ctx.Next()
-	})
-
-	app.Get("/", func(ctx iris.Context){
-		ctx.JSON(iris.Map{
-			"status": "ok",
-			"time": time.Now().UTC().String(),
-			"version": 1,
-		})
-	})
+	go pollEvents(loger, eventPollInterval)
 
-	app.PartyFunc("/plugins", func(p iris.Party){
-		p.Use(parseGetPluginListOption, checkIfNotModified)
-		p.Get("/", v1Plugins)
-		p.Get("/ids", v1PluginIds)
-		p.Get("/count", v1PluginCounts)
-		p.Get("/sitemap.txt", v1PluginSitemapTxt)
-	})
-	app.PartyFunc("/plugin/{id:string pid()}", func(p iris.Party){
-		p.Get("/info", checkIfNotModifiedPluginInfo, v1PluginInfo)
-		p.Get("/readme", v1PluginReadme)
-		p.Get("/releases", checkIfNotModifiedPluginInfo, v1PluginReleases)
-		p.PartyFunc("/release/{tag:string version()}", func(p iris.Party){
-			p.Use(checkIfNotModifiedPluginInfo)
-			p.Get("/", v1PluginRelease)
-			p.Get("/asset/{filename:file}", v1PluginAsset)
-		})
-	})
+	registerRoutes(app)
 
 	if err := app.Build(); err != nil {
 		app.Logger().Fatalf("Cannot build application's router: %v", err)
@@ -179,6 +185,114 @@ func main(){
 	}
 }
 
+// registerRoutes registers the middlewares and the routes of the API
+func registerRoutes(app *iris.Application){
+	app.Macros().Get("string").RegisterFunc("pid", api.PluginIdRe.MatchString)
+	app.Macros().Get("string").RegisterFunc("version", api.VersionRe.MatchString)
+
+	app.Use(recover.New(), loggerMiddleware)
+	app.OnErrorCode(iris.StatusNotFound, func(ctx iris.Context){
+		if !ctx.IsStopped() {
+			ctx.StopWithJSON(iris.StatusNotFound, ErrResp{
+				Status: "error",
+				Name: "EntryPointNotExist",
+				Msg: "The entry point you are accessing is not exists",
+				Extra: ctx.Path(),
+			})
+			return
+		}
+	})
+
+	// the errors are never cached, whatever the policy of the route is
+	app.WrapRouter(func(w http.ResponseWriter, r *http.Request, router http.HandlerFunc){
+		cw := api.NewCompressWriter(w, r)
+		defer cw.Close()
+		router(api.NoStoreOnError(cw), r)
+	})
+	app.Use(func(ctx iris.Context){
+		ctx.Header(irisContext.CacheControlHeaderKey, api.CacheRevalidate)
+		ctx.Next()
+	}, identifyCaller)
+
+	app.Get("/", cacheControl(api.CacheNoStore), func(ctx iris.Context){
+		ctx.JSON(iris.Map{
+			"status": "ok",
+			"time": time.Now().UTC().String(),
+			"version": 1,
+		})
+	})
+
+	app.PartyFunc("/plugins", func(p iris.Party){
+		p.Use(cacheControl(api.CacheList), parseGetPluginListOption, checkIfNotModified)
+		p.Get("/", v1Plugins)
+		p.Get("/ids", v1PluginIds)
+		p.Get("/count", v1PluginCounts)
+		p.Get("/sitemap.txt", v1PluginSitemapTxt)
+	})
+	app.Post("/plugins/batch", cacheControl(api.CacheNoStore), v1PluginBatch)
+	app.PartyFunc("/plugin/{id:string pid()}", func(p iris.Party){
+		p.Use(cacheControl(api.CachePlugin))
+		p.Get("/info", checkIfNotModifiedPluginInfo, v1PluginInfo)
+		p.Get("/readme", v1PluginReadme)
+		p.Get("/releases", checkIfNotModifiedPluginInfo, v1PluginReleases)
+		p.Get("/stats/downloads", v1PluginDownloadStats)
+		p.Get("/changelog", checkIfNotModifiedPluginInfo, v1PluginChangelog)
+		p.PartyFunc("/release/{tag:string version()}", func(p iris.Party){
+			p.Use(cacheControl(api.CacheRelease), checkIfNotModifiedPluginInfo)
+			p.Get("/", v1PluginRelease)
+			p.Get("/asset/{filename:file}", cacheControl(api.CacheAsset), v1PluginAsset)
+			p.Get("/changelog", v1PluginReleaseChangelog)
+			p.Get("/meta", v1PluginReleaseMeta)
+			p.Get("/files", v1PluginReleaseFiles)
+			p.Get("/files/{path:path}", v1PluginReleaseFile)
+		})
+	})
+
+	app.Get("/sitemap.xml", cacheControl(api.CacheList), v1Sitemap)
+	app.Get("/changes", v1Changes)
+	app.Get("/openapi.json", cacheControl(api.CacheStatic), v1OpenAPI)
+	app.HandleMany(http.MethodGet + " " + http.MethodPost, "/graphql", v1GraphQL)
+	app.Get("/graphql/schema.graphql", cacheControl(api.CacheStatic), v1GraphQLSchema)
+
+	app.PartyFunc("/feeds", func(p iris.Party){
+		p.Use(cacheControl(api.CacheList))
+		for _, format := range []string{"atom", "rss"} {
+			p.Get("/releases." + format, v1ReleaseFeed)
+			p.Get("/plugin/{id:string pid()}/releases." + format, v1ReleaseFeed)
+			p.Get("/author/{author:string}/releases." + format, v1ReleaseFeed)
+			p.Get("/label/{label:string}/releases." + format, v1ReleaseFeed)
+		}
+	})
+
+	app.Get("/events", cacheControl(api.CacheNoStore), v1Events)
+
+	app.PartyFunc("/publish/plugin/{id:string pid()}", func(p iris.Party){
+		p.Use(cacheControl(api.CacheNoStore), requireScope(api.ScopePublish))
+		p.Post("/", v1PublishPlugin)
+		p.Patch("/info", v1PublishPluginMeta)
+		p.Put("/readme", v1PublishPluginReadme)
+		p.Post("/release/{tag:string version()}", v1PublishRelease)
+		p.Put("/release/{tag:string version()}/stable", v1PublishReleaseStable)
+	})
+
+	app.PartyFunc("/admin/plugin/{id:string pid()}", func(p iris.Party){
+		p.Use(cacheControl(api.CacheNoStore), requireScope(api.ScopeAdmin))
+		p.Put("/enabled", v1AdminPluginEnabled)
+		p.Post("/resync", v1AdminPluginResync)
+		p.Delete("/cache", v1AdminPluginPurgeCache)
+		p.Put("/release/{tag:string version()}/enabled", v1AdminReleaseEnabled)
+		p.Put("/release/{tag:string version()}/stable", v1AdminReleaseStable)
+	})
+
+	app.PartyFunc("/webhooks", func(p iris.Party){
+		p.Use(cacheControl(api.CacheNoStore), requireScope(api.ScopeWebhook))
+		p.Get("/", v1Webhooks)
+		p.Post("/", v1WebhookCreate)
+		p.Delete("/{hook:int64}", v1WebhookDelete)
+		p.Get("/{hook:int64}/deliveries", v1WebhookDeliveries)
+	})
+}
+
 func loggerMiddleware(ctx iris.Context){
 	var (
 		ip, method, path string
@@ -188,10 +302,7 @@ func loggerMiddleware(ctx iris.Context){
 	)
 	startTime = time.Now()
 
-	ip = ctx.RemoteAddr()
-	if rip := ctx.GetHeader("X-Real-IP"); len(rip) > 0 {
-		ip = rip
-	}
+	ip = clientIP(ctx)
 	method = ctx.Method()
 	path = ctx.Path()
 
@@ -200,6 +311,9 @@ func loggerMiddleware(ctx iris.Context){
 	usedTime = time.Since(startTime)
 	status = ctx.GetStatusCode()
 	line := fmt.Sprintf("%v %4v %s %s %s", status, usedTime, ip, method, path)
+	if key, ok := ctx.Values().Get("apiKey").(*api.APIKey); ok {
+		line += " key=" + key.Id
+	}
 
 	if irisContext.StatusCodeNotSuccessful(status) {
 		ctx.Application().Logger().Warn(line)
@@ -210,10 +324,13 @@ func loggerMiddleware(ctx iris.Context){
 
 func checkIfNotModified(ctx iris.Context){
 	if modTime, err := apiIns.GetLastUpdateTime(); err == nil {
-		if modified, err := ctx.CheckIfModifiedSince(modTime); !modified && err == nil {
-			ctx.WriteNotModified()
-			ctx.StopExecution()
-			return
+		// If-None-Match takes precedence, it's checked by the handler with the ETag of the content
+		if len(ctx.GetHeader("If-None-Match")) == 0 {
+			if modified, err := ctx.CheckIfModifiedSince(modTime); !modified && err == nil {
+				ctx.WriteNotModified()
+				ctx.StopExecution()
+				return
+			}
 		}
 		ctx.SetLastModified(modTime)
 	}else{
@@ -225,10 +342,13 @@ func checkIfNotModified(ctx iris.Context){
 func checkIfNotModifiedPluginInfo(ctx iris.Context){
 	id := ctx.Params().GetString("id")
 	if modTime, err := apiIns.GetPluginLastUpdateTime(id); err == nil {
-		if modified, err := ctx.CheckIfModifiedSince(modTime); !modified && err == nil {
-			ctx.WriteNotModified()
-			ctx.StopExecution()
-			return
+		// If-None-Match takes precedence, it's checked by the handler with the ETag of the content
+		if len(ctx.GetHeader("If-None-Match")) == 0 {
+			if modified, err := ctx.CheckIfModifiedSince(modTime); !modified && err == nil {
+				ctx.WriteNotModified()
+				ctx.StopExecution()
+				return
+			}
 		}
 		ctx.SetLastModified(modTime)
 	}
@@ -271,23 +391,89 @@ func parseGetPluginListOption(ctx iris.Context){
 }
 
 func v1Plugins(ctx iris.Context){
+	format, ok := negotiateFormat(ctx)
+	if !ok {
+		return
+	}
 	payload, _ := ctx.Values().Get(keyPluginListOption).(api.PluginListOpt)
+	if format == api.FormatNDJSON || format == api.FormatCSV {
+		streamPluginList(ctx, payload, format)
+		return
+	}
 	list, err := apiIns.GetPluginList(payload)
 	if err != nil {
 		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
 		return
 	}
-	err = ctx.JSON(NewOkResp(list))
+	writeFormatWithETag(ctx, format, list)
+}
+
+// streamPluginList writes the plugins as the records of the format once they are read,
+// so the client does not wait for the whole list. The content-hash ETag is not available.
+// If the list fails after the status is sent, an error record ends the stream instead
+func streamPluginList(ctx iris.Context, opt api.PluginListOpt, format string){
+	w := ctx.ResponseWriter()
+	var (
+		encode func(v any)(error)
+		csvEnc *api.CSVEncoder
+	)
+	if format == api.FormatCSV {
+		csvEnc = api.NewCSVEncoder(w, api.PluginCSVColumns)
+		encode = csvEnc.Encode
+	}else{
+		encode = json.NewEncoder(w).Encode
+	}
+	ctx.ContentType(api.FormatContentTypes[format])
+	var (
+		started bool
+		writeErr error
+	)
+	err := apiIns.StreamPluginList(opt, func(info *api.PluginInfo)(error){
+		started = true
+		writeErr = encode(info)
+		return writeErr
+	})
+	if writeErr != nil {
+		// the client is gone, nothing more can be sent
+		ctx.Application().Logger().Warnf("Cannot stream the plugin list: %v", writeErr)
+		return
+	}
+	if err != nil {
+		if !started {
+			ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+			return
+		}
+		ctx.Application().Logger().Warnf("Cannot stream the plugin list: %v", err)
+		// the status is already sent, so the error is the last record
+		if csvEnc != nil {
+			err = csvEnc.WriteError("ApiErr", err.Error())
+		}else{
+			err = encode(NewErrResp("ApiErr", err))
+		}
+		if err != nil {
+			ctx.Application().Logger().Warnf("Cannot write the error record: %v", err)
+		}
+		return
+	}
+	if csvEnc != nil {
+		if err = csvEnc.Flush(); err != nil {
+			ctx.Application().Logger().Warnf("Cannot stream the plugin list: %v", err)
+		}
+	}
 }
 
 func v1PluginIds(ctx iris.Context){
+	format, ok := negotiateFormat(ctx)
+	if !ok {
+		return
+	}
 	payload, _ := ctx.Values().Get(keyPluginListOption).(api.PluginListOpt)
 	list, err := apiIns.GetPluginIdList(payload)
 	if err != nil {
 		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
 		return
 	}
-	ctx.JSON(NewOkResp(list))
+	writeFormatWithETag(ctx, format, list)
 }
 
 func v1PluginCounts(ctx iris.Context){
@@ -297,7 +483,7 @@ func v1PluginCounts(ctx iris.Context){
 		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
 		return
 	}
-	ctx.JSON(NewOkResp(counts))
+	writeJSONWithETag(ctx, counts)
 }
 
 func v1PluginSitemapTxt(ctx iris.Context){
@@ -311,10 +497,36 @@ func v1PluginSitemapTxt(ctx iris.Context){
 	for _, id := range list {
 		sites.WriteString(fmt.Sprintf("%s/plugin/%s\n", sitePrefix, id))
 	}
-	ctx.Text(sites.String())
+	writeWithETag(ctx, ([]byte)(sites.String()), "text/plain; charset=utf-8", time.Time{})
+}
+
+// maxBatchRequestSize is the max size of the batch lookup request body
+const maxBatchRequestSize = 64 * 1024
+
+func v1PluginBatch(ctx iris.Context){
+	ctx.SetMaxRequestBodySize(maxBatchRequestSize)
+	body, err := ctx.GetBody()
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusRequestEntityTooLarge, NewErrResp("TooLarge", err))
+		return
+	}
+	var opt api.BatchOpt
+	if err = json.Unmarshal(body, &opt); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("JsonDecodeErr", err))
+		return
+	}
+	if err = opt.Check(); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", err))
+		return
+	}
+	ctx.JSON(NewOkResp(api.GetPluginBatch(apiIns, opt)))
 }
 
 func v1PluginInfo(ctx iris.Context){
+	format, ok := negotiateFormat(ctx)
+	if !ok {
+		return
+	}
 	id := ctx.Params().GetString("id")
 	info, err := apiIns.GetPluginInfo(id, "latest")
 	if err != nil {
@@ -325,7 +537,7 @@ func v1PluginInfo(ctx iris.Context){
 		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
 		return
 	}
-	ctx.JSON(NewOkResp(info))
+	writeFormatWithETag(ctx, format, info)
 }
 
 func v1PluginReadme(ctx iris.Context){
@@ -341,18 +553,12 @@ func v1PluginReadme(ctx iris.Context){
 		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
 		return
 	}
-	content.ModTime += " v1"
-	ims := ctx.GetHeader(irisContext.IfModifiedSinceHeaderKey)
-	if ims == content.ModTime {
-		ctx.WriteNotModified()
-		return
-	}
-	ctx.Header(irisContext.LastModifiedHeaderKey, content.ModTime)
 	body, err := content.Data()
 	if err != nil {
 		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("Cannot get the data of the README", err))
 		return
 	}
+	contentType := "text/plain; charset=utf-8"
 	if render {
 		body0, err := api.RenderMarkdown(body, &api.Option{
 			URLPrefix: content.URLPrefix,
@@ -361,14 +567,21 @@ func v1PluginReadme(ctx iris.Context){
 		})
 		if err == nil {
 			body = body0
+			contentType = "text/html; charset=utf-8"
 		}else{
 			ctx.Application().Logger().Debugf("Cannot render readme: %v", err)
 		}
 	}
-	_, _ = ctx.Write(body)
+	// the modified time is zero if it's unknown, then only the ETag is used
+	modTime, _ := http.ParseTime(content.ModTime)
+	writeWithETag(ctx, body, contentType, modTime)
 }
 
 func v1PluginReleases(ctx iris.Context){
+	format, ok := negotiateFormat(ctx)
+	if !ok {
+		return
+	}
 	id := ctx.Params().GetString("id")
 	releases, err := apiIns.GetPluginReleases(id)
 	if err != nil {
@@ -379,7 +592,7 @@ func v1PluginReleases(ctx iris.Context){
 		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
 		return
 	}
-	ctx.JSON(NewOkResp(releases))
+	writeFormatWithETag(ctx, format, releases)
 }
 
 func v1PluginRelease(ctx iris.Context){
@@ -415,5 +628,1037 @@ func v1PluginAsset(ctx iris.Context){
 		return
 	}
 	defer fd.Close()
+	setAssetHeaders(ctx, id, tag, filename)
 	ctx.ServeContent(fd, filename, modTime)
+	recordDownload(ctx, id, tag, filename)
+}
+
+// setAssetHeaders sets the `Content-Type` of the asset, and `Content-Disposition` if it's not safe to be shown inline,
+// sets `Digest` and `ETag` headers if the checksums of the asset are known,
+// and sets `X-PWP-Problems` header if the package has inspection problems
+func setAssetHeaders(ctx iris.Context, id string, tag api.Version, filename string){
+	// the assets are uploaded by the plugin authors, never let the browser guess the type
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	contentType := api.AssetContentType(filename)
+	ctx.ContentType(contentType)
+	if !api.IsInlineContentType(contentType) {
+		ctx.Header(irisContext.ContentDispositionHeaderKey, irisContext.MakeDisposition(filename))
+	}
+	release, err := apiIns.GetPluginRelease(id, tag)
+	if err != nil {
+		ctx.Application().Logger().Warnf("Cannot get release %s@%s: %v", id, tag, err)
+		return
+	}
+	if filename == release.FileName && len(release.Problems) > 0 {
+		ctx.Header("X-PWP-Problems", problemsHeader(release.Problems))
+	}
+	asset := release.GetAsset(filename)
+	if asset == nil || asset.Checksums.IsZero() {
+		return
+	}
+	ctx.Header("Digest", asset.Checksums.Digest())
+	ctx.Header(irisContext.ETagHeaderKey, asset.Checksums.ETag())
+}
+
+var headerValueReplacer = strings.NewReplacer("\r", " ", "\n", " ", ";", ",")
+
+// problemsHeader joins the problems with `; ` as a single line header value
+func problemsHeader(problems []string)(string){
+	values := make([]string, len(problems))
+	for i, p := range problems {
+		values[i] = headerValueReplacer.Replace(p)
+	}
+	return strings.Join(values, "; ")
+}
+
+// recordDownload records a download of the release asset after it's served.
+// Only the full responses and the ranges started from zero are counted,
+// so neither a conditional request (304) nor a resumed download is counted again
+func recordDownload(ctx iris.Context, id string, tag api.Version, filename string){
+	if ctx.Method() != http.MethodGet {
+		return
+	}
+	switch ctx.GetStatusCode() {
+	case http.StatusOK:
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(ctx.ResponseWriter().Header().Get("Content-Range"), "bytes 0-") {
+			return
+		}
+	default:
+		return
+	}
+	logger := ctx.Application().Logger()
+	go func(){
+		if err := apiIns.RecordPluginDownload(id, tag, filename); err != nil {
+			logger.Errorf("Cannot record download for %s@%s:%s: %v", id, tag, filename, err)
+		}
+	}()
+}
+
+func parseDateParam(ctx iris.Context, name string, def time.Time)(t time.Time, err error){
+	v := ctx.URLParamTrim(name)
+	if len(v) == 0 {
+		return def, nil
+	}
+	if t, err = time.Parse("2006-01-02", v); err == nil {
+		return
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func v1PluginDownloadStats(ctx iris.Context){
+	const maxStatsRange = 366 * 3 * 24 * time.Hour
+
+	id := ctx.Params().GetString("id")
+	var (
+		opt api.DownloadStatsOpt
+		err error
+	)
+	now := time.Now().UTC()
+	if opt.To, err = parseDateParam(ctx, "to", now); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("TimeFormatErr", err))
+		return
+	}
+	if opt.From, err = parseDateParam(ctx, "from", opt.To.AddDate(0, 0, -30)); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("TimeFormatErr", err))
+		return
+	}
+	if opt.To.Before(opt.From) || opt.To.Sub(opt.From) > maxStatsRange {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("TimeRangeErr",
+			fmt.Errorf("Time range must be positive and not longer than %d days", maxStatsRange / (24 * time.Hour))))
+		return
+	}
+	opt.Granularity = ctx.URLParamDefault("granularity", api.GranularityDay)
+	if err = api.CheckGranularity(opt.Granularity); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("GranularityErr", err))
+		return
+	}
+	if tag0 := ctx.URLParamTrim("tag"); len(tag0) > 0 {
+		tag, err := api.VersionFromString(tag0)
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+			return
+		}
+		opt.Tag = &tag
+	}
+	opt.Filename = ctx.URLParamTrim("filename")
+	stats, err := apiIns.GetPluginDownloadStats(id, opt)
+	if err != nil {
+		if err == api.ErrNotFound {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotFound", err))
+			return
+		}
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	ctx.JSON(NewOkResp(iris.Map{
+		"granularity": opt.Granularity,
+		"from": api.BucketStart(opt.From, opt.Granularity),
+		"to": opt.To,
+		"series": stats,
+	}))
+}
+
+func parseVersionParam(ctx iris.Context, name string)(v *api.Version, err error){
+	s := ctx.URLParamTrim(name)
+	if len(s) == 0 {
+		return nil, nil
+	}
+	var v0 api.Version
+	if v0, err = api.VersionFromString(s); err != nil {
+		return
+	}
+	return &v0, nil
+}
+
+func writeMarkdownContent(ctx iris.Context, content api.Content){
+	render, _ := ctx.URLParamBool("render")
+	body, err := content.Data()
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	if render {
+		body0, err := api.RenderMarkdown(body, &api.Option{
+			URLPrefix: content.URLPrefix,
+			DataURLPrefix: content.DataURLPrefix,
+			HeadingIDPrefix: "MDH~",
+		})
+		if err == nil {
+			ctx.ContentType("text/html")
+			_, _ = ctx.Write(body0)
+			return
+		}
+		ctx.Application().Logger().Warnf("Cannot render markdown: %v", err)
+	}
+	ctx.ContentType("text/plain")
+	_, _ = ctx.Write(body)
+}
+
+func v1PluginReleaseChangelog(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	tag, err := api.VersionFromString(ctx.Params().GetString("tag"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	content, err := apiIns.GetPluginReleaseChangelog(id, tag)
+	if err != nil {
+		if err == api.ErrNotFound {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotFound", err))
+			return
+		}
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	defer content.Close()
+	writeMarkdownContent(ctx, content)
+}
+
+func v1PluginChangelog(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	from, err := parseVersionParam(ctx, "from")
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	to, err := parseVersionParam(ctx, "to")
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	content, err := apiIns.GetPluginChangelog(id, from, to)
+	if err != nil {
+		if err == api.ErrNotFound {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotFound", err))
+			return
+		}
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	defer content.Close()
+	writeMarkdownContent(ctx, content)
+}
+
+func v1PluginReleaseMeta(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	tag, err := api.VersionFromString(ctx.Params().GetString("tag"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	info, err := apiIns.GetPluginReleaseMeta(id, tag)
+	if err != nil {
+		if err == api.ErrNotFound {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotFound", err))
+			return
+		}
+		if err == api.ErrNotMcdrPackage {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotMcdrPackage", err))
+			return
+		}
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	ctx.JSON(NewOkResp(info))
+}
+
+func v1PluginReleaseFiles(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	tag, err := api.VersionFromString(ctx.Params().GetString("tag"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	info, err := apiIns.GetPluginReleaseMeta(id, tag)
+	if err != nil {
+		if err == api.ErrNotFound {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotFound", err))
+			return
+		}
+		if err == api.ErrNotMcdrPackage {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotMcdrPackage", err))
+			return
+		}
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	ctx.JSON(NewOkResp(info.Files))
+}
+
+func v1PluginReleaseFile(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	tag, err := api.VersionFromString(ctx.Params().GetString("tag"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	name := ctx.Params().GetString("path")
+	render, _ := ctx.URLParamBool("render")
+	data, file, err := apiIns.GetPluginReleaseFile(id, tag, name)
+	if err != nil {
+		if err == api.ErrNotFound {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotFound", err))
+			return
+		}
+		if err == api.ErrNotMcdrPackage {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotMcdrPackage", err))
+			return
+		}
+		if err == api.ErrFileTooLarge || err == api.ErrMcdrTooLarge {
+			ctx.StopWithJSON(iris.StatusUnprocessableEntity, NewErrResp("FileTooLarge", err))
+			return
+		}
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	// the files are uploaded by the plugin authors, never let the browser guess the type,
+	// and only the plain text or the highlighted HTML generated by ourselves is shown inline
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	if !api.IsTextFile(data) {
+		ctx.ContentType("application/octet-stream")
+		ctx.Header(irisContext.ContentDispositionHeaderKey, irisContext.MakeDisposition(path.Base(file.Name)))
+		ctx.ServeContent(bytes.NewReader(data), path.Base(file.Name), file.ModTime)
+		return
+	}
+	if render {
+		body, err := api.HighlightCode(file.Name, data)
+		if err == nil {
+			// the highlighted code only has the inline styles
+			ctx.Header("Content-Security-Policy", highlightCSP)
+			ctx.ContentType("text/html")
+			_, _ = ctx.Write(body)
+			return
+		}
+		ctx.Application().Logger().Warnf("Cannot highlight %s: %v", file.Name, err)
+	}
+	ctx.ContentType("text/plain")
+	_, _ = ctx.Write(data)
+}
+
+// highlightCSP is the Content-Security-Policy of the highlighted files,
+// which blocks anything but the inline styles even if the highlighter misses escaping something
+const highlightCSP = "default-src 'none'; style-src 'unsafe-inline'; sandbox"
+
+func clientIP(ctx iris.Context)(string){
+	return api.ClientIP(ctx.Request().RemoteAddr, ctx.GetHeader("X-Real-IP"), trustedProxies)
+}
+
+// apiKeyToken returns the token in the header `Authorization: Bearer <token>` or `X-API-Key: <token>`
+func apiKeyToken(ctx iris.Context)(string){
+	if auth := ctx.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return strings.TrimSpace(ctx.GetHeader("X-API-Key"))
+}
+
+// identifyCaller authenticates the API key if it's provided, and limits the requests of the caller.
+// The callers without API key are limited by their IP with a lower limit
+func identifyCaller(ctx iris.Context){
+	var (
+		caller string
+		limit int
+	)
+	now := time.Now()
+	ip := clientIP(ctx)
+	if token := apiKeyToken(ctx); len(token) > 0 {
+		// the keys are not looked up for the IPs that are guessing them
+		if authFailures.Exceeded(ip, maxAuthFailures, now) {
+			ctx.Header("Retry-After", strconv.Itoa((int)(api.RateLimitWindow.Seconds())))
+			ctx.StopWithJSON(iris.StatusTooManyRequests, NewErrResp("TooManyRequests",
+				fmt.Errorf("Too many invalid API keys, %d are allowed per %v", maxAuthFailures, api.RateLimitWindow)))
+			return
+		}
+		key, err := apiIns.GetAPIKey(token)
+		if err != nil {
+			if err == api.ErrUnauthorized {
+				authFailures.Allow(ip, maxAuthFailures, now)
+				ctx.Header("WWW-Authenticate", `Bearer realm="PluginWebPoint", error="invalid_token"`)
+				ctx.StopWithJSON(iris.StatusUnauthorized, NewErrResp("Unauthorized", err))
+				return
+			}
+			ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+			return
+		}
+		ctx.Values().Set("apiKey", key)
+		apiIns.RecordAPIKeyUsage(key.Id)
+		caller, limit = "key:" + key.Id, key.RateLimit(keyRateLimit)
+	}else{
+		caller, limit = "ip:" + ip, anonRateLimit
+	}
+
+	ok, remaining, reset := rateLimiter.Allow(caller, limit, now)
+	ctx.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+	ctx.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	ctx.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	if !ok {
+		ctx.Header("Retry-After", strconv.Itoa((int)(reset.Sub(now).Seconds()) + 1))
+		ctx.StopWithJSON(iris.StatusTooManyRequests, NewErrResp("TooManyRequests",
+			fmt.Errorf("Rate limit exceeded, %d requests are allowed per %v", limit, api.RateLimitWindow)))
+		return
+	}
+	ctx.Next()
+}
+
+// requireScope checks if the API key authenticated by identifyCaller is granted the scope
+func requireScope(scope string)(iris.Handler){
+	return func(ctx iris.Context){
+		key, ok := ctx.Values().Get("apiKey").(*api.APIKey)
+		if !ok {
+			ctx.Header("WWW-Authenticate", `Bearer realm="PluginWebPoint"`)
+			ctx.StopWithJSON(iris.StatusUnauthorized, NewErrResp("Unauthorized", api.ErrUnauthorized))
+			return
+		}
+		if !key.HasScope(scope) {
+			ctx.StopWithJSON(iris.StatusForbidden, NewErrResp("Forbidden", fmt.Errorf("The API key is not granted the scope %q", scope)))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func writePublishErr(ctx iris.Context, err error){
+	var perr *api.PackageProblemsErr
+	switch {
+	case err == api.ErrNotFound:
+		ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotFound", err))
+	case err == api.ErrExists:
+		ctx.StopWithJSON(iris.StatusConflict, NewErrResp("Exists", err))
+	case err == api.ErrGithubSynced:
+		ctx.StopWithJSON(iris.StatusConflict, NewErrResp("GithubSynced", err))
+	case err == api.ErrNotGithubSynced:
+		ctx.StopWithJSON(iris.StatusConflict, NewErrResp("NotGithubSynced", err))
+	case err == api.ErrNotMcdrPackage:
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("NotMcdrPackage", err))
+	case err == api.ErrMcdrTooLarge:
+		ctx.StopWithJSON(iris.StatusRequestEntityTooLarge, NewErrResp("TooLarge", err))
+	case errors.As(err, &perr):
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("InvalidPackage", err))
+	default:
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+	}
+}
+
+func v1PublishPlugin(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	var meta api.PluginMetaUpdate
+	if err := ctx.ReadJSON(&meta); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", err))
+		return
+	}
+	if err := apiIns.CreatePlugin(id, &meta); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.StatusCode(iris.StatusCreated)
+	ctx.JSON(NewOkResp(nil))
+}
+
+func v1PublishPluginMeta(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	var meta api.PluginMetaUpdate
+	if err := ctx.ReadJSON(&meta); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", err))
+		return
+	}
+	if err := apiIns.UpdatePluginMeta(id, &meta); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(nil))
+}
+
+const maxReadmeSize = 1024 * 1024
+
+func v1PublishPluginReadme(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	ctx.SetMaxRequestBodySize(maxReadmeSize)
+	data, err := ctx.GetBody()
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusRequestEntityTooLarge, NewErrResp("TooLarge", err))
+		return
+	}
+	if err := apiIns.SetPluginReadme(id, data); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(nil))
+}
+
+func v1PublishRelease(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	tag, err := api.VersionFromString(ctx.Params().GetString("tag"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	ctx.SetMaxRequestBodySize(api.McdrMaxSize + maxReadmeSize)
+	fd, header, err := ctx.FormFile("asset")
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", err))
+		return
+	}
+	defer fd.Close()
+	data, err := io.ReadAll(io.LimitReader(fd, api.McdrMaxSize + 1))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", err))
+		return
+	}
+	stable, _ := ctx.PostValueBool("stable")
+	release, err := apiIns.PublishRelease(id, tag, api.ReleasePublishOpt{
+		Name: ctx.PostValue("name"),
+		Changelog: ctx.PostValue("changelog"),
+		Stable: stable,
+		FileName: header.Filename,
+	}, data)
+	if err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.StatusCode(iris.StatusCreated)
+	ctx.JSON(NewOkResp(release))
+}
+
+func v1PublishReleaseStable(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	tag, err := api.VersionFromString(ctx.Params().GetString("tag"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	var body struct{
+		Stable bool `json:"stable"`
+	}
+	if err := ctx.ReadJSON(&body); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", err))
+		return
+	}
+	if err := apiIns.SetReleaseStable(id, tag, body.Stable); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(nil))
+}
+
+type adminSwitchBody struct{
+	Enabled *bool `json:"enabled"`
+	Stable  *bool `json:"stable"`
+}
+
+// readAdminSwitch reads the boolean field `enabled` or `stable` of the request body,
+// it stops the request with 400 if the body is invalid or the field is missing
+func readAdminSwitch(ctx iris.Context, field string)(value bool, ok bool){
+	var body adminSwitchBody
+	if err := ctx.ReadJSON(&body); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", err))
+		return
+	}
+	v := body.Enabled
+	if field == "stable" {
+		v = body.Stable
+	}
+	if v == nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", fmt.Errorf("missing field %q", field)))
+		return
+	}
+	return *v, true
+}
+
+func v1AdminPluginEnabled(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	enabled, ok := readAdminSwitch(ctx, "enabled")
+	if !ok {
+		return
+	}
+	if err := apiIns.SetPluginEnabled(id, enabled); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(nil))
+}
+
+func v1AdminPluginResync(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	if err := apiIns.ResyncPlugin(id); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(nil))
+}
+
+func v1AdminPluginPurgeCache(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	n, err := apiIns.PurgePluginCache(id)
+	if err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(iris.Map{
+		"removed": n,
+	}))
+}
+
+func v1AdminReleaseEnabled(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	tag, err := api.VersionFromString(ctx.Params().GetString("tag"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	enabled, ok := readAdminSwitch(ctx, "enabled")
+	if !ok {
+		return
+	}
+	if err := apiIns.SetReleaseEnabled(id, tag, enabled); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(nil))
+}
+
+func v1AdminReleaseStable(ctx iris.Context){
+	id := ctx.Params().GetString("id")
+	tag, err := api.VersionFromString(ctx.Params().GetString("tag"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("VersionFormatErr", err))
+		return
+	}
+	stable, ok := readAdminSwitch(ctx, "stable")
+	if !ok {
+		return
+	}
+	if err := apiIns.OverrideReleaseStable(id, tag, stable); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(nil))
+}
+
+func v1Webhooks(ctx iris.Context){
+	key := ctx.Values().Get("apiKey").(*api.APIKey)
+	hooks, err := apiIns.GetWebhooks(key.Id)
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	ctx.JSON(NewOkResp(hooks))
+}
+
+func v1WebhookCreate(ctx iris.Context){
+	key := ctx.Values().Get("apiKey").(*api.APIKey)
+	var hook api.Webhook
+	if err := ctx.ReadJSON(&hook); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("BodyFormatErr", err))
+		return
+	}
+	if err := api.CheckWebhook(&hook); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("InvalidWebhook", err))
+		return
+	}
+	if err := apiIns.CreateWebhook(key.Id, &hook); err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	ctx.StatusCode(iris.StatusCreated)
+	ctx.JSON(NewOkResp(hook))
+}
+
+func v1WebhookDelete(ctx iris.Context){
+	key := ctx.Values().Get("apiKey").(*api.APIKey)
+	id, _ := ctx.Params().GetInt64("hook")
+	if err := apiIns.DeleteWebhook(key.Id, id); err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(nil))
+}
+
+func v1WebhookDeliveries(ctx iris.Context){
+	key := ctx.Values().Get("apiKey").(*api.APIKey)
+	id, _ := ctx.Params().GetInt64("hook")
+	limit := ctx.URLParamIntDefault("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	deliveries, err := apiIns.GetWebhookDeliveries(key.Id, id, limit)
+	if err != nil {
+		writePublishErr(ctx, err)
+		return
+	}
+	ctx.JSON(NewOkResp(deliveries))
+}
+
+func v1ReleaseFeed(ctx iris.Context){
+	var (
+		opt api.ReleaseFeedOpt
+		title, page string
+	)
+	params := ctx.Params()
+	switch {
+	case params.Exists("id"):
+		opt.Plugin = params.GetString("id")
+		title, page = "Releases of " + opt.Plugin, "/plugin/" + opt.Plugin + "/"
+	case params.Exists("author"):
+		opt.Author = params.GetString("author")
+		title, page = "Releases of the plugins by " + opt.Author, "/author/" + url.PathEscape(opt.Author)
+	case params.Exists("label"):
+		opt.Label = strings.ToLower(params.GetString("label"))
+		title, page = "Releases of the " + opt.Label + " plugins", "/plugins"
+	default:
+		title, page = "Latest MCDR plugin releases", "/plugins"
+	}
+	opt.Limit = ctx.URLParamIntDefault("limit", api.FeedDefaultLimit)
+	items, err := apiIns.GetReleaseFeed(opt)
+	if err != nil {
+		if err == api.ErrNotFound {
+			ctx.StopWithJSON(iris.StatusNotFound, NewErrResp("NotFound", err))
+			return
+		}
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	if len(opt.Plugin) > 0 && len(items) > 0 {
+		title = "Releases of " + items[0].Plugin.Name
+	}
+	feed := api.NewReleaseFeed(sitePrefix, title, page, apiPrefix + ctx.Request().URL.RequestURI(), items, ctx.URLParam("lang"))
+	var (
+		data []byte
+		contentType string
+	)
+	if strings.HasSuffix(ctx.Path(), ".rss") {
+		data, err = feed.RSS()
+		contentType = "application/rss+xml; charset=utf-8"
+	}else{
+		data, err = feed.Atom()
+		contentType = "application/atom+xml; charset=utf-8"
+	}
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("FeedErr", err))
+		return
+	}
+	writeWithETag(ctx, data, contentType, feed.Updated)
+}
+
+// pollEvents publishes the new catalogue events to the event hub,
+// the events are written by other processes so the log has to be polled
+func pollEvents(loger logger.Logger, interval time.Duration){
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var since int64 = -1
+	for range ticker.C {
+		if since < 0 {
+			// only the events after the start are published
+			seq, err := apiIns.GetLastEventSeq()
+			if err != nil {
+				loger.Warnf("Cannot get the last event sequence: %v", err)
+				continue
+			}
+			since = seq
+			continue
+		}
+		for {
+			events, err := apiIns.GetCatalogueEvents(since, api.EventBacklogLimit)
+			if err != nil {
+				loger.Warnf("Cannot poll the catalogue events: %v", err)
+				break
+			}
+			if len(events) > 0 {
+				since = events[len(events) - 1].Seq
+				eventHub.Publish(events...)
+			}
+			if len(events) < api.EventBacklogLimit {
+				break
+			}
+		}
+	}
+}
+
+func v1Events(ctx iris.Context){
+	types, ok := api.ParseEventTypes(ctx.URLParam("types"))
+	if !ok {
+		ctx.StopWithJSON(iris.StatusBadRequest, ErrResp{
+			Status: "error",
+			Name: "InvalidEventType",
+			Msg: "Unknown event type in " + ctx.URLParam("types"),
+		})
+		return
+	}
+	plugin := ctx.URLParam("plugin")
+	if len(plugin) > 0 && !api.PluginIdRe.MatchString(plugin) {
+		ctx.StopWithJSON(iris.StatusBadRequest, ErrResp{
+			Status: "error",
+			Name: "InvalidPluginId",
+			Msg: "Invalid plugin id " + plugin,
+		})
+		return
+	}
+	// the browsers send the header when they reconnect, the url param is used to resume a new connection
+	lastId := ctx.GetHeader("Last-Event-ID")
+	if len(lastId) == 0 {
+		lastId = ctx.URLParam("lastEventId")
+	}
+	var (
+		sent int64
+		resume bool
+	)
+	if len(lastId) > 0 {
+		if sent, resume = api.ParseEventID(lastId); !resume {
+			ctx.StopWithJSON(iris.StatusBadRequest, ErrResp{
+				Status: "error",
+				Name: "InvalidEventId",
+				Msg: "Invalid last event id " + lastId,
+			})
+			return
+		}
+	}
+
+	sub, lastSeq := eventHub.Subscribe(types, plugin)
+	defer eventHub.Unsubscribe(sub)
+	if !resume {
+		sent = lastSeq
+	}
+
+	ctx.ContentType("text/event-stream")
+	ctx.Header("X-Accel-Buffering", "no")
+	w := ctx.ResponseWriter()
+	flush := func()(bool){
+		w.Flush()
+		return !ctx.IsCanceled()
+	}
+	fmt.Fprintf(w, "retry: %d\n\n", (eventPollInterval * 2).Milliseconds())
+	if !flush() {
+		return
+	}
+
+	// send the missed events in the log before the live ones,
+	// the live events that are already sent will be skipped
+	for resume {
+		events, err := apiIns.GetCatalogueEvents(sent, api.EventBacklogLimit)
+		if err != nil {
+			ctx.Application().Logger().Warnf("Cannot query the catalogue events: %v", err)
+			return
+		}
+		for _, ev := range events {
+			if sub.Match(ev) {
+				if err := api.WriteSSE(w, ev); err != nil {
+					return
+				}
+			}
+			sent = ev.Seq
+		}
+		if !flush() {
+			return
+		}
+		if len(events) < api.EventBacklogLimit {
+			break
+		}
+	}
+
+	pingTicker := time.NewTicker(eventPingInterval)
+	defer pingTicker.Stop()
+	timeout := time.NewTimer(eventStreamDuration)
+	defer timeout.Stop()
+	done := ctx.Request().Context().Done()
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				// the subscription is dropped, the client should reconnect and resume from the log
+				return
+			}
+			if ev.Seq <= sent {
+				continue
+			}
+			if err := api.WriteSSE(w, ev); err != nil {
+				return
+			}
+			sent = ev.Seq
+		case <-pingTicker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-timeout.C:
+			return
+		case <-done:
+			return
+		}
+		if !flush() {
+			return
+		}
+	}
+}
+
+func v1Changes(ctx iris.Context){
+	var since int64
+	if ctx.URLParamExists("since") {
+		var err error
+		if since, err = ctx.URLParamInt64("since"); err != nil || since < 0 {
+			ctx.StopWithJSON(iris.StatusBadRequest, ErrResp{
+				Status: "error",
+				Name: "InvalidCursor",
+				Msg: "Invalid change sequence " + ctx.URLParam("since"),
+			})
+			return
+		}
+	}
+	limit := ctx.URLParamIntDefault("limit", api.ChangesDefaultLimit)
+	if limit <= 0 || limit > api.ChangesMaxLimit {
+		limit = api.ChangesDefaultLimit
+	}
+	changes, err := apiIns.GetChanges(since, limit)
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	ctx.JSON(NewOkResp(api.NewChangesPage(since, changes, limit)))
+}
+
+// writeWithETag writes the data with the ETag of its content, and responds 304 if the client has the same one.
+// If-Modified-Since is only checked if If-None-Match is not sent
+func writeWithETag(ctx iris.Context, data []byte, contentType string, modTime time.Time){
+	etag := api.ContentETag(data)
+	ctx.Header("ETag", etag)
+	if !modTime.IsZero() {
+		ctx.SetLastModified(modTime)
+	}
+	if inm := ctx.GetHeader("If-None-Match"); len(inm) > 0 {
+		if api.MatchETag(inm, etag) {
+			ctx.WriteNotModified()
+			return
+		}
+	}else if !modTime.IsZero() {
+		if modified, err := ctx.CheckIfModifiedSince(modTime); !modified && err == nil {
+			ctx.WriteNotModified()
+			return
+		}
+	}
+	ctx.ContentType(contentType)
+	ctx.Write(data)
+}
+
+// writeJSONWithETag writes the ok response of the data with a content-hash ETag
+func writeJSONWithETag(ctx iris.Context, data any){
+	body, err := json.Marshal(NewOkResp(data))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("JsonEncodeErr", err))
+		return
+	}
+	writeWithETag(ctx, body, "application/json; charset=utf-8", time.Time{})
+}
+
+// negotiateFormat returns the response format selected by the `format` parameter or the Accept header,
+// the error response is written if the parameter is invalid
+func negotiateFormat(ctx iris.Context)(format string, ok bool){
+	api.AddVary(ctx.ResponseWriter().Header(), "Accept")
+	format, err := api.NegotiateFormat(ctx.GetHeader("Accept"), ctx.URLParamTrim("format"))
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("FormatErr", err))
+		return "", false
+	}
+	return format, true
+}
+
+// writeFormatWithETag writes the data in the format with a content-hash ETag,
+// only the json is wrapped in the ok response
+func writeFormatWithETag(ctx iris.Context, format string, data any){
+	if format == api.FormatJSON {
+		writeJSONWithETag(ctx, data)
+		return
+	}
+	body, err := api.MarshalFormat(format, data)
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("EncodeErr", err))
+		return
+	}
+	writeWithETag(ctx, body, api.FormatContentTypes[format], time.Time{})
+}
+
+// cacheControl sets the Cache-Control policy of the route, see api.NoStoreOnError for the errors
+func cacheControl(policy string)(iris.Handler){
+	return func(ctx iris.Context){
+		// replace the default policy, ctx.Header adds a new value
+		ctx.ResponseWriter().Header().Set(irisContext.CacheControlHeaderKey, policy)
+		ctx.Next()
+	}
+}
+
+func v1Sitemap(ctx iris.Context){
+	plugins, err := apiIns.GetSitemapPlugins()
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", err))
+		return
+	}
+	urls := api.BuildSitemap(sitePrefix, plugins)
+	pages := api.SitemapPages(len(urls))
+	var data []byte
+	if ctx.URLParamExists("page") {
+		if urls = api.SitemapPage(urls, ctx.URLParamIntDefault("page", 0)); urls == nil {
+			ctx.StopWithJSON(iris.StatusNotFound, ErrResp{
+				Status: "error",
+				Name: "NotFound",
+				Msg: "Sitemap page " + ctx.URLParam("page") + " not found",
+			})
+			return
+		}
+		data, err = api.MarshalSitemap(urls)
+	}else if pages > 1 {
+		locs := make([]string, pages)
+		mods := make([]time.Time, pages)
+		for i := range locs {
+			locs[i] = fmt.Sprintf("%s/sitemap.xml?page=%d", apiPrefix, i + 1)
+			mods[i] = api.LatestSitemapMod(api.SitemapPage(urls, i + 1))
+		}
+		data, err = api.MarshalSitemapIndex(locs, mods)
+	}else{
+		data, err = api.MarshalSitemap(urls)
+	}
+	if err != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("SitemapErr", err))
+		return
+	}
+	writeWithETag(ctx, data, "application/xml; charset=utf-8", api.LatestSitemapMod(urls))
+}
+
+// maxGraphQLRequestSize is the max size of the GraphQL request body
+const maxGraphQLRequestSize = 64 * 1024
+
+func v1GraphQL(ctx iris.Context){
+	var req graphql.Request
+	if ctx.Method() == http.MethodGet {
+		req.Query = ctx.URLParam("query")
+		req.OperationName = ctx.URLParam("operationName")
+		if vars := ctx.URLParam("variables"); len(vars) > 0 {
+			if err := json.Unmarshal(([]byte)(vars), &req.Variables); err != nil {
+				ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("JsonDecodeErr", err))
+				return
+			}
+		}
+	}else{
+		ctx.SetMaxRequestBodySize(maxGraphQLRequestSize)
+		body, err := ctx.GetBody()
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusRequestEntityTooLarge, NewErrResp("TooLarge", err))
+			return
+		}
+		if strings.HasPrefix(ctx.GetHeader("Content-Type"), "application/graphql") {
+			req.Query = (string)(body)
+		}else if err = json.Unmarshal(body, &req); err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, NewErrResp("JsonDecodeErr", err))
+			return
+		}
+	}
+	if len(req.Query) == 0 {
+		ctx.StopWithJSON(iris.StatusBadRequest, ErrResp{
+			Status: "error",
+			Name: "BodyFormatErr",
+			Msg: "The GraphQL query is required",
+		})
+		return
+	}
+	ctx.JSON(graphql.ExecuteCatalogue(ctx.Request().Context(), apiIns, &req))
+}
+
+func v1GraphQLSchema(ctx iris.Context){
+	writeWithETag(ctx, ([]byte)(graphql.SDL()), "text/plain; charset=utf-8", time.Time{})
 }
@@ -0,0 +1,202 @@
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api/graphql"
+)
+
+var pluginListParams = []api.OpenAPIParam{
+	{ Name: "filterBy", In: "query", Desc: "The keyword to search in the plugin's id, name, authors and description" },
+	{ Name: "tags", In: "query", Desc: "The comma separated labels which the plugins must have" },
+	{ Name: "sortBy", In: "query", Desc: "The field to sort the plugins" },
+	{ Name: "reversed", In: "query", Type: "boolean" },
+	{ Name: "offset", In: "query", Type: "integer" },
+	{ Name: "limit", In: "query", Type: "integer" },
+}
+
+// publishErrors are the errors of the routes which require an API key, see writePublishErr
+var publishErrors = []int{400, 401, 403, 404, 409, 413, 500}
+
+// responseFormats are the formats of the list and the information routes besides json
+var responseFormats = []string{api.FormatNDJSON, api.FormatCSV, api.FormatYAML}
+
+var renderParam = api.OpenAPIParam{ Name: "render", In: "query", Type: "boolean", Desc: "Render the markdown as html" }
+
+var feedParams = []api.OpenAPIParam{
+	{ Name: "limit", In: "query", Type: "integer" },
+	{ Name: "lang", In: "query", Enum: api.SitemapLangs, Desc: "The language of the plugin descriptions" },
+}
+
+type downloadStatsResult struct{
+	Granularity string              `json:"granularity"`
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+	Series      []*api.DownloadStat `json:"series"`
+}
+
+type purgeCacheResult struct{
+	Removed int `json:"removed"`
+}
+
+type publishStableBody struct{
+	Stable bool `json:"stable"`
+}
+
+type adminEnabledBody struct{
+	Enabled bool `json:"enabled"`
+}
+
+type adminStableBody struct{
+	Stable bool `json:"stable"`
+}
+
+var v1Operations = []*api.OpenAPIOperation{
+	{ Method: http.MethodGet, Path: "/", Summary: "Get the status of the API", ResultType: "application/json" },
+
+	{ Method: http.MethodGet, Path: "/plugins", Summary: "List the plugins", Tag: "plugins",
+		Params: pluginListParams, Result: []*api.PluginInfo{}, Formats: responseFormats, Errors: []int{400, 500} },
+	{ Method: http.MethodGet, Path: "/plugins/ids", Summary: "List the ids of the plugins", Tag: "plugins",
+		Params: pluginListParams, Result: []string{}, Formats: responseFormats, Errors: []int{400, 500} },
+	{ Method: http.MethodGet, Path: "/plugins/count", Summary: "Count the plugins", Tag: "plugins",
+		Params: pluginListParams, Result: api.PluginCounts{}, Errors: []int{400, 500} },
+	{ Method: http.MethodGet, Path: "/plugins/sitemap.txt", Summary: "List the urls of the plugin pages", Tag: "plugins",
+		Params: pluginListParams, ResultType: "text/plain", Errors: []int{400, 500} },
+	{ Method: http.MethodPost, Path: "/plugins/batch", Summary: "Look up many plugins at once", Tag: "plugins",
+		Body: &api.BatchOpt{}, Result: []*api.BatchResult{}, Errors: []int{400, 413} },
+
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/info", Summary: "Get the information of the plugin", Tag: "plugin",
+		Result: &api.PluginInfo{}, Formats: responseFormats, Errors: []int{400, 404, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/readme", Summary: "Get the README of the plugin", Tag: "plugin",
+		Params: []api.OpenAPIParam{renderParam}, ResultType: "text/plain, text/html", Errors: []int{404, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/releases", Summary: "List the releases of the plugin", Tag: "plugin",
+		Result: []*api.PluginRelease{}, Formats: responseFormats, Errors: []int{400, 404, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/stats/downloads", Summary: "Get the download statistics of the plugin", Tag: "plugin",
+		Params: []api.OpenAPIParam{
+			{ Name: "from", In: "query", Desc: "The start date, default is 30 days before `to`" },
+			{ Name: "to", In: "query", Desc: "The end date, default is now" },
+			{ Name: "granularity", In: "query", Enum: []string{api.GranularityDay, api.GranularityWeek, api.GranularityMonth} },
+			{ Name: "tag", In: "query", Desc: "Only count the downloads of the release" },
+			{ Name: "filename", In: "query", Desc: "Only count the downloads of the main file or the asset" },
+		}, Result: &downloadStatsResult{}, Errors: []int{400, 404, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/changelog", Summary: "Get the changelogs of the releases", Tag: "plugin",
+		Params: []api.OpenAPIParam{
+			{ Name: "from", In: "query", Desc: "The oldest version to include" },
+			{ Name: "to", In: "query", Desc: "The newest version to include" },
+			renderParam,
+		}, ResultType: "text/plain, text/html", Errors: []int{400, 404, 500} },
+
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/release/{tag:string version()}", Summary: "Get the release", Tag: "release",
+		Result: &api.PluginRelease{}, Errors: []int{400, 404, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/release/{tag:string version()}/asset/{filename:file}", Summary: "Download the asset of the release", Tag: "release",
+		ResultType: "application/octet-stream", Errors: []int{400, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/release/{tag:string version()}/changelog", Summary: "Get the changelog of the release", Tag: "release",
+		Params: []api.OpenAPIParam{renderParam}, ResultType: "text/plain, text/html", Errors: []int{400, 404, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/release/{tag:string version()}/meta", Summary: "Get the metadata in the package of the release", Tag: "release",
+		Result: &api.McdrInfo{}, Errors: []int{400, 404, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/release/{tag:string version()}/files", Summary: "List the files in the package of the release", Tag: "release",
+		Result: []*api.McdrFile{}, Errors: []int{400, 404, 500} },
+	{ Method: http.MethodGet, Path: "/plugin/{id:string pid()}/release/{tag:string version()}/files/{path:path}", Summary: "Get a file in the package of the release", Tag: "release",
+		Params: []api.OpenAPIParam{renderParam}, ResultType: "application/octet-stream, text/html", Errors: []int{400, 404, 422, 500} },
+
+	{ Method: http.MethodGet, Path: "/sitemap.xml", Summary: "Get the sitemap of the site, or the sitemap index if there are too many urls", Tag: "mirror",
+		Params: []api.OpenAPIParam{{ Name: "page", In: "query", Type: "integer" }}, ResultType: "application/xml", Errors: []int{404, 500} },
+	{ Method: http.MethodGet, Path: "/changes", Summary: "List the changes of the catalogue after the sequence", Tag: "mirror",
+		Params: []api.OpenAPIParam{
+			{ Name: "since", In: "query", Type: "integer", Desc: "The `next` of the previous page" },
+			{ Name: "limit", In: "query", Type: "integer" },
+		}, Result: &api.ChangesPage{}, Errors: []int{400, 500} },
+	{ Method: http.MethodGet, Path: "/events", Summary: "Stream the catalogue events as server-sent events", Tag: "mirror",
+		Params: []api.OpenAPIParam{
+			{ Name: "types", In: "query", Desc: "The comma separated event types" },
+			{ Name: "plugin", In: "query" },
+			{ Name: "lastEventId", In: "query", Type: "integer", Desc: "Same as the `Last-Event-ID` header" },
+			{ Name: "Last-Event-ID", In: "header", Type: "integer" },
+		}, ResultType: "text/event-stream", Errors: []int{400, 500} },
+
+	{ Method: http.MethodGet, Path: "/feeds/releases.atom", Summary: "Get the feed of the releases", Tag: "feeds",
+		Params: feedParams, ResultType: "application/atom+xml", Errors: []int{500} },
+	{ Method: http.MethodGet, Path: "/feeds/releases.rss", Summary: "Get the feed of the releases", Tag: "feeds",
+		Params: feedParams, ResultType: "application/rss+xml", Errors: []int{500} },
+	{ Method: http.MethodGet, Path: "/feeds/plugin/{id:string pid()}/releases.atom", Summary: "Get the feed of the plugin's releases", Tag: "feeds",
+		Params: feedParams, ResultType: "application/atom+xml", Errors: []int{500} },
+	{ Method: http.MethodGet, Path: "/feeds/plugin/{id:string pid()}/releases.rss", Summary: "Get the feed of the plugin's releases", Tag: "feeds",
+		Params: feedParams, ResultType: "application/rss+xml", Errors: []int{500} },
+	{ Method: http.MethodGet, Path: "/feeds/author/{author:string}/releases.atom", Summary: "Get the feed of the author's releases", Tag: "feeds",
+		Params: feedParams, ResultType: "application/atom+xml", Errors: []int{500} },
+	{ Method: http.MethodGet, Path: "/feeds/author/{author:string}/releases.rss", Summary: "Get the feed of the author's releases", Tag: "feeds",
+		Params: feedParams, ResultType: "application/rss+xml", Errors: []int{500} },
+	{ Method: http.MethodGet, Path: "/feeds/label/{label:string}/releases.atom", Summary: "Get the feed of the releases with the label", Tag: "feeds",
+		Params: feedParams, ResultType: "application/atom+xml", Errors: []int{500} },
+	{ Method: http.MethodGet, Path: "/feeds/label/{label:string}/releases.rss", Summary: "Get the feed of the releases with the label", Tag: "feeds",
+		Params: feedParams, ResultType: "application/rss+xml", Errors: []int{500} },
+
+	{ Method: http.MethodGet, Path: "/graphql", Summary: "Run a GraphQL query over the plugin catalogue", Tag: "graphql",
+		Params: []api.OpenAPIParam{
+			{ Name: "query", In: "query", Required: true },
+			{ Name: "operationName", In: "query" },
+			{ Name: "variables", In: "query", Desc: "The variables encoded in JSON" },
+		}, ResultType: "application/json", Errors: []int{400} },
+	{ Method: http.MethodPost, Path: "/graphql", Summary: "Run a GraphQL query over the plugin catalogue", Tag: "graphql",
+		Body: &graphql.Request{}, ResultType: "application/json", Errors: []int{400, 413} },
+	{ Method: http.MethodGet, Path: "/graphql/schema.graphql", Summary: "Get the GraphQL schema in SDL", Tag: "graphql",
+		ResultType: "text/plain" },
+
+	{ Method: http.MethodPost, Path: "/publish/plugin/{id:string pid()}", Summary: "Create the plugin", Tag: "publish", Auth: api.ScopePublish,
+		Body: &api.PluginMetaUpdate{}, Status: http.StatusCreated, Errors: publishErrors },
+	{ Method: http.MethodPatch, Path: "/publish/plugin/{id:string pid()}/info", Summary: "Update the metadata of the plugin", Tag: "publish", Auth: api.ScopePublish,
+		Body: &api.PluginMetaUpdate{}, Errors: publishErrors },
+	{ Method: http.MethodPut, Path: "/publish/plugin/{id:string pid()}/readme", Summary: "Replace the README of the plugin", Tag: "publish", Auth: api.ScopePublish,
+		BodyType: "text/markdown", Errors: publishErrors },
+	{ Method: http.MethodPost, Path: "/publish/plugin/{id:string pid()}/release/{tag:string version()}", Summary: "Publish a release of the plugin", Tag: "publish", Auth: api.ScopePublish,
+		BodyType: "multipart/form-data", Result: &api.PluginRelease{}, Status: http.StatusCreated, Errors: publishErrors },
+	{ Method: http.MethodPut, Path: "/publish/plugin/{id:string pid()}/release/{tag:string version()}/stable", Summary: "Mark the release as stable or prerelease", Tag: "publish", Auth: api.ScopePublish,
+		Body: &publishStableBody{}, Errors: publishErrors },
+
+	{ Method: http.MethodPut, Path: "/admin/plugin/{id:string pid()}/enabled", Summary: "Enable or disable the plugin", Tag: "admin", Auth: api.ScopeAdmin,
+		Body: &adminEnabledBody{}, Errors: publishErrors },
+	{ Method: http.MethodPost, Path: "/admin/plugin/{id:string pid()}/resync", Summary: "Sync the plugin from its repository", Tag: "admin", Auth: api.ScopeAdmin,
+		Errors: publishErrors },
+	{ Method: http.MethodDelete, Path: "/admin/plugin/{id:string pid()}/cache", Summary: "Remove the cached assets of the plugin", Tag: "admin", Auth: api.ScopeAdmin,
+		Result: &purgeCacheResult{}, Errors: publishErrors },
+	{ Method: http.MethodPut, Path: "/admin/plugin/{id:string pid()}/release/{tag:string version()}/enabled", Summary: "Enable or disable the release", Tag: "admin", Auth: api.ScopeAdmin,
+		Body: &adminEnabledBody{}, Errors: publishErrors },
+	{ Method: http.MethodPut, Path: "/admin/plugin/{id:string pid()}/release/{tag:string version()}/stable", Summary: "Override whether the release is stable", Tag: "admin", Auth: api.ScopeAdmin,
+		Body: &adminStableBody{}, Errors: publishErrors },
+
+	{ Method: http.MethodGet, Path: "/webhooks", Summary: "List the webhooks of the API key", Tag: "webhooks", Auth: api.ScopeWebhook,
+		Result: []*api.Webhook{}, Errors: []int{401, 403, 500} },
+	{ Method: http.MethodPost, Path: "/webhooks", Summary: "Create a webhook", Tag: "webhooks", Auth: api.ScopeWebhook,
+		Body: &api.Webhook{}, Result: &api.Webhook{}, Status: http.StatusCreated, Errors: []int{400, 401, 403, 500} },
+	{ Method: http.MethodDelete, Path: "/webhooks/{hook:int64}", Summary: "Delete the webhook", Tag: "webhooks", Auth: api.ScopeWebhook,
+		Errors: []int{401, 403, 404, 500} },
+	{ Method: http.MethodGet, Path: "/webhooks/{hook:int64}/deliveries", Summary: "List the recent deliveries of the webhook", Tag: "webhooks", Auth: api.ScopeWebhook,
+		Params: []api.OpenAPIParam{{ Name: "limit", In: "query", Type: "integer" }}, Result: []*api.WebhookDelivery{}, Errors: []int{401, 403, 404, 500} },
+
+	{ Method: http.MethodGet, Path: "/openapi.json", Summary: "Get this document", ResultType: "application/json" },
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIData []byte
+	openAPIErr  error
+	openAPITime = time.Now()
+)
+
+func v1OpenAPI(ctx iris.Context){
+	openAPIOnce.Do(func(){
+		doc := api.NewOpenAPI("PluginWebPoint V1 API", "1", apiPrefix, v1Operations)
+		openAPIData, openAPIErr = json.MarshalIndent(doc, "", "  ")
+	})
+	if openAPIErr != nil {
+		ctx.StopWithJSON(iris.StatusInternalServerError, NewErrResp("ApiErr", openAPIErr))
+		return
+	}
+	writeWithETag(ctx, openAPIData, "application/json; charset=utf-8", openAPITime)
+}
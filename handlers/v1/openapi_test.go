@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmcsr/PluginWebPoint/api"
+)
+
+func TestOpenAPIRoutes(t *testing.T){
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatalf("Cannot build the router: %v", err)
+	}
+
+	documented := make(map[string]bool)
+	for _, op := range v1Operations {
+		path, _ := api.OpenAPIPath(op.Path)
+		key := op.Method + " " + path
+		if documented[key] {
+			t.Errorf("Operation %s is documented twice", key)
+		}
+		documented[key] = true
+	}
+
+	registered := make(map[string]bool)
+	for _, r := range app.GetRoutes() {
+		// skip the error handlers and the routes registered by HandleMany for HEAD
+		if r.StatusCode != 0 || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			continue
+		}
+		path, _ := api.OpenAPIPath(r.Tmpl().Src)
+		key := r.Method + " " + path
+		registered[key] = true
+		if !documented[key] {
+			t.Errorf("Route %s is not documented in the OpenAPI operations", key)
+		}
+	}
+	for key := range documented {
+		if !registered[key] {
+			t.Errorf("Operation %s is documented but not registered", key)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"embed"
@@ -9,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"mime"
 	"net"
 	"net/http"
 	"os"
@@ -16,13 +18,12 @@ import (
 	"path"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
 	"github.com/kmcsr/go-logger"
 	"github.com/kmcsr/go-logger/logrus"
-	// "github.com/kmcsr/PluginWebPoint/api"
+	"github.com/kmcsr/PluginWebPoint/api"
 	// "github.com/kmcsr/PluginWebPoint/api/mysqlimpl"
 )
 
@@ -79,7 +80,11 @@ func main(){
 		indexFile = fd.(io.ReadSeeker)
 	}
 
-	http.Handle("/assets/", http.StripPrefix("/assets", NewConstFileServer(assetsFS, now)))
+	assetsServer, err := NewConstFileServer(assetsFS, now)
+	if err != nil {
+		loger.Fatalf("Couldn't load the assets: %v", err)
+	}
+	http.Handle("/assets/", http.StripPrefix("/assets", assetsServer))
 	http.Handle("/", HandleConstData(indexFile, time.Time{}, "index.html"))
 
 	server := &http.Server{
@@ -110,40 +115,120 @@ func main(){
 	}
 }
 
+// constFile is a file that never changes, its compressed variants are prepared when it's loaded
+type constFile struct{
+	name string
+	modTime time.Time
+	contentType string
+	etag string
+	data []byte
+	encodings []string // the available encodings, in the order of preference
+	variants map[string][]byte
+}
+
+func newConstFile(name string, data []byte, modTime time.Time, withEtag bool)(f *constFile){
+	f = &constFile{
+		name: name,
+		modTime: modTime,
+		contentType: mime.TypeByExtension(path.Ext(name)),
+		data: data,
+		variants: make(map[string][]byte),
+	}
+	if len(f.contentType) == 0 {
+		f.contentType = http.DetectContentType(data)
+	}
+	if withEtag {
+		f.etag = fmt.Sprintf(`"sha256:%x"`, sha256.Sum256(data))
+	}
+	if len(data) < api.CompressMinSize || !api.IsCompressibleType(f.contentType) {
+		return
+	}
+	for _, enc := range api.Encodings {
+		buf, err := api.CompressBytes(data, enc)
+		if err != nil {
+			loger.Errorf("Cannot compress %s with %s: %v", name, enc, err)
+			continue
+		}
+		if len(buf) < len(data) {
+			f.encodings = append(f.encodings, enc)
+			f.variants[enc] = buf
+		}
+	}
+	return
+}
+
+func (f *constFile)ServeHTTP(rw http.ResponseWriter, req *http.Request){
+	data, etag := f.data, f.etag
+	header := rw.Header()
+	if len(f.encodings) > 0 {
+		api.AddVary(header, "Accept-Encoding")
+		if enc := api.NegotiateEncoding(req.Header.Get("Accept-Encoding"), f.encodings); len(enc) > 0 {
+			data = f.variants[enc]
+			header.Set("Content-Encoding", enc)
+			if len(etag) > 0 {
+				etag = etag[:len(etag) - 1] + "-" + enc + `"`
+			}
+		}
+	}
+	header.Set("Content-Type", f.contentType)
+	if len(etag) > 0 {
+		header.Set("Etag", etag)
+	}
+	http.ServeContent(rw, req, f.name, f.modTime, bytes.NewReader(data))
+}
+
 func HandleConstData(data io.ReadSeeker, modTime time.Time, name string)(http.Handler){
-	h := sha256.New()
 	_, err := data.Seek(0, io.SeekStart)
 	if err != nil {
 		loger.Panic(err)
 	}
-	if _, err = io.Copy(h, data); err != nil {
+	buf, err := io.ReadAll(data)
+	if err != nil {
 		loger.Panic(err)
 	}
-	etag := fmt.Sprintf(`"sha256:%x"`, h.Sum(nil))
+	file := newConstFile(name, buf, modTime, true)
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request){
 		loger.Debugf("Conn from %s;%s;%s", req.RemoteAddr, req.Method, req.URL.RawPath)
 		if req.Method != "GET" {
 			http.Error(rw, "Request method not allowed, only allows GET", http.StatusMethodNotAllowed)
 			return
 		}
-		rw.Header().Set("Etag", etag)
-		http.ServeContent(rw, req, name, modTime, data)
+		file.ServeHTTP(rw, req)
 	})
 }
 
+// ConstFileServer serves the files in root, which are loaded and compressed once when it's created
 type ConstFileServer struct{
-	root fs.FS
-	modTime time.Time
-	mux sync.RWMutex
-	etags map[string]string
+	files map[string]*constFile
 }
 
-func NewConstFileServer(root fs.FS, modTime time.Time)(*ConstFileServer){
-	return &ConstFileServer{
-		root: root,
-		modTime: modTime,
-		etags: make(map[string]string),
+func NewConstFileServer(root fs.FS, modTime time.Time)(s *ConstFileServer, err error){
+	s = &ConstFileServer{
+		files: make(map[string]*constFile),
+	}
+	err = fs.WalkDir(root, ".", func(name string, d fs.DirEntry, err error)(error){
+		if err != nil {
+			if name == "." && errors.Is(err, fs.ErrNotExist) {
+				// there is no asset
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := fs.ReadFile(root, name)
+		if err != nil {
+			return err
+		}
+		// Doesn't need Etag, since vite use filename with hash suffix
+		s.files["/" + name] = newConstFile(path.Base(name), data, modTime, false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return
 }
 
 func (s *ConstFileServer)ServeHTTP(rw http.ResponseWriter, req *http.Request){
@@ -158,37 +243,12 @@ func (s *ConstFileServer)ServeHTTP(rw http.ResponseWriter, req *http.Request){
 		req.URL.Path = name
 	}
 	name = path.Clean(name)
-	fd, err := s.root.Open(name[1:])
-	if err != nil {
-		code := http.StatusInternalServerError
-		if errors.Is(err, fs.ErrPermission) {
-			code = http.StatusForbidden
-		}else if errors.Is(err, fs.ErrNotExist) {
-			code = http.StatusNotFound
-		}else{
-			loger.Errorf("%s:%d: %v", req.URL.Path, code, err)
-		}
+	file, ok := s.files[name]
+	if !ok {
+		code := http.StatusNotFound
 		http.Error(rw, fmt.Sprintf("%d: %s", code, http.StatusText(code)), code)
 		return
 	}
-	// Doesn't need Etag, since vite use filename with hash suffix
 	rw.Header().Set("Cache-Control", "max-age=108000") // A month
-	// s.mux.RLock()
-	// etag, ok := s.etags[name]
-	// s.mux.RUnlock()
-	// if !ok {
-	// 	h := sha256.New()
-	// 	if _, err := io.Copy(h, fd); err == nil {
-	// 		etag = fmt.Sprintf(`"sha256:%x"`, h.Sum(nil))
-	// 		s.mux.Lock()
-	// 		s.etags[name] = etag
-	// 		s.mux.Unlock()
-	// 	}else{
-	// 		loger.Errorf("%s: Cannot calc etag: %v", req.URL.Path, err)
-	// 	}
-	// }
-	// if len(etag) > 0 {
-	// 	rw.Header().Set("Etag", etag)
-	// }
-	http.ServeContent(rw, req, name, s.modTime, fd.(io.ReadSeeker))
+	file.ServeHTTP(rw, req)
 }
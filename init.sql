@@ -69,3 +69,183 @@ ALTER TABLE plugin_releases ADD `filename` VARCHAR(64) NOT NULL;
 ALTER TABLE plugin_releases ADD `downloads` INTEGER UNSIGNED DEFAULT 0 NOT NULL;
 
 ALTER TABLE plugin_releases ADD `github_url` VARCHAR(256) DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS plugin_downloads (
+	`id`    VARCHAR(64) NOT NULL,
+	`tag`   VARCHAR(32) NOT NULL,
+	`day`   DATE NOT NULL,
+	`count` INTEGER UNSIGNED DEFAULT 0 NOT NULL,
+	PRIMARY KEY (`id`, `tag`, `day`),
+	CONSTRAINT download_plugin_id FOREIGN KEY (`id`)
+	REFERENCES plugins(`id`)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE plugins ADD `score_trending` DOUBLE DEFAULT 0 NOT NULL;
+ALTER TABLE plugins ADD `score_popular` DOUBLE DEFAULT 0 NOT NULL;
+
+CREATE TABLE IF NOT EXISTS plugin_release_snapshots (
+	`id`        VARCHAR(64) NOT NULL,
+	`tag`       VARCHAR(32) NOT NULL,
+	`time`      DATETIME NOT NULL,
+	`downloads` INTEGER UNSIGNED NOT NULL,
+	PRIMARY KEY (`id`, `tag`, `time`),
+	CONSTRAINT snapshot_plugin_id FOREIGN KEY (`id`)
+	REFERENCES plugins(`id`)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE plugin_releases ADD `name` VARCHAR(256) DEFAULT '' NOT NULL;
+ALTER TABLE plugin_releases ADD `changelog` TEXT DEFAULT NULL;
+
+ALTER TABLE plugin_releases ADD `sha256` CHAR(64) DEFAULT NULL;
+ALTER TABLE plugin_releases ADD `sha1` CHAR(40) DEFAULT NULL;
+
+ALTER TABLE plugin_releases ADD `mcdr_meta` MEDIUMTEXT DEFAULT NULL;
+ALTER TABLE plugin_releases ADD `problems` TEXT DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS plugin_release_assets (
+	`id`              VARCHAR(64) NOT NULL,
+	`tag`             VARCHAR(32) NOT NULL,
+	`name`            VARCHAR(256) NOT NULL,
+	`size`            BIGINT UNSIGNED NOT NULL,
+	`content_type`    VARCHAR(128) DEFAULT '' NOT NULL,
+	`uploaded`        DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	`downloads`       INTEGER UNSIGNED DEFAULT 0 NOT NULL,
+	`local_downloads` INTEGER UNSIGNED DEFAULT 0 NOT NULL,
+	`github_url`      VARCHAR(256) DEFAULT NULL,
+	`sha256`          CHAR(64) DEFAULT NULL,
+	`sha1`            CHAR(40) DEFAULT NULL,
+	PRIMARY KEY (`id`, `tag`, `name`),
+	CONSTRAINT asset_release FOREIGN KEY (`id`, `tag`)
+	REFERENCES plugin_releases(`id`, `tag`)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	`id`          VARCHAR(16) NOT NULL,
+	`name`        VARCHAR(64) NOT NULL,
+	`secret_hash` CHAR(64) NOT NULL,
+	`scopes`      VARCHAR(256) DEFAULT '' NOT NULL,
+	`createAt`    DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	`lastUsed`    DATETIME DEFAULT NULL,
+	`revoked`     BOOLEAN DEFAULT FALSE NOT NULL,
+	PRIMARY KEY (`id`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- the values set by the admins are kept across github syncs
+ALTER TABLE plugins ADD `enabled_locked` BOOLEAN DEFAULT FALSE NOT NULL;
+ALTER TABLE plugin_releases ADD `stable_locked` BOOLEAN DEFAULT FALSE NOT NULL;
+-- the synced releases were stored with `stable` set to the prerelease flag, flip them along with the column above,
+-- so it runs only once
+UPDATE plugin_releases AS r JOIN plugins AS p ON r.`id`=p.`id`
+	SET r.`stable`=NOT r.`stable` WHERE p.`github_sync`=TRUE AND r.`stable_locked`=FALSE;
+
+-- the requests allowed per minute of the key, 0 means the default limit
+ALTER TABLE api_keys ADD `quota` INT UNSIGNED DEFAULT 0 NOT NULL;
+
+CREATE TABLE IF NOT EXISTS api_key_usage (
+	`id`       VARCHAR(16) NOT NULL,
+	`day`      DATE NOT NULL,
+	`requests` BIGINT UNSIGNED DEFAULT 0 NOT NULL,
+	PRIMARY KEY (`id`, `day`),
+	CONSTRAINT usage_key FOREIGN KEY (`id`)
+	REFERENCES api_keys(`id`)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- the catalogue events detected by ghupdater, which are sent to the webhooks
+CREATE TABLE IF NOT EXISTS catalogue_events (
+	`seq`  BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+	`type` VARCHAR(32) NOT NULL,
+	`id`   VARCHAR(64) NOT NULL,
+	`tag`  VARCHAR(32) DEFAULT NULL,
+	`time` DATETIME NOT NULL,
+	`data` TEXT DEFAULT NULL,
+	PRIMARY KEY (`seq`),
+	INDEX (`id`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS webhooks (
+	`id`       BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+	`owner`    VARCHAR(16) NOT NULL,
+	`url`      VARCHAR(512) NOT NULL,
+	`secret`   VARCHAR(64) NOT NULL,
+	`events`   VARCHAR(256) DEFAULT '' NOT NULL,
+	`createAt` DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	PRIMARY KEY (`id`),
+	CONSTRAINT webhook_owner FOREIGN KEY (`owner`)
+	REFERENCES api_keys(`id`)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	`id`           BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+	`webhook`      BIGINT UNSIGNED NOT NULL,
+	`event`        BIGINT UNSIGNED NOT NULL,
+	`status`       ENUM('pending', 'ok', 'failed') DEFAULT 'pending' NOT NULL,
+	`attempts`     INT DEFAULT 0 NOT NULL,
+	`last_code`    INT DEFAULT NULL,
+	`last_error`   VARCHAR(256) DEFAULT NULL,
+	`next_attempt` DATETIME DEFAULT NULL,
+	`updated`      DATETIME NOT NULL,
+	PRIMARY KEY (`id`),
+	INDEX (`status`, `next_attempt`),
+	CONSTRAINT delivery_webhook FOREIGN KEY (`webhook`)
+	REFERENCES webhooks(`id`)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE,
+	CONSTRAINT delivery_event FOREIGN KEY (`event`)
+	REFERENCES catalogue_events(`seq`)
+	ON DELETE CASCADE
+	ON UPDATE CASCADE
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- for the release feeds
+ALTER TABLE plugin_releases ADD INDEX (`uploaded`);
+
+-- the change log for the mirrors, only the latest change of each plugin and release is kept
+CREATE TABLE IF NOT EXISTS catalogue_changes (
+	`seq`  BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+	`kind` ENUM('plugin', 'release') NOT NULL,
+	`op`   ENUM('upsert', 'delete') NOT NULL,
+	`id`   VARCHAR(64) NOT NULL,
+	`tag`  VARCHAR(32) DEFAULT '' NOT NULL,
+	`hash` CHAR(40) DEFAULT NULL,
+	`time` DATETIME NOT NULL,
+	PRIMARY KEY (`seq`),
+	UNIQUE KEY (`id`, `tag`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- the existing plugins and releases, the hashes must be same as the ones in api/ghsync/changes.go,
+-- so they are not reported as changed by the next sync
+INSERT IGNORE INTO catalogue_changes (`kind`,`op`,`id`,`tag`,`hash`,`time`)
+	SELECT 'plugin','upsert',p.`id`,'',
+	SHA1(CONCAT_WS(0x1f,p.`name`,p.`version`,p.`authors`,p.`desc`,p.`desc_zhCN`,p.`repo`,p.`repo_branch`,p.`repo_subdir`,p.`link`,p.`label_information`,p.`label_tool`,p.`label_management`,p.`label_api`,p.`lastRelease`,(SELECT GROUP_CONCAT(`target`,'@',`tag` ORDER BY `target`) FROM plugin_dependencies WHERE `id`=p.`id`),(SELECT GROUP_CONCAT(`target`,'@',`tag` ORDER BY `target`) FROM plugin_requirements WHERE `id`=p.`id`))),
+	NOW() FROM plugins AS p WHERE p.`enabled`=TRUE;
+INSERT IGNORE INTO catalogue_changes (`kind`,`op`,`id`,`tag`,`hash`,`time`)
+	SELECT 'release','upsert',r.`id`,r.`tag`,
+	SHA1(CONCAT_WS(0x1f,r.`stable`,r.`size`,r.`uploaded`,r.`filename`,r.`github_url`,r.`name`,r.`changelog`,(SELECT GROUP_CONCAT(`name`,':',`size` ORDER BY `name`) FROM plugin_release_assets WHERE `id`=r.`id` AND `tag`=r.`tag`))),
+	NOW() FROM plugin_releases AS r
+	JOIN plugins AS p ON r.`id`=p.`id` WHERE p.`enabled`=TRUE AND r.`enabled`=TRUE;
+
+-- the sequence numbers of catalogue_changes and catalogue_events are allocated from the single counter row,
+-- which is locked until the transaction ends, so the numbers are committed in order
+CREATE TABLE IF NOT EXISTS catalogue_seq (
+	`id`  TINYINT UNSIGNED NOT NULL,
+	`seq` BIGINT UNSIGNED NOT NULL,
+	PRIMARY KEY (`id`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8;
+INSERT IGNORE INTO catalogue_seq (`id`,`seq`)
+	SELECT 1,GREATEST(IFNULL((SELECT MAX(`seq`) FROM catalogue_changes),0),IFNULL((SELECT MAX(`seq`) FROM catalogue_events),0));
+
+-- the downloads of the extra assets are recorded in the series too, the existing rows are the main files
+ALTER TABLE plugin_downloads ADD `filename` VARCHAR(256) DEFAULT '' NOT NULL AFTER `tag`;
+UPDATE plugin_downloads AS d JOIN plugin_releases AS r ON d.`id`=r.`id` AND d.`tag`=r.`tag`
+	SET d.`filename`=r.`filename` WHERE d.`filename`='';
+ALTER TABLE plugin_downloads DROP PRIMARY KEY, ADD PRIMARY KEY (`id`, `tag`, `filename`, `day`);
@@ -40,7 +40,9 @@ process.on('uncaughtException', function (err){
 	}
 
 	app.get('*', async (req, res, next) => {
-		const locale = req.cookies[LANG_COOKIE] || req.acceptsLanguages(...Object.keys(i18nLangMap))
+		// the url param is used by the alternate links in the sitemap
+		const queryLang = req.query.lang in i18nLangMap ?req.query.lang :null
+		const locale = queryLang || req.cookies[LANG_COOKIE] || req.acceptsLanguages(...Object.keys(i18nLangMap))
 		const pageContextInit = {
 			urlOriginal: req.originalUrl,
 			i18nLocale: locale,